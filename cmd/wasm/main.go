@@ -0,0 +1,186 @@
+//go:build js && wasm
+
+// Command wasm exposes the measurement engine to browsers as `globalThis.taierspeed`, with HTTP traffic going through
+// the Fetch API and HTTP ping instead of ICMP ping.
+//
+//	const result = await taierspeed.runTest({duration: 10}, (event) => console.log(event))
+//	const servers = await taierspeed.discover({provinces: ["bj"], isps: ["ct"]})
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"syscall/js"
+	"time"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/speedtest"
+)
+
+// options are the options of runTest, durations are in seconds
+type options struct {
+	Server     *defs.Server `json:"server"`
+	APIBase    string       `json:"apiBase"`
+	Timeout    float64      `json:"timeout"`
+	PingCount  int          `json:"pingCount"`
+	NoDownload bool         `json:"noDownload"`
+	NoUpload   bool         `json:"noUpload"`
+	Concurrent int          `json:"concurrent"`
+	Duration   float64      `json:"duration"`
+	UploadSize int          `json:"uploadSize"`
+}
+
+// filters are the filters of discover
+type filters struct {
+	IDs       []string          `json:"ids"`
+	Provinces []string          `json:"provinces"`
+	ISPs      []string          `json:"isps"`
+	Types     []defs.ServerType `json:"types"`
+	APIBase   string            `json:"apiBase"`
+}
+
+func main() {
+	js.Global().Set("taierspeed", map[string]interface{}{
+		"version":  defs.ProgVersion,
+		"runTest":  js.FuncOf(runTest),
+		"discover": js.FuncOf(discover),
+	})
+	select {}
+}
+
+// runTest runs a speed test with the options in args[0], args[1] is called with every event if given
+func runTest(_ js.Value, args []js.Value) interface{} {
+	var o options
+	if err := decode(args, 0, &o); err != nil {
+		return reject(err)
+	}
+
+	opts := speedtest.DefaultOptions()
+	opts.Server = o.Server
+	opts.NoICMP = true
+	opts.NoDownload, opts.NoUpload = o.NoDownload, o.NoUpload
+	if o.APIBase != "" {
+		opts.APIBase = o.APIBase
+	}
+	if o.Timeout > 0 {
+		opts.Timeout = seconds(o.Timeout)
+	}
+	if o.PingCount > 0 {
+		opts.PingCount = o.PingCount
+	}
+	if o.Concurrent > 0 {
+		opts.Concurrent = o.Concurrent
+	}
+	if o.Duration > 0 {
+		opts.Duration = seconds(o.Duration)
+	}
+	if o.UploadSize > 0 {
+		opts.UploadSize = o.UploadSize
+	}
+	if len(args) > 1 && args[1].Type() == js.TypeFunction {
+		onEvent := args[1]
+		opts.Events = speedtest.NewBus()
+		opts.Events.Handle(func(e speedtest.Event) {
+			onEvent.Invoke(encodeEvent(e))
+		})
+	}
+
+	return promise(func() (interface{}, error) {
+		return speedtest.RunTest(context.Background(), opts)
+	})
+}
+
+// discover returns the servers matching the filters in args[0]
+func discover(_ js.Value, args []js.Value) interface{} {
+	var f filters
+	if err := decode(args, 0, &f); err != nil {
+		return reject(err)
+	}
+
+	opts := speedtest.DefaultOptions()
+	if f.APIBase != "" {
+		opts.APIBase = f.APIBase
+	}
+
+	return promise(func() (interface{}, error) {
+		return speedtest.Discover(context.Background(), speedtest.Filters{
+			IDs:       f.IDs,
+			Provinces: f.Provinces,
+			ISPs:      f.ISPs,
+			Types:     f.Types,
+			Options:   &opts,
+		})
+	})
+}
+
+// encodeEvent converts an event to a JS object, with its kind in the `type` field
+func encodeEvent(e speedtest.Event) js.Value {
+	v, err := encode(e)
+	if err != nil {
+		v = js.Global().Get("Object").New()
+	}
+	v.Set("type", speedtest.EventType(e))
+	if rc, ok := e.(speedtest.RunComplete); ok && rc.Err != nil {
+		v.Set("Err", rc.Err.Error())
+	}
+	return v
+}
+
+// promise runs `fn` in a goroutine, as blocking calls would deadlock the event loop, and returns a promise of its
+// result
+func promise(fn func() (interface{}, error)) js.Value {
+	var handler js.Func
+	handler = js.FuncOf(func(_ js.Value, args []js.Value) interface{} {
+		resolve, reject := args[0], args[1]
+		go func() {
+			defer handler.Release()
+			res, err := fn()
+			if err == nil {
+				var v js.Value
+				if v, err = encode(res); err == nil {
+					resolve.Invoke(v)
+					return
+				}
+			}
+			reject.Invoke(js.Global().Get("Error").New(err.Error()))
+		}()
+		return nil
+	})
+	return js.Global().Get("Promise").New(handler)
+}
+
+// reject returns a rejected promise
+func reject(err error) js.Value {
+	return js.Global().Get("Promise").Call("reject", js.Global().Get("Error").New(err.Error()))
+}
+
+// decode unmarshals the JS object args[i] into `v` through JSON, a missing argument leaves `v` unchanged
+func decode(args []js.Value, i int, v interface{}) error {
+	if len(args) <= i || args[i].IsUndefined() || args[i].IsNull() {
+		return nil
+	}
+	if args[i].Type() != js.TypeObject {
+		return errors.New("options must be an object")
+	}
+	s := js.Global().Get("JSON").Call("stringify", args[i]).String()
+	if err := json.Unmarshal([]byte(s), v); err != nil {
+		return fmt.Errorf("invalid options: %s", err)
+	}
+	return nil
+}
+
+// encode marshals `v` into a JS object through JSON
+func encode(v interface{}) (js.Value, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return js.Value{}, err
+	}
+	return js.Global().Get("JSON").Call("parse", string(b)), nil
+}
+
+// seconds converts seconds to a duration
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
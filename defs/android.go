@@ -0,0 +1,15 @@
+package defs
+
+import (
+	"os"
+	"runtime"
+)
+
+// Android is set when running on Android, e.g. in Termux, which is detected on start up or given by --android. ICMP
+// ping is unprivileged then, and the data is kept in the temporary directory if the config directory isn't writable
+var Android = detectAndroid()
+
+// detectAndroid checks for builds for Android, and for the environment of Android shells on Linux builds
+func detectAndroid() bool {
+	return runtime.GOOS == "android" || os.Getenv("TERMUX_VERSION") != "" || os.Getenv("ANDROID_ROOT") != ""
+}
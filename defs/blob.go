@@ -0,0 +1,35 @@
+package defs
+
+import (
+	"crypto/rand"
+	"io"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// openBlob opens `path` for MapBlob, the file is created and filled with random data when it's smaller than `length`
+// bytes
+func openBlob(path string, length int) (*os.File, error) {
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	fi, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if fi.Size() < int64(length) {
+		log.Debugf("Filling %s with %d bytes of upload data", path, length)
+		if _, err = f.Seek(fi.Size(), io.SeekStart); err == nil {
+			_, err = io.CopyN(f, rand.Reader, int64(length)-fi.Size())
+		}
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,25 @@
+//go:build !unix
+
+package defs
+
+// MapBlob loads `path` as the cached upload blob, the file is created and filled with random data when it's smaller
+// than `length` KiB. Memory mapping is not available on this platform, so the file is read into memory instead
+func MapBlob(path string, length int) error {
+	length *= 1024
+
+	f, err := openBlob(path, length)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data := make([]byte, length)
+	if _, err = f.ReadAt(data, 0); err != nil {
+		return err
+	}
+
+	blobLock.Lock()
+	defer blobLock.Unlock()
+	blobCache = data
+	return nil
+}
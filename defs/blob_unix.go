@@ -0,0 +1,27 @@
+//go:build unix
+
+package defs
+
+import "golang.org/x/sys/unix"
+
+// MapBlob memory-maps `path` as the cached upload blob, the file is created and filled with random data when it's
+// smaller than `length` KiB. The mapped pages are backed by the page cache instead of the Go heap
+func MapBlob(path string, length int) error {
+	length *= 1024
+
+	f, err := openBlob(path, length)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	data, err := unix.Mmap(int(f.Fd()), 0, length, unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return err
+	}
+
+	blobLock.Lock()
+	defer blobLock.Unlock()
+	blobCache = data
+	return nil
+}
@@ -1,24 +1,41 @@
 package defs
 
 import (
-	"bytes"
 	"crypto/rand"
 	"fmt"
 	"io"
-	"log"
 	"sync"
 	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ztelliot/taierspeed-cli/i18n"
+)
+
+// DefaultCopyBufferSize is the size of the buffer used by each download stream unless Server.BufferSize is set
+const DefaultCopyBufferSize = 32 * 1024
+
+// SampleInterval is the interval between two progress samples of a transfer
+const SampleInterval = 100 * time.Millisecond
+
+var (
+	blobLock  sync.Mutex
+	blobCache []byte
 )
 
 // BytesCounter implements io.Reader and io.Writer interface, for counting bytes being read/written in HTTP requests
 type BytesCounter struct {
 	start      time.Time
-	pos        int
 	total      uint64
 	payload    []byte
-	reader     io.ReadSeeker
 	mebi       bool
 	uploadSize int
+	// rate caps the throughput in bytes/second, unlimited when zero
+	rate float64
+	// stats are the requests of the transfer workers, set when the transfer ended
+	stats *WorkerStats
+	// streams are the finished streams of the transfer
+	streams []stream
 
 	lock *sync.Mutex
 }
@@ -34,23 +51,17 @@ func (c *BytesCounter) Write(p []byte) (int, error) {
 	n := len(p)
 	c.lock.Lock()
 	c.total += uint64(n)
+	total := c.total
 	c.lock.Unlock()
 
+	c.throttle(total)
 	return n, nil
 }
 
-// Read implements io.Reader
-func (c *BytesCounter) Read(p []byte) (int, error) {
-	n, err := c.reader.Read(p)
-	c.lock.Lock()
-	c.total += uint64(n)
-	c.pos += n
-	if c.pos == c.uploadSize {
-		c.resetReader()
-	}
-	c.lock.Unlock()
-
-	return n, err
+// NewReader returns an endless io.Reader over the upload payload which counts the bytes being read, every concurrent
+// request should use its own reader. Random data is generated on the fly when no payload is pre allocated
+func (c *BytesCounter) NewReader() io.Reader {
+	return &payloadReader{counter: c, payload: c.payload}
 }
 
 // SetMebi sets the base for dividing bytes into megabyte or mebibyte
@@ -58,6 +69,24 @@ func (c *BytesCounter) SetMebi(mebi bool) {
 	c.mebi = mebi
 }
 
+// SetRateLimit caps the throughput of all requests counted by the counter at `mbps`, unlimited when zero
+func (c *BytesCounter) SetRateLimit(mbps float64) {
+	c.rate = mbps * 125000
+}
+
+// throttle sleeps until `total` bytes are due at the rate limit
+func (c *BytesCounter) throttle(total uint64) {
+	if c.rate <= 0 {
+		return
+	}
+	time.Sleep(time.Until(c.start.Add(time.Duration(float64(total) / c.rate * float64(time.Second)))))
+}
+
+// Stats returns the requests of the transfer workers once the transfer ended, nil for transfers without workers
+func (c *BytesCounter) Stats() *WorkerStats {
+	return c.stats
+}
+
 // SetUploadSize sets the size of payload being uploaded
 func (c *BytesCounter) SetUploadSize(uploadSize int) {
 	c.uploadSize = uploadSize * 1024
@@ -65,10 +94,10 @@ func (c *BytesCounter) SetUploadSize(uploadSize int) {
 
 // AvgBytes returns the average bytes/second
 func (c *BytesCounter) AvgBytes() float64 {
-	return float64(c.total) / time.Since(c.start).Seconds()
+	return float64(c.Total()) / time.Since(c.start).Seconds()
 }
 
-// AvgMbps returns the average mbits/second
+// AvgMbps returns the average mbits/second (or mibits/second)
 func (c *BytesCounter) AvgMbps() float64 {
 	var base float64 = 125000
 	if c.mebi {
@@ -99,14 +128,14 @@ func (c *BytesCounter) AvgHumanize() string {
 
 // Bytes returns the Bytes
 func (c *BytesCounter) Bytes() float64 {
-	return float64(c.total) / 8
+	return float64(c.Total())
 }
 
-// MBytes returns the MBytes
+// MBytes returns the MBytes (or MiBytes)
 func (c *BytesCounter) MBytes() float64 {
-	var base float64 = 125000
+	var base float64 = 1000000
 	if c.mebi {
-		base = 131072
+		base = 1048576
 	}
 	return c.Bytes() / base
 }
@@ -131,17 +160,10 @@ func (c *BytesCounter) BytesHumanize() string {
 	}
 }
 
-// GenerateBlob generates a random byte array of `uploadSize` in the `payload` field, and sets the `reader` field to
-// read from it
+// GenerateBlob fills the `payload` field with a random byte array of `uploadSize`. The blob is cached and shared by
+// every upload phase in the process
 func (c *BytesCounter) GenerateBlob() {
-	c.payload = getRandomData(c.uploadSize)
-	c.reader = bytes.NewReader(c.payload)
-}
-
-// resetReader resets the `reader` field to 0 position
-func (c *BytesCounter) resetReader() (int64, error) {
-	c.pos = 0
-	return c.reader.Seek(0, 0)
+	c.payload = getBlob(c.uploadSize)
 }
 
 // Start will set the `start` field to current time
@@ -149,24 +171,75 @@ func (c *BytesCounter) Start() {
 	c.start = time.Now()
 }
 
+// Elapsed returns the time elapsed since the `start` field
+func (c *BytesCounter) Elapsed() time.Duration {
+	return time.Since(c.start)
+}
+
 // Total returns the total bytes read/written
 func (c *BytesCounter) Total() uint64 {
+	c.lock.Lock()
+	defer c.lock.Unlock()
 	return c.total
 }
 
 // CurrentSpeed returns the current bytes/second
 func (c *BytesCounter) CurrentSpeed() float64 {
-	return float64(c.total) / time.Since(c.start).Seconds()
+	return c.AvgBytes()
 }
 
-// SeekWrapper is a wrapper around io.Reader to give it a noop io.Seeker interface
-type SeekWrapper struct {
-	io.Reader
+// sampleCounter calls `progress` with the counter every SampleInterval, until the returned function is called
+func sampleCounter(counter *BytesCounter, progress func(*BytesCounter)) func() {
+	done := make(chan struct{})
+	stopped := make(chan struct{})
+
+	go func() {
+		defer close(stopped)
+		ticker := time.NewTicker(SampleInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				progress(counter)
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		<-stopped
+	}
 }
 
-// Seek implements the io.Seeker interface
-func (r *SeekWrapper) Seek(offset int64, whence int) (int64, error) {
-	return 0, nil
+// payloadReader reads the payload of a BytesCounter in a loop
+type payloadReader struct {
+	counter *BytesCounter
+	payload []byte
+	pos     int
+}
+
+// Read implements io.Reader
+func (r *payloadReader) Read(p []byte) (int, error) {
+	var n int
+	if len(r.payload) == 0 {
+		var err error
+		if n, err = rand.Read(p); err != nil {
+			return n, err
+		}
+	} else {
+		n = copy(p, r.payload[r.pos:])
+		r.pos = (r.pos + n) % len(r.payload)
+	}
+
+	r.counter.lock.Lock()
+	r.counter.total += uint64(n)
+	total := r.counter.total
+	r.counter.lock.Unlock()
+
+	r.counter.throttle(total)
+	return n, nil
 }
 
 // getAvg returns the average value of a float64 array
@@ -179,11 +252,29 @@ func getAvg(vals []float64) float64 {
 	return total / float64(len(vals))
 }
 
+// milliseconds returns a duration in milliseconds, keeping the precision of sub-millisecond RTTs
+func milliseconds(d time.Duration) float64 {
+	return float64(d.Microseconds()) / 1000
+}
+
+// getBlob returns a random byte array of `length` from the blob cache, the cache is only regenerated when it's smaller
+// than requested
+func getBlob(length int) []byte {
+	blobLock.Lock()
+	defer blobLock.Unlock()
+
+	if len(blobCache) < length {
+		log.Debugf("Generating %d bytes of upload data", length)
+		blobCache = getRandomData(length)
+	}
+	return blobCache[:length]
+}
+
 // getRandomData returns an `length` sized array of random bytes
 func getRandomData(length int) []byte {
 	data := make([]byte, length)
 	if _, err := rand.Read(data); err != nil {
-		log.Fatalf("Failed to generate random data: %s", err)
+		log.Fatalf(i18n.T("Failed to generate random data: %s"), err)
 	}
 	return data
 }
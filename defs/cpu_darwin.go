@@ -0,0 +1,22 @@
+package defs
+
+import (
+	"runtime"
+	"time"
+
+	"golang.org/x/sys/unix"
+)
+
+// SampleCPU takes a snapshot of the CPU time used by the process. The CPU time of the whole system needs the Mach
+// host API, which isn't available without cgo, so only the utilization of the process is measured
+func SampleCPU() (*CPUSample, error) {
+	var usage unix.Rusage
+	if err := unix.Getrusage(unix.RUSAGE_SELF, &usage); err != nil {
+		return nil, err
+	}
+	return &CPUSample{
+		time:    time.Now(),
+		process: uint64(usage.Utime.Nano() + usage.Stime.Nano()),
+		cpus:    runtime.NumCPU(),
+	}, nil
+}
@@ -0,0 +1,63 @@
+package defs
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// clock ticks per second used by /proc, USER_HZ is fixed to 100 on Linux
+const userHZ = 100
+
+// SampleCPU takes a snapshot of the CPU time used by the process and the whole system
+func SampleCPU() (*CPUSample, error) {
+	self, err := os.ReadFile("/proc/self/stat")
+	if err != nil {
+		return nil, err
+	}
+	// the process name may contain spaces, so fields are counted after the closing bracket
+	idx := strings.LastIndexByte(string(self), ')')
+	if idx < 0 {
+		return nil, errors.New("malformed /proc/self/stat")
+	}
+	fields := strings.Fields(string(self)[idx+1:])
+	if len(fields) < 13 {
+		return nil, errors.New("malformed /proc/self/stat")
+	}
+	utime, _ := strconv.ParseUint(fields[11], 10, 64)
+	stime, _ := strconv.ParseUint(fields[12], 10, 64)
+
+	stat, err := os.ReadFile("/proc/stat")
+	if err != nil {
+		return nil, err
+	}
+	line, _, _ := strings.Cut(string(stat), "\n")
+	fields = strings.Fields(line)
+	if len(fields) < 5 || fields[0] != "cpu" {
+		return nil, errors.New("malformed /proc/stat")
+	}
+	var total, idle uint64
+	for i, f := range fields[1:] {
+		// guest time is already accounted in user time
+		if i >= 8 {
+			break
+		}
+		v, _ := strconv.ParseUint(f, 10, 64)
+		total += v
+		// idle and iowait
+		if i == 3 || i == 4 {
+			idle += v
+		}
+	}
+
+	return &CPUSample{
+		time:    time.Now(),
+		process: (utime + stime) * uint64(time.Second) / userHZ,
+		busy:    total - idle,
+		total:   total,
+		cpus:    runtime.NumCPU(),
+	}, nil
+}
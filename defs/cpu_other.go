@@ -0,0 +1,12 @@
+//go:build !linux && !darwin && !windows
+
+package defs
+
+import (
+	"errors"
+)
+
+// SampleCPU takes a snapshot of the CPU time used by the process and the whole system, only available for linux, darwin and windows
+func SampleCPU() (*CPUSample, error) {
+	return nil, errors.New("CPU sampling is not supported on this platform")
+}
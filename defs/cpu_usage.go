@@ -0,0 +1,50 @@
+package defs
+
+import (
+	"time"
+)
+
+// cpuBoundThreshold is the utilization in percent above which a test is considered CPU-bound
+const cpuBoundThreshold = 90
+
+// CPUSample is a snapshot of the CPU time used by the process and the whole system
+type CPUSample struct {
+	time    time.Time
+	process uint64
+	busy    uint64
+	total   uint64
+	cpus    int
+}
+
+// CPUUsage represents the CPU utilization between two samples, in percent of all CPUs. Core is the utilization of the
+// process in percent of a single CPU, above 100 if it kept several busy. System is left out on platforms where only the
+// utilization of the process can be measured
+type CPUUsage struct {
+	Process float64 `json:"process"`
+	Core    float64 `json:"core,omitempty"`
+	System  float64 `json:"system,omitempty"`
+}
+
+// UsageSince returns the CPU utilization between `prev` and this sample
+func (s *CPUSample) UsageSince(prev *CPUSample) CPUUsage {
+	var usage CPUUsage
+	if wall := s.time.Sub(prev.time); wall > 0 && s.cpus > 0 {
+		usage.Core = float64(s.process-prev.process) / float64(wall.Nanoseconds()) * 100
+		usage.Process = usage.Core / float64(s.cpus)
+	}
+	if total := s.total - prev.total; total > 0 {
+		usage.System = float64(s.busy-prev.busy) / float64(total) * 100
+	}
+	return usage
+}
+
+// Bound checks if the utilization is high enough to limit the test result
+func (u CPUUsage) Bound() bool {
+	return u.Process >= cpuBoundThreshold || u.System >= cpuBoundThreshold || u.CoreBound()
+}
+
+// CoreBound checks if the process kept a single CPU busy, which limits the test result even with other CPUs idle as
+// much of the transfer runs on one
+func (u CPUUsage) CoreBound() bool {
+	return u.Core >= cpuBoundThreshold
+}
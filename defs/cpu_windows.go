@@ -0,0 +1,39 @@
+package defs
+
+import (
+	"runtime"
+	"time"
+	"unsafe"
+
+	"golang.org/x/sys/windows"
+)
+
+var procGetSystemTimes = windows.NewLazySystemDLL("kernel32.dll").NewProc("GetSystemTimes")
+
+// SampleCPU takes a snapshot of the CPU time used by the process and the whole system
+func SampleCPU() (*CPUSample, error) {
+	var creation, exit, kernel, user windows.Filetime
+	if err := windows.GetProcessTimes(windows.CurrentProcess(), &creation, &exit, &kernel, &user); err != nil {
+		return nil, err
+	}
+
+	var idle, sysKernel, sysUser windows.Filetime
+	if r, _, err := procGetSystemTimes.Call(uintptr(unsafe.Pointer(&idle)), uintptr(unsafe.Pointer(&sysKernel)), uintptr(unsafe.Pointer(&sysUser))); r == 0 {
+		return nil, err
+	}
+	// the kernel time of the system includes the idle time
+	total := filetime(sysKernel) + filetime(sysUser)
+
+	return &CPUSample{
+		time:    time.Now(),
+		process: (filetime(kernel) + filetime(user)) * 100,
+		busy:    total - filetime(idle),
+		total:   total,
+		cpus:    runtime.NumCPU(),
+	}, nil
+}
+
+// filetime returns a FILETIME duration in its unit of 100 ns
+func filetime(ft windows.Filetime) uint64 {
+	return uint64(ft.HighDateTime)<<32 | uint64(ft.LowDateTime)
+}
@@ -0,0 +1,27 @@
+package defs
+
+import (
+	"os"
+	"path/filepath"
+)
+
+// DataDir returns the directory of the data kept on this machine, like the result history. It's created if needed. On
+// Android, where $HOME might be unset or read-only outside of Termux, the temporary directory is used instead
+func DataDir() (string, error) {
+	dir, err := configDir()
+	if err != nil && Android {
+		dir = filepath.Join(os.TempDir(), "taierspeed-cli")
+		err = os.MkdirAll(dir, 0o755)
+	}
+	return dir, err
+}
+
+// configDir returns the directory of the data in the config directory of the user, it's created if needed
+func configDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "taierspeed-cli")
+	return dir, os.MkdirAll(dir, 0o755)
+}
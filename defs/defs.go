@@ -18,10 +18,10 @@ var (
 )
 
 type ProvinceInfo struct {
-	ID    uint8  `csv:"id"`
-	Code  string `csv:"code"`
-	Short string `csv:"short"`
-	Name  string `csv:"name"`
+	ID    uint8  `csv:"id" json:"id"`
+	Code  string `csv:"code" json:"code"`
+	Short string `csv:"short" json:"short"`
+	Name  string `csv:"name" json:"name"`
 }
 
 type ServerResponse struct {
@@ -0,0 +1,92 @@
+//go:build !js
+
+package defs
+
+import (
+	"context"
+	"math"
+	"time"
+
+	"github.com/go-ping/ping"
+	log "github.com/sirupsen/logrus"
+)
+
+// ICMPPingAndJitter pings the server via ICMP echos and calculate the average ping and jitter, `onPing` is called with
+// the RTT of every echo if not nil
+func (s *Server) ICMPPingAndJitter(ctx context.Context, count int, srcIp, network string, onPing func(float64)) (float64, float64, error) {
+	if s.NoICMP {
+		log.Debugf("Skipping ICMP for server %s, will use HTTP ping", s.Name)
+		return s.PingAndJitter(ctx, count+2, onPing)
+	}
+
+	rtts, err := timestampedPing(ctx, s.Host, count, srcIp, network, onPing)
+	if err != nil {
+		log.Debugf("Kernel timestamps for ICMP ping unavailable: %s", err)
+		if rtts, err = goPing(ctx, s.Host, count, srcIp, network, onPing); err != nil {
+			log.Debugf("ICMP ping failed: %s, will use HTTP ping", err)
+			return s.PingAndJitter(ctx, count+2, onPing)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+
+	if len(rtts) == 0 {
+		s.NoICMP = true
+		log.Debugf("No ICMP pings returned for server %s (%s), trying TCP ping", s.Name, s.IP)
+		return s.PingAndJitter(ctx, count+2, onPing)
+	}
+
+	var lastPing, jitter float64
+	for idx, rtt := range rtts {
+		if idx != 0 {
+			instJitter := math.Abs(lastPing - rtt)
+			if idx > 1 {
+				if jitter > instJitter {
+					jitter = jitter*0.7 + instJitter*0.3
+				} else {
+					jitter = instJitter*0.2 + jitter*0.8
+				}
+			}
+		}
+		lastPing = rtt
+	}
+
+	return getAvg(rtts), jitter, nil
+}
+
+// goPing pings `host` with go-ping, and returns the RTTs of the echos in milliseconds
+func goPing(ctx context.Context, host string, count int, srcIp, network string, onPing func(float64)) ([]float64, error) {
+	p, err := ping.NewPinger(host)
+	if err != nil {
+		return nil, err
+	}
+	// raw sockets are not allowed for apps on Android, which can only use ping sockets
+	p.SetPrivileged(!Android)
+	p.SetNetwork(network)
+	p.Count = count
+	p.Timeout = time.Duration(count) * time.Second
+	if srcIp != "" {
+		p.Source = srcIp
+	}
+	if log.GetLevel() == log.DebugLevel {
+		p.Debug = true
+	}
+	if onPing != nil {
+		p.OnRecv = func(pkt *ping.Packet) {
+			onPing(milliseconds(pkt.Rtt))
+		}
+	}
+	stop := context.AfterFunc(ctx, p.Stop)
+	defer stop()
+	if err := p.Run(); err != nil {
+		return nil, err
+	}
+
+	var rtts []float64
+	for _, rtt := range p.Statistics().Rtts {
+		rtts = append(rtts, milliseconds(rtt))
+	}
+	return rtts, nil
+}
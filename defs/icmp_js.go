@@ -0,0 +1,12 @@
+//go:build js
+
+package defs
+
+import (
+	"context"
+)
+
+// ICMPPingAndJitter falls back to HTTP ping, as ICMP is not available in browsers
+func (s *Server) ICMPPingAndJitter(ctx context.Context, count int, srcIp, network string, onPing func(float64)) (float64, float64, error) {
+	return s.PingAndJitter(ctx, count+2, onPing)
+}
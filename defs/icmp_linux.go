@@ -0,0 +1,176 @@
+package defs
+
+import (
+	"context"
+	"errors"
+	"net"
+	"os"
+	"time"
+	"unsafe"
+
+	log "github.com/sirupsen/logrus"
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// the interval between two echos, like go-ping
+	icmpInterval = time.Second
+	// the interval of checking the context while waiting for a reply
+	icmpPollInterval = 100 * time.Millisecond
+)
+
+// timestampedPing pings `host` with `count` ICMP echos a second apart, and returns the RTTs of the replies in
+// milliseconds. The receive time of every reply is taken by the kernel with SO_TIMESTAMPNS, so that sub-millisecond
+// RTTs to nearby servers aren't dominated by the delay of scheduling this process. Echos without a reply in time are
+// left out, an error is only returned if the socket can't be set up
+func timestampedPing(ctx context.Context, host string, count int, srcIp, network string, onPing func(float64)) ([]float64, error) {
+	addr, err := net.ResolveIPAddr(network, host)
+	if err != nil {
+		return nil, err
+	}
+	v4 := addr.IP.To4() != nil
+
+	var sa unix.Sockaddr
+	var typ, replyType byte
+	var proto int
+	if v4 {
+		sa4 := &unix.SockaddrInet4{}
+		copy(sa4.Addr[:], addr.IP.To4())
+		sa, typ, replyType, proto = sa4, 8, 0, unix.IPPROTO_ICMP
+	} else {
+		sa6 := &unix.SockaddrInet6{}
+		copy(sa6.Addr[:], addr.IP.To16())
+		sa, typ, replyType, proto = sa6, 128, 129, unix.IPPROTO_ICMPV6
+	}
+
+	fd, raw, err := icmpSocket(v4, proto)
+	if err != nil {
+		return nil, err
+	}
+	defer unix.Close(fd)
+	if err := unix.SetsockoptInt(fd, unix.SOL_SOCKET, unix.SO_TIMESTAMPNS, 1); err != nil {
+		return nil, err
+	}
+	if srcIp != "" {
+		if err := bindSource(fd, srcIp, v4); err != nil {
+			return nil, err
+		}
+	}
+
+	// the kernel replaces the identifier of ping sockets with their port, and only delivers their own replies
+	id := os.Getpid() & 0xffff
+	buf := make([]byte, 1500)
+	oob := make([]byte, 128)
+	var rtts []float64
+	for seq := 0; seq < count && ctx.Err() == nil; seq++ {
+		deadline := time.Now().Add(icmpInterval)
+		sent := time.Now()
+		if err := unix.Sendto(fd, echoRequest(typ, id, seq, v4), 0, sa); err != nil {
+			log.Debugf("Failed to send ICMP echo: %s", err)
+		} else if received, ok := awaitReply(ctx, fd, buf, oob, deadline, replyType, id, seq, v4 && raw, raw); ok {
+			rtt := milliseconds(received.Sub(sent))
+			rtts = append(rtts, rtt)
+			if onPing != nil {
+				onPing(rtt)
+			}
+		}
+		if seq < count-1 {
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Until(deadline)):
+			}
+		}
+	}
+	return rtts, nil
+}
+
+// icmpSocket opens an unprivileged ping socket, or a raw socket if ping sockets aren't allowed for this user
+func icmpSocket(v4 bool, proto int) (fd int, raw bool, err error) {
+	family := unix.AF_INET6
+	if v4 {
+		family = unix.AF_INET
+	}
+	if fd, err = unix.Socket(family, unix.SOCK_DGRAM|unix.SOCK_CLOEXEC, proto); err == nil {
+		return fd, false, nil
+	}
+	if fd, err = unix.Socket(family, unix.SOCK_RAW|unix.SOCK_CLOEXEC, proto); err == nil {
+		return fd, true, nil
+	}
+	return -1, false, err
+}
+
+// bindSource binds the socket to the source IP address `srcIp`
+func bindSource(fd int, srcIp string, v4 bool) error {
+	ip := net.ParseIP(srcIp)
+	if ip == nil {
+		return errors.New("invalid source IP address")
+	}
+	if v4 {
+		sa := &unix.SockaddrInet4{}
+		copy(sa.Addr[:], ip.To4())
+		return unix.Bind(fd, sa)
+	}
+	sa := &unix.SockaddrInet6{}
+	copy(sa.Addr[:], ip.To16())
+	return unix.Bind(fd, sa)
+}
+
+// echoRequest returns an ICMP echo request of `typ`, the checksum of ICMPv6 is filled in by the kernel
+func echoRequest(typ byte, id, seq int, v4 bool) []byte {
+	pkt := make([]byte, 8+56)
+	pkt[0] = typ
+	pkt[4], pkt[5] = byte(id>>8), byte(id)
+	pkt[6], pkt[7] = byte(seq>>8), byte(seq)
+	if v4 {
+		var sum uint32
+		for i := 0; i < len(pkt); i += 2 {
+			sum += uint32(pkt[i])<<8 | uint32(pkt[i+1])
+		}
+		sum = sum>>16 + sum&0xffff
+		sum += sum >> 16
+		pkt[2], pkt[3] = byte(^sum>>8), byte(^sum)
+	}
+	return pkt
+}
+
+// awaitReply waits until `deadline` for the echo reply of `seq`, and returns the time the kernel received it. Replies
+// on raw sockets are checked for `id`, and start with the IP header for IPv4
+func awaitReply(ctx context.Context, fd int, buf, oob []byte, deadline time.Time, replyType byte, id, seq int, ipHeader, raw bool) (time.Time, bool) {
+	for ctx.Err() == nil {
+		wait := min(time.Until(deadline), icmpPollInterval)
+		if wait <= 0 {
+			return time.Time{}, false
+		}
+		fds := []unix.PollFd{{Fd: int32(fd), Events: unix.POLLIN}}
+		if n, err := unix.Poll(fds, int(wait.Milliseconds())+1); err != nil && !errors.Is(err, unix.EINTR) {
+			log.Debugf("Failed to wait for ICMP reply: %s", err)
+			return time.Time{}, false
+		} else if n <= 0 {
+			continue
+		}
+
+		n, oobn, _, _, err := unix.Recvmsg(fd, buf, oob, unix.MSG_DONTWAIT)
+		if err != nil {
+			continue
+		}
+		received := time.Now()
+		pkt := buf[:n]
+		if ipHeader && len(pkt) > 0 {
+			pkt = pkt[min(int(pkt[0]&0x0f)*4, len(pkt)):]
+		}
+		if len(pkt) < 8 || pkt[0] != replyType || int(pkt[6])<<8|int(pkt[7]) != seq || (raw && int(pkt[4])<<8|int(pkt[5]) != id) {
+			continue
+		}
+
+		if msgs, err := unix.ParseSocketControlMessage(oob[:oobn]); err == nil {
+			for _, m := range msgs {
+				if m.Header.Level == unix.SOL_SOCKET && m.Header.Type == unix.SCM_TIMESTAMPNS && len(m.Data) >= int(unsafe.Sizeof(unix.Timespec{})) {
+					ts := (*unix.Timespec)(unsafe.Pointer(&m.Data[0]))
+					received = time.Unix(ts.Unix())
+				}
+			}
+		}
+		return received, true
+	}
+	return time.Time{}, false
+}
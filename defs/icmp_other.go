@@ -0,0 +1,13 @@
+//go:build !linux && !js
+
+package defs
+
+import (
+	"context"
+	"errors"
+)
+
+// timestampedPing is only supported on linux, go-ping is used elsewhere
+func timestampedPing(ctx context.Context, host string, count int, srcIp, network string, onPing func(float64)) ([]float64, error) {
+	return nil, errors.New("only supported on linux")
+}
@@ -0,0 +1,322 @@
+package defs
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// IPerf3Port is the default port of iperf3 servers
+const IPerf3Port = 5201
+
+// states of the iperf3 control protocol
+const (
+	iperfTestStart       = 1
+	iperfTestRunning     = 2
+	iperfTestEnd         = 4
+	iperfParamExchange   = 9
+	iperfCreateStreams   = 10
+	iperfServerTerminate = 11
+	iperfExchangeResults = 13
+	iperfDisplayResults  = 14
+	iperfDone            = 16
+	iperfAccessDenied    = -1
+	iperfServerError     = -2
+)
+
+const (
+	// the cookie identifying a test is 36 characters and a trailing NUL
+	iperfCookieSize  = 37
+	iperfCookieChars = "abcdefghijklmnopqrstuvwxyz234567"
+	// the size of the blocks written by the sender
+	iperfBlockSize = 128 * 1024
+	// the timeout of each step of the control protocol
+	iperfControlTimeout = 10 * time.Second
+)
+
+// errIperfBusy is returned while the iperf3 server is running a test for another client
+var errIperfBusy = errors.New("iperf3 server is busy running a test")
+
+// iperfStreamResult is the result of a stream sent to the server after the test
+type iperfStreamResult struct {
+	ID          int     `json:"id"`
+	Bytes       uint64  `json:"bytes"`
+	Retransmits int     `json:"retransmits"`
+	Jitter      float64 `json:"jitter"`
+	Errors      int     `json:"errors"`
+	Packets     int     `json:"packets"`
+	StartTime   float64 `json:"start_time"`
+	EndTime     float64 `json:"end_time"`
+}
+
+// iperfResults are the results sent to the server after the test
+type iperfResults struct {
+	CPUUtilTotal         float64             `json:"cpu_util_total"`
+	CPUUtilUser          float64             `json:"cpu_util_user"`
+	CPUUtilSystem        float64             `json:"cpu_util_system"`
+	SenderHasRetransmits int                 `json:"sender_has_retransmits"`
+	Streams              []iperfStreamResult `json:"streams"`
+}
+
+// iperfAddr returns the address of the iperf3 server
+func (s *Server) iperfAddr() string {
+	return s.hostPort()
+}
+
+// iperfDial connects to the iperf3 server
+func (s *Server) iperfDial(ctx context.Context) (net.Conn, error) {
+	var d net.Dialer
+	if s.Dialer != nil {
+		d = *s.Dialer
+	}
+	return d.DialContext(ctx, "tcp", s.iperfAddr())
+}
+
+// iperfPing measures the time of connecting to the iperf3 server in milliseconds. The connection then runs an empty
+// test, as iperf3 servers log an error for every control connection closed before the end of a test
+func (s *Server) iperfPing(ctx context.Context) (float64, error) {
+	start := time.Now()
+	ctrl, err := s.iperfDial(ctx)
+	if err != nil {
+		return 0, err
+	}
+	rtt := time.Since(start)
+	defer ctrl.Close()
+	// a busy server is up all the same
+	if _, _, err := s.iperfTest(ctx, ctrl, false, 1, 0, nil); err != nil && !errors.Is(err, errIperfBusy) {
+		return 0, err
+	}
+	return milliseconds(rtt), nil
+}
+
+// iperfTransfer runs an iperf3 TCP test with `streams` parallel streams for `duration`, receiving from the server in
+// reverse mode or else sending to it. The throughput is measured on this side
+func (s *Server) iperfTransfer(ctx context.Context, reverse bool, streams int, duration time.Duration, progress func(*BytesCounter)) (float64, uint64, error) {
+	ctrl, err := s.iperfDial(ctx)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer ctrl.Close()
+	return s.iperfTest(ctx, ctrl, reverse, streams, duration, progress)
+}
+
+// iperfTest runs an iperf3 test over the control connection `ctrl` like iperfTransfer
+func (s *Server) iperfTest(ctx context.Context, ctrl net.Conn, reverse bool, streams int, duration time.Duration, progress func(*BytesCounter)) (float64, uint64, error) {
+	stop := context.AfterFunc(ctx, func() { ctrl.Close() })
+	defer stop()
+
+	cookie := make([]byte, iperfCookieSize)
+	rand.Read(cookie)
+	for i := range cookie[:iperfCookieSize-1] {
+		cookie[i] = iperfCookieChars[int(cookie[i])%len(iperfCookieChars)]
+	}
+	cookie[iperfCookieSize-1] = 0
+	if _, err := ctrl.Write(cookie); err != nil {
+		return 0, 0, err
+	}
+
+	counter := NewCounter()
+	counter.SetRateLimit(s.RateLimit)
+	// the measurement is taken when the test ends, the streams are drained until the server displays the results
+	var rate float64
+	var total uint64
+	var conns []net.Conn
+	var wg sync.WaitGroup
+	var start time.Time
+	defer func() {
+		for _, conn := range conns {
+			conn.Close()
+		}
+		wg.Wait()
+	}()
+
+	for {
+		state, err := iperfReadState(ctrl)
+		if err != nil {
+			if ctx.Err() != nil {
+				return 0, 0, ctx.Err()
+			}
+			return 0, 0, err
+		}
+
+		switch state {
+		case iperfParamExchange:
+			params := map[string]interface{}{
+				"tcp":            true,
+				"omit":           0,
+				"time":           int(duration.Seconds()),
+				"parallel":       streams,
+				"len":            iperfBlockSize,
+				"pacing_timer":   1000,
+				"client_version": "3.1.3",
+			}
+			if reverse {
+				params["reverse"] = true
+			}
+			if err := iperfWriteJSON(ctrl, params); err != nil {
+				return 0, 0, err
+			}
+		case iperfCreateStreams:
+			for i := 0; i < streams; i++ {
+				conn, err := s.iperfDial(ctx)
+				if err != nil {
+					return 0, 0, err
+				}
+				conns = append(conns, conn)
+				if _, err := conn.Write(cookie); err != nil {
+					return 0, 0, err
+				}
+			}
+		case iperfTestStart:
+		case iperfTestRunning:
+			start = time.Now()
+			counter.Start()
+			for _, conn := range conns {
+				wg.Add(1)
+				go func(conn net.Conn) {
+					defer wg.Done()
+					iperfStream(conn, reverse, counter)
+				}(conn)
+			}
+
+			// the client ends the test in both directions
+			func() {
+				if progress != nil {
+					defer sampleCounter(counter, progress)()
+				}
+				select {
+				case <-ctx.Done():
+				case <-time.After(duration):
+				}
+			}()
+			if err := ctx.Err(); err != nil {
+				return 0, 0, err
+			}
+			rate = counter.AvgMbps()
+			if !reverse {
+				// stop sending without closing the streams, which the server still reads until the results
+				for _, conn := range conns {
+					conn.SetWriteDeadline(time.Now())
+				}
+				wg.Wait()
+			}
+			total = counter.Total()
+			if err := iperfWriteState(ctrl, iperfTestEnd); err != nil {
+				return 0, 0, err
+			}
+		case iperfExchangeResults:
+			results := iperfResults{}
+			for i, sid := 0, 1; i < streams; i++ {
+				// iperf3 skips the stream ID 2
+				results.Streams = append(results.Streams, iperfStreamResult{ID: sid, EndTime: time.Since(start).Seconds()})
+				if sid++; sid == 2 {
+					sid++
+				}
+			}
+			if !reverse {
+				// only the total is known, the server doesn't mind how it's split
+				results.Streams[0].Bytes = total
+			}
+			if err := iperfWriteJSON(ctrl, results); err != nil {
+				return 0, 0, err
+			}
+			var serverResults json.RawMessage
+			if err := iperfReadJSON(ctrl, &serverResults); err != nil {
+				return 0, 0, err
+			}
+			log.Debugf("iperf3 server results: %s", serverResults)
+		case iperfDisplayResults:
+			iperfWriteState(ctrl, iperfDone)
+			return rate, total, nil
+		case iperfAccessDenied:
+			return 0, 0, errIperfBusy
+		case iperfServerError:
+			var codes [2]int32
+			binary.Read(ctrl, binary.BigEndian, &codes)
+			return 0, 0, fmt.Errorf("iperf3 server error %d (errno %d)", codes[0], codes[1])
+		case iperfServerTerminate:
+			return 0, 0, errors.New("iperf3 server terminated the test")
+		default:
+			return 0, 0, fmt.Errorf("unexpected iperf3 state %d", state)
+		}
+	}
+}
+
+// iperfStream sends or receives the data of a stream until the connection is closed, or sending is stopped by the
+// write deadline
+func iperfStream(conn net.Conn, reverse bool, counter *BytesCounter) {
+	buf := make([]byte, iperfBlockSize)
+	start := time.Now()
+	var total uint64
+	defer func() { counter.addStream(total, time.Since(start)) }()
+	for {
+		var n int
+		var err error
+		if reverse {
+			n, err = conn.Read(buf)
+		} else {
+			n, err = conn.Write(buf)
+		}
+		counter.Write(buf[:n])
+		total += uint64(n)
+		if err != nil {
+			if err != io.EOF && !errors.Is(err, net.ErrClosed) && !errors.Is(err, os.ErrDeadlineExceeded) {
+				log.Debugf("iperf3 stream failed: %s", err)
+			}
+			return
+		}
+	}
+}
+
+// iperfReadState reads a state of the control protocol
+func iperfReadState(conn net.Conn) (int8, error) {
+	conn.SetReadDeadline(time.Now().Add(iperfControlTimeout))
+	var state int8
+	err := binary.Read(conn, binary.BigEndian, &state)
+	return state, err
+}
+
+// iperfWriteState writes a state of the control protocol
+func iperfWriteState(conn net.Conn, state int8) error {
+	return binary.Write(conn, binary.BigEndian, state)
+}
+
+// iperfWriteJSON writes a JSON message prefixed with its length
+func iperfWriteJSON(conn net.Conn, v interface{}) error {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	msg := make([]byte, 4+len(b))
+	binary.BigEndian.PutUint32(msg, uint32(len(b)))
+	copy(msg[4:], b)
+	_, err = conn.Write(msg)
+	return err
+}
+
+// iperfReadJSON reads a JSON message prefixed with its length
+func iperfReadJSON(conn net.Conn, v interface{}) error {
+	conn.SetReadDeadline(time.Now().Add(iperfControlTimeout))
+	var size uint32
+	if err := binary.Read(conn, binary.BigEndian, &size); err != nil {
+		return err
+	}
+	if size > 1<<20 {
+		return fmt.Errorf("iperf3 message of %d bytes is too large", size)
+	}
+	b := make([]byte, size)
+	if _, err := io.ReadFull(conn, b); err != nil {
+		return err
+	}
+	return json.Unmarshal(b, v)
+}
@@ -1,6 +1,7 @@
 package defs
 
 import (
+	"context"
 	"encoding/json"
 	log "github.com/sirupsen/logrus"
 	"io"
@@ -15,15 +16,15 @@ type IPInfoResponse struct {
 	ISP      string `json:"isp"`
 }
 
-func request(url string, obj any) error {
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+func request(ctx context.Context, client *http.Client, url string, obj any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		log.Debugf("Failed when creating HTTP request: %s", err)
 		return err
 	}
 	req.Header.Set("User-Agent", AndroidUA)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		log.Debugf("Failed when making HTTP request: %s", err)
 		return err
@@ -42,13 +43,13 @@ func request(url string, obj any) error {
 	return nil
 }
 
-func uPai() (*IPInfoResponse, error) {
+func uPai(ctx context.Context, client *http.Client) (*IPInfoResponse, error) {
 	var upaiyun struct {
 		IP     string         `json:"remote_addr"`
 		Detail IPInfoResponse `json:"remote_addr_location"`
 	}
 
-	if err := request("https://pubstatic.b0.upaiyun.com/?_upnode", &upaiyun); err != nil {
+	if err := request(ctx, client, "https://pubstatic.b0.upaiyun.com/?_upnode", &upaiyun); err != nil {
 		return nil, err
 	} else {
 		upaiyun.Detail.IP = upaiyun.IP
@@ -56,31 +57,31 @@ func uPai() (*IPInfoResponse, error) {
 	}
 }
 
-func bilibili() (*IPInfoResponse, error) {
+func bilibili(ctx context.Context, client *http.Client) (*IPInfoResponse, error) {
 	var bili struct {
 		Data IPInfoResponse `json:"data"`
 	}
 
-	if err := request("https://api.bilibili.com/x/web-interface/zone", &bili); err != nil {
+	if err := request(ctx, client, "https://api.bilibili.com/x/web-interface/zone", &bili); err != nil {
 		return nil, err
 	} else {
 		return &bili.Data, nil
 	}
 }
 
-func bilibiliLive() (*IPInfoResponse, error) {
+func bilibiliLive(ctx context.Context, client *http.Client) (*IPInfoResponse, error) {
 	var bili struct {
 		Data IPInfoResponse `json:"data"`
 	}
 
-	if err := request("https://api.live.bilibili.com/ip_service/v1/ip_service/get_ip_addr", &bili); err != nil {
+	if err := request(ctx, client, "https://api.live.bilibili.com/ip_service/v1/ip_service/get_ip_addr", &bili); err != nil {
 		return nil, err
 	} else {
 		return &bili.Data, nil
 	}
 }
 
-func ipip() (*IPInfoResponse, error) {
+func ipip(ctx context.Context, client *http.Client) (*IPInfoResponse, error) {
 	var data struct {
 		Data struct {
 			IP       string   `json:"ip"`
@@ -88,7 +89,7 @@ func ipip() (*IPInfoResponse, error) {
 		} `json:"data"`
 	}
 
-	if err := request("http://myip6.ipip.net/json", &data); err != nil {
+	if err := request(ctx, client, "http://myip6.ipip.net/json", &data); err != nil {
 		return nil, err
 	} else {
 		var ipInfo IPInfoResponse
@@ -101,14 +102,19 @@ func ipip() (*IPInfoResponse, error) {
 	}
 }
 
-func GetIPInfo() (*IPInfoResponse, error) {
+// GetIPInfo returns the public IP address and its location of the client, using http.DefaultClient if `client` is nil
+func GetIPInfo(ctx context.Context, client *http.Client) (*IPInfoResponse, error) {
 	var ipInfo *IPInfoResponse
 	var err error
 
-	if ipInfo, err = uPai(); err != nil || ipInfo.IP == "" {
-		if ipInfo, err = ipip(); err != nil || ipInfo.IP == "" {
-			if ipInfo, err = bilibiliLive(); err != nil || ipInfo.IP == "" {
-				if ipInfo, err = bilibili(); err != nil || ipInfo.IP == "" {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	if ipInfo, err = uPai(ctx, client); err != nil || ipInfo.IP == "" {
+		if ipInfo, err = ipip(ctx, client); err != nil || ipInfo.IP == "" {
+			if ipInfo, err = bilibiliLive(ctx, client); err != nil || ipInfo.IP == "" {
+				if ipInfo, err = bilibili(ctx, client); err != nil || ipInfo.IP == "" {
 					return nil, err
 				}
 			}
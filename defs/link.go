@@ -0,0 +1,55 @@
+package defs
+
+import (
+	"fmt"
+	"net"
+
+	log "github.com/sirupsen/logrus"
+)
+
+// Link represents the network interface a server is reached through, Speed is its negotiated link speed in Mbps if
+// known. Tunnel is set for interfaces of VPNs and other tunnels
+type Link struct {
+	Interface string `json:"interface"`
+	Speed     int    `json:"speed,omitempty"`
+	Tunnel    bool   `json:"tunnel,omitempty"`
+}
+
+// LinkTo returns the network interface routing to `host` and its link speed if known. No packet is sent, the interface
+// is looked up by the local address of an unconnected UDP socket
+func LinkTo(host string) (*Link, error) {
+	conn, err := net.Dial("udp", net.JoinHostPort(host, "9"))
+	if err != nil {
+		return nil, err
+	}
+	local := conn.LocalAddr().(*net.UDPAddr).IP
+	conn.Close()
+
+	ifaces, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+	for _, iface := range ifaces {
+		addrs, err := iface.Addrs()
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			if ipNet, ok := addr.(*net.IPNet); ok && ipNet.IP.Equal(local) {
+				return LinkOf(iface.Name), nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("no interface with address %s", local)
+}
+
+// LinkOf returns the network interface `iface` and its link speed if known
+func LinkOf(iface string) *Link {
+	link := &Link{Interface: iface, Tunnel: IsTunnel(iface)}
+	if speed, err := linkSpeed(iface); err != nil {
+		log.Debugf("Failed to get the link speed of %s: %s", iface, err)
+	} else {
+		link.Speed = speed
+	}
+	return link
+}
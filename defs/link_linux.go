@@ -0,0 +1,27 @@
+package defs
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// linkSpeed returns the negotiated link speed of the interface in Mbps
+func linkSpeed(iface string) (int, error) {
+	// reading the speed fails for interfaces without a link speed, e.g. loopback ones
+	b, err := os.ReadFile(filepath.Join("/sys/class/net", iface, "speed"))
+	if err != nil {
+		return 0, err
+	}
+	speed, err := strconv.Atoi(strings.TrimSpace(string(b)))
+	if err != nil {
+		return 0, err
+	}
+	// wireless and virtual interfaces report -1
+	if speed <= 0 {
+		return 0, errors.New("unknown link speed")
+	}
+	return speed, nil
+}
@@ -0,0 +1,12 @@
+//go:build !linux
+
+package defs
+
+import (
+	"errors"
+)
+
+// linkSpeed returns the negotiated link speed of the interface in Mbps, only available for linux
+func linkSpeed(iface string) (int, error) {
+	return 0, errors.New("link speed is not supported on this platform")
+}
@@ -0,0 +1,47 @@
+package defs
+
+// Metadata is the bundle of mappings used for classifying servers, which is updated from the project's repository so
+// that mapping fixes don't wait for a release
+type Metadata struct {
+	Version   string         `json:"version"`
+	ISPs      []ISPMetadata  `json:"isps"`
+	Provinces []ProvinceInfo `json:"provinces"`
+	// Aliases maps names which can be given by --server to server IDs
+	Aliases map[string]string `json:"aliases"`
+}
+
+// ISPMetadata is an ISP of the metadata bundle. Servers without a known ISP are assigned to the ISP whose Suffixes end
+// their name
+type ISPMetadata struct {
+	ID       uint8    `json:"id"`
+	ASN      uint16   `json:"asn"`
+	Short    string   `json:"short"`
+	Code     string   `json:"code"`
+	Name     string   `json:"name"`
+	Suffixes []string `json:"suffixes"`
+}
+
+var (
+	// ISPSuffixes maps suffixes of server names to ISP IDs, ISPs are matched by their name if empty
+	ISPSuffixes = map[string]uint8{}
+	// ServerAliases maps names which can be given by --server to server IDs
+	ServerAliases = map[string]string{}
+)
+
+// Apply updates ISPMap, ISPSuffixes and ServerAliases with the bundle, the ISPs known already are updated in place
+func (m *Metadata) Apply() {
+	for _, isp := range m.ISPs {
+		info := ISPInfo{ID: isp.ID, ASN: isp.ASN, Short: isp.Short, Code: isp.Code, Name: isp.Name}
+		if known, ok := ISPMap[isp.ID]; ok {
+			*known = info
+		} else {
+			ISPMap[isp.ID] = &info
+		}
+		for _, suffix := range isp.Suffixes {
+			ISPSuffixes[suffix] = isp.ID
+		}
+	}
+	for alias, id := range m.Aliases {
+		ServerAliases[alias] = id
+	}
+}
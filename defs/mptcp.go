@@ -0,0 +1,99 @@
+package defs
+
+import (
+	"context"
+	"net"
+	"sync"
+)
+
+// MPTCPUsage is how many connections of a test used Multipath TCP
+type MPTCPUsage struct {
+	// Conns is the number of connections dialed with MPTCP
+	Conns int `json:"conns"`
+	// Accepted is the number of connections the server accepted MPTCP on, the others fell back to plain TCP
+	Accepted int `json:"accepted"`
+	// Multipath is the number of connections which added subflows, i.e. actually used more than one path
+	Multipath int `json:"multipath"`
+}
+
+// MPTCP tracks the connections dialed with Multipath TCP, the usage is counted since the last Reset over the
+// connections closed meanwhile and the open ones
+type MPTCP struct {
+	lock sync.Mutex
+	open map[*mptcpConn]struct{}
+	// closed is the usage of the connections closed since the last Reset
+	closed MPTCPUsage
+}
+
+// mptcpConn is a connection tracked by MPTCP
+type mptcpConn struct {
+	net.Conn
+	tracker  *MPTCP
+	accepted bool
+	once     sync.Once
+}
+
+// Dial wraps `dial`, which must dial with net.Dialer.SetMultipathTCP enabled, to track the connections it dials
+func (m *MPTCP) Dial(dial func(ctx context.Context, network, address string) (net.Conn, error)) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		conn, err := dial(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+		tc, ok := conn.(*net.TCPConn)
+		if !ok {
+			return conn, nil
+		}
+		// MultipathTCP only fails on connections which aren't open anymore
+		accepted, _ := tc.MultipathTCP()
+		mc := &mptcpConn{Conn: conn, tracker: m, accepted: accepted}
+
+		m.lock.Lock()
+		if m.open == nil {
+			m.open = make(map[*mptcpConn]struct{})
+		}
+		m.open[mc] = struct{}{}
+		m.lock.Unlock()
+		return mc, nil
+	}
+}
+
+// Reset forgets the usage of the closed connections, e.g. before another test
+func (m *MPTCP) Reset() {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.closed = MPTCPUsage{}
+}
+
+// Usage returns the usage since the last Reset
+func (m *MPTCP) Usage() *MPTCPUsage {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	usage := m.closed
+	for c := range m.open {
+		c.count(&usage)
+	}
+	return &usage
+}
+
+// count accounts the connection in `usage`
+func (c *mptcpConn) count(usage *MPTCPUsage) {
+	usage.Conns++
+	if c.accepted {
+		usage.Accepted++
+		if subflows(c.Conn.(*net.TCPConn)) > 0 {
+			usage.Multipath++
+		}
+	}
+}
+
+// Close accounts the connection in the usage before closing it, subflows can't be checked after that
+func (c *mptcpConn) Close() error {
+	c.once.Do(func() {
+		c.tracker.lock.Lock()
+		defer c.tracker.lock.Unlock()
+		delete(c.tracker.open, c)
+		c.count(&c.tracker.closed)
+	})
+	return c.Conn.Close()
+}
@@ -0,0 +1,35 @@
+package defs
+
+import (
+	"net"
+	"unsafe"
+
+	"golang.org/x/sys/unix"
+)
+
+const (
+	// mptcpInfo is MPTCP_INFO of the SOL_MPTCP socket options
+	mptcpInfo = 1
+	// the size of the buffer struct mptcp_info is read into, the kernel copies as much of it as fits
+	mptcpInfoSize = 256
+)
+
+// subflows returns the number of subflows of an MPTCP connection besides the initial one, from the first field of
+// struct mptcp_info
+func subflows(conn *net.TCPConn) int {
+	raw, err := conn.SyscallConn()
+	if err != nil {
+		return 0
+	}
+	var buf [mptcpInfoSize]byte
+	size := uint32(len(buf))
+	var errno unix.Errno
+	raw.Control(func(fd uintptr) {
+		// the struct is binary, so it's read raw instead of as a string ending at the first zero byte
+		_, _, errno = unix.Syscall6(unix.SYS_GETSOCKOPT, fd, unix.SOL_MPTCP, mptcpInfo, uintptr(unsafe.Pointer(&buf[0])), uintptr(unsafe.Pointer(&size)), 0)
+	})
+	if errno != 0 || size == 0 {
+		return 0
+	}
+	return int(buf[0])
+}
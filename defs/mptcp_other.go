@@ -0,0 +1,11 @@
+//go:build !linux
+
+package defs
+
+import "net"
+
+// subflows returns the number of subflows of an MPTCP connection besides the initial one, MPTCP is only supported on
+// Linux
+func subflows(conn *net.TCPConn) int {
+	return 0
+}
@@ -1,45 +1,119 @@
 package defs
 
 const (
-	OptionHelp           = "help"
-	OptionIPv4           = "ipv4"
-	OptionIPv4Alt        = "4"
-	OptionIPv6           = "ipv6"
-	OptionIPv6Alt        = "6"
-	OptionNoDownload     = "no-download"
-	OptionNoUpload       = "no-upload"
-	OptionNoICMP         = "no-icmp"
-	OptionConcurrent     = "concurrent"
-	OptionConcurrentAlt  = "n"
-	OptionBytes          = "bytes"
-	OptionMebiBytes      = "mebibytes"
-	OptionSimple         = "simple"
-	OptionSimpleAlt      = "q"
-	OptionCSV            = "csv"
-	OptionCSVDelimiter   = "csv-delimiter"
-	OptionCSVHeader      = "csv-header"
-	OptionJSON           = "json"
-	OptionList           = "list"
-	OptionListAlt        = "l"
-	OptionServer         = "server"
-	OptionServerAlt      = "s"
-	OptionServerGroup    = "group"
-	OptionServerGroupAlt = "g"
-	OptionExclude        = "exclude"
-	OptionSource         = "source"
-	OptionInterface      = "interface"
-	OptionInterfaceAlt   = "i"
-	OptionTimeout        = "timeout"
-	OptionUploadSize     = "upload-size"
-	OptionDuration       = "duration"
-	OptionDurationAlt    = "t"
-	OptionNoPreAllocate  = "no-pre-allocate"
-	OptionVersion        = "version"
-	OptionVersionAlt     = "v"
-	OptionCheckUpdate    = "update"
-	OptionCheckUpdateAlt = "u"
-	OptionAPIBase        = "api-base"
-	OptionAPIVersion     = "api-version"
-	OptionTLSInsecure    = "tls-insecure"
-	OptionDebug          = "debug"
+	OptionHelp                 = "help"
+	OptionIPv4                 = "ipv4"
+	OptionIPv4Alt              = "4"
+	OptionIPv6                 = "ipv6"
+	OptionIPv6Alt              = "6"
+	OptionNoDownload           = "no-download"
+	OptionNoUpload             = "no-upload"
+	OptionPingOnly             = "ping-only"
+	OptionNoICMP               = "no-icmp"
+	OptionConcurrent           = "concurrent"
+	OptionConcurrentAlt        = "n"
+	OptionBytes                = "bytes"
+	OptionMebiBytes            = "mebibytes"
+	OptionUnit                 = "unit"
+	OptionSimple               = "simple"
+	OptionSimpleAlt            = "q"
+	OptionCSV                  = "csv"
+	OptionCSVDelimiter         = "csv-delimiter"
+	OptionCSVHeader            = "csv-header"
+	OptionJSON                 = "json"
+	OptionFormat               = "format"
+	OptionBrief                = "brief"
+	OptionJSONProgress         = "json-progress"
+	OptionStdioRPC             = "stdio-rpc"
+	OptionList                 = "list"
+	OptionListAlt              = "l"
+	OptionServer               = "server"
+	OptionServerAlt            = "s"
+	OptionServerGroup          = "group"
+	OptionServerGroupAlt       = "g"
+	OptionExclude              = "exclude"
+	OptionSource               = "source"
+	OptionInterface            = "interface"
+	OptionInterfaceAlt         = "i"
+	OptionTimeout              = "timeout"
+	OptionUploadSize           = "upload-size"
+	OptionDuration             = "duration"
+	OptionDurationAlt          = "t"
+	OptionNoPreAllocate        = "no-pre-allocate"
+	OptionStallTimeout         = "stall-timeout"
+	OptionBlobFile             = "blob-file"
+	OptionLowMemory            = "low-memory"
+	OptionAndroid              = "android"
+	OptionMemLimit             = "memlimit"
+	OptionSelectionConcurrency = "selection-concurrency"
+	OptionSelectionTimeout     = "selection-timeout"
+	OptionUpCheckTimeout       = "upcheck-timeout"
+	OptionSkipUpCheck          = "skip-upcheck"
+	OptionVersion              = "version"
+	OptionVersionAlt           = "v"
+	OptionCheckUpdate          = "update"
+	OptionCheckUpdateAlt       = "u"
+	OptionAPIBase              = "api-base"
+	OptionAPIVersion           = "api-version"
+	OptionProxy                = "proxy"
+	OptionTLSInsecure          = "tls-insecure"
+	OptionSelfTest             = "selftest"
+	OptionDebug                = "debug"
+	OptionLang                 = "lang"
+	OptionNoColor              = "no-color"
+	OptionPort                 = "port"
+	OptionPortAlt              = "p"
+	OptionBind                 = "bind"
+	OptionType                 = "type"
+	OptionServerType           = "server-type"
+	OptionListen               = "listen"
+	OptionPeer                 = "peer"
+	OptionLAN                  = "lan"
+	OptionProbe                = "probe"
+	OptionInterval             = "interval"
+	OptionCount                = "count"
+	OptionJitter               = "jitter"
+	OptionCrossTraffic         = "cross-traffic"
+	OptionCrossTrafficWait     = "cross-traffic-wait"
+	OptionWatch                = "watch"
+	OptionWindow               = "window"
+	OptionMonitor              = "monitor"
+	OptionHistory              = "history"
+	OptionNoHistory            = "no-history"
+	OptionVerifyAnomalies      = "verify-anomalies"
+	OptionVerifyServer         = "verify-server"
+	OptionPromTextfile         = "prom-textfile"
+	OptionSignKey              = "sign-key"
+	OptionShare                = "share"
+	OptionShareURL             = "share-url"
+	OptionPingMonitor          = "ping-monitor"
+	OptionPings                = "pings"
+	OptionStaircase            = "staircase"
+	OptionSteps                = "steps"
+	OptionRRUL                 = "rrul"
+	OptionRounds               = "rounds"
+	OptionMPTCP                = "mptcp"
+	OptionInterfaces           = "interfaces"
+	OptionNoMetadataUpdate     = "no-metadata-update"
+	OptionMetadataVersion      = "metadata-version"
+	OptionMetadataSHA256       = "metadata-sha256"
+	OptionRefresh              = "refresh"
+	OptionPeriod               = "period"
+	OptionFavorite             = "favorite"
+	OptionDownloadURL          = "download-url"
+	OptionUploadURL            = "upload-url"
+	OptionDownloadPath         = "download-path"
+	OptionUploadPath           = "upload-path"
+	OptionPingPath             = "ping-path"
+	OptionPingURL              = "ping-url"
+)
+
+// the values of --format, FormatLegacyCSV is the CSV layout of sivel/speedtest-cli
+const (
+	FormatLegacyCSV = "legacy-csv"
+	FormatJSONL     = "jsonl"
+	FormatCSV       = "csv"
+	FormatText      = "text"
+	FormatMarkdown  = "markdown"
+	FormatHTML      = "html"
 )
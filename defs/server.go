@@ -2,19 +2,19 @@ package defs
 
 import (
 	"context"
-	"crypto/rand"
 	"errors"
 	"fmt"
 	"io"
 	"math"
+	"net"
 	"net/http"
-	"os"
+	"strconv"
 	"strings"
 	"time"
 
-	"github.com/briandowns/spinner"
-	"github.com/go-ping/ping"
 	log "github.com/sirupsen/logrus"
+
+	"github.com/ztelliot/taierspeed-cli/i18n"
 )
 
 type ServerGlobal struct {
@@ -43,8 +43,13 @@ func (s *ServerGlobal) GetISP() *ISPInfo {
 	case "鹏博士":
 		return &DRPENG
 	default:
+		for suffix, id := range ISPSuffixes {
+			if isp, ok := ISPMap[id]; ok && strings.HasSuffix(s.Name, suffix) {
+				return isp
+			}
+		}
 		for _, isp := range ISPMap {
-			if strings.HasSuffix(s.Name, isp.Name) {
+			if isp.Name != "" && strings.HasSuffix(s.Name, isp.Name) {
 				return isp
 			}
 		}
@@ -58,8 +63,36 @@ const (
 	GlobalSpeed ServerType = iota
 	Perception
 	WirelessSpeed
+	// Custom is any plain HTTP endpoint given by absolute URIs
+	Custom
+	// IPerf3 is an iperf3 server, tested over its own protocol instead of HTTP
+	IPerf3
 )
 
+// String returns the name of the server type
+func (t ServerType) String() string {
+	switch t {
+	case GlobalSpeed:
+		return "GlobalSpeed"
+	case Perception:
+		return "Perception"
+	case WirelessSpeed:
+		return "WirelessSpeed"
+	case Custom:
+		return "Custom"
+	case IPerf3:
+		return "iperf3"
+	}
+	return fmt.Sprintf("ServerType(%d)", t)
+}
+
+// ServerTypeNames are the names of the server types emulated by `serve`
+var ServerTypeNames = map[string]ServerType{
+	"globalspeed":   GlobalSpeed,
+	"perception":    Perception,
+	"wirelessspeed": WirelessSpeed,
+}
+
 // Server represents a speed test server
 type Server struct {
 	ID          string     `json:"id"`
@@ -77,133 +110,146 @@ type Server struct {
 	PingURI     string     `json:"ping"`
 	Type        ServerType `json:"type"`
 	NoICMP      bool       `json:"-"`
+	// RateLimit caps the throughput of the download and upload tests in Mbps, unlimited when zero
+	RateLimit float64 `json:"-"`
+	// BufferSize is the size of the buffer of each download stream, DefaultCopyBufferSize is used when zero
+	BufferSize int `json:"-"`
+	// StallTimeout aborts a download or upload request which transferred nothing for this long, retried by its
+	// worker. Requests never stall out when zero
+	StallTimeout time.Duration `json:"-"`
+
+	// Client is the HTTP client for all requests to the server, http.DefaultClient is used when nil
+	Client *http.Client `json:"-"`
+	// Dialer for the connections to the server other than HTTP, a zero net.Dialer is used when nil
+	Dialer *net.Dialer `json:"-"`
+}
+
+// HTTPClient returns the HTTP client for requests to the server
+func (s *Server) HTTPClient() *http.Client {
+	if s.Client != nil {
+		return s.Client
+	}
+	return http.DefaultClient
+}
+
+// hostPort returns the HOST:PORT of the server, with IPv6 addresses in brackets
+func (s *Server) hostPort() string {
+	return net.JoinHostPort(s.Host, strconv.Itoa(int(s.Port)))
 }
 
 func (s *Server) DownloadURL() string {
-	if s.DownloadURI != "" {
-		return fmt.Sprintf("http://%s:%d%s", s.Host, s.Port, s.DownloadURI)
+	if isAbsolute(s.DownloadURI) {
+		return s.DownloadURI
+	} else if s.DownloadURI != "" {
+		return fmt.Sprintf("http://%s%s", s.hostPort(), s.DownloadURI)
 	} else {
 		switch s.Type {
 		case Perception:
-			return fmt.Sprintf("http://%s:%d/speedtest/download", s.Host, s.Port)
+			return fmt.Sprintf("http://%s/speedtest/download", s.hostPort())
 		case WirelessSpeed:
-			return fmt.Sprintf("http://%s:%d/GSpeedTestServer/download", s.Host, s.Port)
+			return fmt.Sprintf("http://%s/GSpeedTestServer/download", s.hostPort())
 		default:
-			return fmt.Sprintf("http://%s:%d/speed/File(1G).dl", s.Host, s.Port)
+			return fmt.Sprintf("http://%s/speed/File(1G).dl", s.hostPort())
 		}
 	}
 }
 
 func (s *Server) UploadURL() string {
-	if s.UploadURI != "" {
-		return fmt.Sprintf("http://%s:%d%s", s.Host, s.Port, s.UploadURI)
+	if isAbsolute(s.UploadURI) {
+		return s.UploadURI
+	} else if s.UploadURI != "" {
+		return fmt.Sprintf("http://%s%s", s.hostPort(), s.UploadURI)
 	} else {
 		switch s.Type {
 		case Perception:
-			return fmt.Sprintf("http://%s:%d/speedtest/upload", s.Host, s.Port)
+			return fmt.Sprintf("http://%s/speedtest/upload", s.hostPort())
 		case WirelessSpeed:
-			return fmt.Sprintf("http://%s:%d/GSpeedTestServer/upload", s.Host, s.Port)
+			return fmt.Sprintf("http://%s/GSpeedTestServer/upload", s.hostPort())
 		default:
-			return fmt.Sprintf("http://%s:%d/speed/doAnalsLoad.do", s.Host, s.Port)
+			return fmt.Sprintf("http://%s/speed/doAnalsLoad.do", s.hostPort())
 		}
 	}
 }
 
 func (s *Server) PingURL() string {
-	if s.PingURI != "" {
-		return fmt.Sprintf("http://%s:%d%s", s.Host, s.Port, s.PingURI)
+	if isAbsolute(s.PingURI) {
+		return s.PingURI
+	} else if s.PingURI != "" {
+		return fmt.Sprintf("http://%s%s", s.hostPort(), s.PingURI)
 	} else {
 		switch s.Type {
 		case Perception:
-			return fmt.Sprintf("http://%s:%d/speedtest/ping", s.Host, s.Port)
+			return fmt.Sprintf("http://%s/speedtest/ping", s.hostPort())
 		case WirelessSpeed:
-			return fmt.Sprintf("http://%s:%d/GSpeedTestServer/", s.Host, s.Port)
+			return fmt.Sprintf("http://%s/GSpeedTestServer/", s.hostPort())
 		default:
-			return fmt.Sprintf("http://%s:%d/speed/", s.Host, s.Port)
+			return fmt.Sprintf("http://%s/speed/", s.hostPort())
 		}
 	}
 }
 
-// IsUp checks the speed test backend is up by accessing the ping URL
-func (s *Server) IsUp() bool {
-	req, err := http.NewRequest(http.MethodGet, s.PingURL(), nil)
-	if err != nil {
-		log.Debugf("Failed when creating HTTP request: %s", err)
-		return false
-	}
+// IsUp checks the speed test backend is up by requesting the ping URL, giving up after `timeout`. A cheap HEAD request
+// is tried first, falling back to GET for servers which don't answer HEAD requests. The response body is never read
+func (s *Server) IsUp(ctx context.Context, timeout time.Duration) bool {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
 
-	req.Header.Set("User-Agent", AndroidUA)
+	if s.Type == IPerf3 {
+		if _, err := s.iperfPing(ctx); err != nil {
+			log.Debugf("Error checking for server status: %s", err)
+			return false
+		}
+		return true
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	up, err := s.checkStatus(ctx, http.MethodHead)
+	if up || ctx.Err() != nil {
+		return up
+	}
 	if err != nil {
+		log.Debugf("HEAD request for server status failed: %s, will try GET", err)
+	}
+	if up, err = s.checkStatus(ctx, http.MethodGet); err != nil {
 		log.Debugf("Error checking for server status: %s", err)
-		return false
 	}
-	defer resp.Body.Close()
-
-	// only return online if the ping URL returns nothing and 200
-	return (resp.StatusCode == http.StatusOK) || (resp.StatusCode == http.StatusForbidden)
+	return up
 }
 
-// ICMPPingAndJitter pings the server via ICMP echos and calculate the average ping and jitter
-func (s *Server) ICMPPingAndJitter(count int, srcIp, network string) (float64, float64, error) {
-	if s.NoICMP {
-		log.Debugf("Skipping ICMP for server %s, will use HTTP ping", s.Name)
-		return s.PingAndJitter(count + 2)
-	}
-
-	p, err := ping.NewPinger(s.Host)
+// checkStatus requests the ping URL with `method`, and checks the status shows the backend is up
+func (s *Server) checkStatus(ctx context.Context, method string) (bool, error) {
+	req, err := http.NewRequestWithContext(ctx, method, s.PingURL(), nil)
 	if err != nil {
-		log.Debugf("ICMP ping failed: %s, will use HTTP ping", err)
-		return s.PingAndJitter(count + 2)
-	}
-	p.SetPrivileged(true)
-	p.SetNetwork(network)
-	p.Count = count
-	p.Timeout = time.Duration(count) * time.Second
-	if srcIp != "" {
-		p.Source = srcIp
-	}
-	if log.GetLevel() == log.DebugLevel {
-		p.Debug = true
-	}
-	if err := p.Run(); err != nil {
-		log.Debugf("Failed to ping target host: %s", err)
-		log.Debug("Will try TCP ping")
-		return s.PingAndJitter(count + 2)
+		return false, err
 	}
 
-	stats := p.Statistics()
+	req.Header.Set("User-Agent", AndroidUA)
 
-	var lastPing, jitter float64
-	for idx, rtt := range stats.Rtts {
-		if idx != 0 {
-			instJitter := math.Abs(lastPing - float64(rtt.Milliseconds()))
-			if idx > 1 {
-				if jitter > instJitter {
-					jitter = jitter*0.7 + instJitter*0.3
-				} else {
-					jitter = instJitter*0.2 + jitter*0.8
-				}
-			}
-		}
-		lastPing = float64(rtt.Milliseconds())
+	resp, err := s.HTTPClient().Do(req)
+	if err != nil {
+		return false, err
 	}
+	resp.Body.Close()
 
-	if len(stats.Rtts) == 0 {
-		s.NoICMP = true
-		log.Debugf("No ICMP pings returned for server %s (%s), trying TCP ping", s.Name, s.IP)
-		return s.PingAndJitter(count + 2)
+	// any response means a custom endpoint is reachable, as its ping URL is not known to exist
+	if s.Type == Custom {
+		return resp.StatusCode < http.StatusInternalServerError, nil
 	}
 
-	return float64(stats.AvgRtt.Milliseconds()), jitter, nil
+	// only return online if the ping URL returns 200 or 403
+	return (resp.StatusCode == http.StatusOK) || (resp.StatusCode == http.StatusForbidden), nil
+}
+
+// isAbsolute checks if a URI is an absolute HTTP URL
+func isAbsolute(uri string) bool {
+	return strings.HasPrefix(uri, "http://") || strings.HasPrefix(uri, "https://")
 }
 
-// PingAndJitter pings the server via accessing ping URL and calculate the average ping and jitter
-func (s *Server) PingAndJitter(count int) (float64, float64, error) {
+// PingAndJitter pings the server via accessing ping URL and calculate the average ping and jitter, `onPing` is called
+// with the RTT of every ping if not nil
+func (s *Server) PingAndJitter(ctx context.Context, count int, onPing func(float64)) (float64, float64, error) {
 	var pings []float64
 
-	req, err := http.NewRequest(http.MethodGet, s.PingURL(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.PingURL(), nil)
 	if err != nil {
 		log.Debugf("Failed when creating HTTP request: %s", err)
 		return 0, 0, err
@@ -212,16 +258,30 @@ func (s *Server) PingAndJitter(count int) (float64, float64, error) {
 	req.Header.Set("User-Agent", AndroidUA)
 
 	for i := 0; i < count; i++ {
-		start := time.Now()
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil {
-			log.Debugf("Failed when making HTTP request: %s", err)
-			return 0, 0, err
-		}
-		io.Copy(io.Discard, resp.Body)
-		resp.Body.Close()
+		if s.Type == IPerf3 {
+			// iperf3 servers are pinged by connecting to them
+			rtt, err := s.iperfPing(ctx)
+			if err != nil {
+				log.Debugf("Failed when connecting to iperf3 server: %s", err)
+				return 0, 0, err
+			}
+			pings = append(pings, rtt)
+		} else {
+			start := time.Now()
+			resp, err := s.HTTPClient().Do(req)
+			if err != nil {
+				log.Debugf("Failed when making HTTP request: %s", err)
+				return 0, 0, err
+			}
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
 
-		pings = append(pings, float64(time.Since(start).Milliseconds()))
+			pings = append(pings, float64(time.Since(start).Milliseconds()))
+		}
+		// the first result is discarded below
+		if onPing != nil && i > 0 {
+			onPing(pings[i])
+		}
 	}
 
 	// discard first result due to handshake overhead
@@ -247,177 +307,171 @@ func (s *Server) PingAndJitter(count int) (float64, float64, error) {
 	return getAvg(pings), jitter, nil
 }
 
-// Download performs the actual download test
-func (s *Server) Download(silent, useBytes, useMebi bool, requests int, duration time.Duration, token string) (float64, uint64, error) {
-	counter := NewCounter()
-	counter.SetMebi(useMebi)
+// Download performs the actual download test until `duration` elapsed or `ctx` is done, `progress` is called with the counter every SampleInterval if not nil
+func (s *Server) Download(ctx context.Context, requests int, duration time.Duration, token string, progress func(*BytesCounter)) (float64, uint64, error) {
+	if s.Type == IPerf3 {
+		return s.iperfTransfer(ctx, true, requests, duration, progress)
+	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	counter := NewCounter()
+	counter.SetRateLimit(s.RateLimit)
+	bufferSize := DefaultCopyBufferSize
+	if s.BufferSize > 0 {
+		bufferSize = s.BufferSize
+	}
 
 	url := s.DownloadURL()
 	if s.Type == GlobalSpeed {
 		url = fmt.Sprintf("%s?key=%s", url, token)
 	}
 
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		log.Debugf("Failed when creating HTTP request: %s", err)
-		return 0, 0, err
-	}
-
-	req.Header.Set("User-Agent", BrowserUA)
-	req.Header.Set("Accept", "*/*")
-	req.Header.Set("Connection", "close")
+	doDownload := func(ctx context.Context) error {
+		// every request is a connection of its own, so its lifetime is a stream
+		start := time.Now()
+		activity := newActivity()
+		defer func() { counter.addStream(activity.total.Load(), time.Since(start)) }()
+		reqCtx, stop := activity.watch(ctx, s.StallTimeout)
+		defer stop()
 
-	downloadDone := make(chan struct{}, requests)
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
+		if err != nil {
+			log.Debugf("Failed when creating HTTP request: %s", err)
+			return err
+		}
 
-	doDownload := func() {
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !os.IsTimeout(err) {
-			log.Debugf("Failed when making HTTP request: %s", err)
-		} else {
-			defer resp.Body.Close()
+		req.Header.Set("User-Agent", BrowserUA)
+		req.Header.Set("Accept", "*/*")
+		req.Header.Set("Connection", "close")
 
-			if _, err = io.Copy(io.Discard, io.TeeReader(resp.Body, counter)); err != nil {
-				if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !os.IsTimeout(err) {
-					log.Debugf("Failed when reading HTTP response: %s", err)
-				}
-			}
+		resp, err := s.HTTPClient().Do(req)
+		if err != nil {
+			return requestError(reqCtx, err, "Failed when making HTTP request: %s")
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			log.Debugf("Download request failed with %s", resp.Status)
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
+		// the Fetch API transport of js/wasm doesn't abort reading the body when the request is canceled
+		closeBody := context.AfterFunc(reqCtx, func() { resp.Body.Close() })
+		defer closeBody()
 
-			downloadDone <- struct{}{}
+		n, err := io.CopyBuffer(&streamWriter{w: counter, streamActivity: activity}, resp.Body, make([]byte, bufferSize))
+		if err != nil {
+			return requestError(reqCtx, err, "Failed when reading HTTP response: %s")
+		}
+		// an empty body is a failure, so the worker backs off instead of requesting again right away
+		if n == 0 {
+			log.Debug("Download request returned an empty response")
+			return errEmptyResponse
 		}
+		return nil
 	}
 
 	counter.Start()
-	if !silent {
-		pb := spinner.New(spinner.CharSets[11], 100*time.Millisecond)
-		pb.Prefix = "Downloading...  "
-		pb.PostUpdate = func(s *spinner.Spinner) {
-			if useBytes {
-				s.Suffix = fmt.Sprintf("  %s", counter.AvgHumanize())
-			} else {
-				s.Suffix = fmt.Sprintf("  %.2f Mbps", counter.AvgMbps())
-			}
-		}
+	if progress != nil {
+		defer sampleCounter(counter, progress)()
+	}
 
-		pb.Start()
-		defer func() {
-			if useBytes {
-				pb.FinalMSG = fmt.Sprintf("Download:\t%s\n (data used: %s)", counter.AvgHumanize(), counter.BytesHumanize())
-			} else {
-				pb.FinalMSG = fmt.Sprintf("Download:\t%.2f Mbps (data used: %.2f MB)\n", counter.AvgMbps(), counter.MBytes())
-			}
-			pb.Stop()
-		}()
-	}
-
-	for i := 0; i < requests; i++ {
-		go doDownload()
-		time.Sleep(200 * time.Millisecond)
-	}
-	timeout := time.After(duration)
-Loop:
-	for {
-		select {
-		case <-timeout:
-			ctx.Done()
-			break Loop
-		case <-downloadDone:
-			go doDownload()
-		}
+	stats := runWorkers(ctx, requests, duration, doDownload)
+	counter.stats = stats
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+	log.Debugf("Download workers: %d/%d alive, %d requests, %d failed", stats.Alive(), stats.Workers, stats.Requests, stats.Failures)
+	if rates := counter.StreamRates(); rates != nil {
+		log.Debugf("Download streams: %d, %.2f/%.2f/%.2f Mbps min/median/max", rates.Streams, rates.Min, rates.Median, rates.Max)
+	}
+	if stats.Alive() == 0 && (counter.Total() == 0 || stats.Requests == stats.Failures) {
+		return 0, 0, errors.New("all download requests failed")
+	} else if stats.Exited > 0 {
+		log.Warnf(i18n.T("%d of %d download workers gave up after failed requests, result might be lower than expected"), stats.Exited, stats.Workers)
+	}
+	if stats.Stalls > 0 {
+		log.Warnf(i18n.T("%d download requests stalled for %s and were restarted, result might be lower than expected"), stats.Stalls, s.StallTimeout)
 	}
 
 	return counter.AvgMbps(), counter.Total(), nil
 }
 
-// Upload performs the actual upload test
-func (s *Server) Upload(noPrealloc, silent, useBytes, useMebi bool, requests, uploadSize int, duration time.Duration, token string) (float64, uint64, error) {
+// Upload performs the actual upload test until `duration` elapsed or `ctx` is done, `progress` is called with the counter every SampleInterval if not nil
+func (s *Server) Upload(ctx context.Context, noPrealloc bool, requests, uploadSize int, duration time.Duration, token string, progress func(*BytesCounter)) (float64, uint64, error) {
+	if s.Type == IPerf3 {
+		return s.iperfTransfer(ctx, false, requests, duration, progress)
+	}
+
 	counter := NewCounter()
-	counter.SetMebi(useMebi)
 	counter.SetUploadSize(uploadSize)
+	counter.SetRateLimit(s.RateLimit)
 
 	if noPrealloc {
-		log.Info("Pre-allocation is disabled, performance might be lower!")
-		counter.reader = &SeekWrapper{rand.Reader}
+		log.Info(i18n.T("Pre-allocation is disabled, performance might be lower!"))
 	} else {
 		counter.GenerateBlob()
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
-
-	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.UploadURL(), counter)
-	if err != nil {
-		log.Debugf("Failed when creating HTTP request: %s", err)
-		return 0, 0, err
-	}
+	doUpload := func(ctx context.Context) error {
+		start := time.Now()
+		body := &streamReader{r: uploadBody(counter), streamActivity: newActivity()}
+		defer func() { counter.addStream(body.total.Load(), time.Since(start)) }()
+		reqCtx, stop := body.watch(ctx, s.StallTimeout)
+		defer stop()
 
-	req.Header.Set("User-Agent", AndroidUA)
-	if s.Type != WirelessSpeed {
-		req.Header.Set("Connection", "close")
-		req.Header.Set("Charset", "UTF-8")
-		req.Header.Set("Key", token)
-		req.Header.Set("Content-Type", "multipart/form-data;boundary=00content0boundary00")
-	} else {
-		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	}
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodPost, s.UploadURL(), body)
+		if err != nil {
+			log.Debugf("Failed when creating HTTP request: %s", err)
+			return err
+		}
 
-	uploadDone := make(chan struct{}, requests)
+		req.Header.Set("User-Agent", AndroidUA)
+		if s.Type == Custom {
+			req.Header.Set("Content-Type", "application/octet-stream")
+		} else if s.Type != WirelessSpeed {
+			req.Header.Set("Connection", "close")
+			req.Header.Set("Charset", "UTF-8")
+			req.Header.Set("Key", token)
+			req.Header.Set("Content-Type", "multipart/form-data;boundary=00content0boundary00")
+		} else {
+			req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		}
 
-	doUpload := func() {
-		resp, err := http.DefaultClient.Do(req)
-		if err != nil && !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !os.IsTimeout(err) {
-			log.Debugf("Failed when making HTTP request: %s", err)
-		} else if err == nil {
-			defer resp.Body.Close()
-			if _, err := io.Copy(io.Discard, resp.Body); err != nil {
-				if !errors.Is(err, context.Canceled) && !errors.Is(err, context.DeadlineExceeded) && !os.IsTimeout(err) {
-					log.Debugf("Failed when reading HTTP response: %s", err)
-				}
-			}
+		resp, err := s.HTTPClient().Do(req)
+		if err != nil {
+			return requestError(reqCtx, err, "Failed when making HTTP request: %s")
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= http.StatusBadRequest {
+			log.Debugf("Upload request failed with %s", resp.Status)
+			return fmt.Errorf("unexpected status %s", resp.Status)
+		}
 
-			uploadDone <- struct{}{}
+		if _, err = io.Copy(io.Discard, resp.Body); err != nil {
+			return requestError(reqCtx, err, "Failed when reading HTTP response: %s")
 		}
+		return nil
 	}
 
 	counter.Start()
-	if !silent {
-		pb := spinner.New(spinner.CharSets[11], 100*time.Millisecond)
-		pb.Prefix = "Uploading...  "
-		pb.PostUpdate = func(s *spinner.Spinner) {
-			if useBytes {
-				s.Suffix = fmt.Sprintf("  %s", counter.AvgHumanize())
-			} else {
-				s.Suffix = fmt.Sprintf("  %.2f Mbps", counter.AvgMbps())
-			}
-		}
+	if progress != nil {
+		defer sampleCounter(counter, progress)()
+	}
 
-		pb.Start()
-		defer func() {
-			if useBytes {
-				pb.FinalMSG = fmt.Sprintf("Upload:\t\t%s (data used: %s)\n", counter.AvgHumanize(), counter.BytesHumanize())
-			} else {
-				pb.FinalMSG = fmt.Sprintf("Upload:\t\t%.2f Mbps (data used: %.2f MB)\n", counter.AvgMbps(), counter.MBytes())
-			}
-			pb.Stop()
-		}()
-	}
-
-	for i := 0; i < requests; i++ {
-		go doUpload()
-		time.Sleep(200 * time.Millisecond)
-	}
-	timeout := time.After(duration)
-Loop:
-	for {
-		select {
-		case <-timeout:
-			ctx.Done()
-			break Loop
-		case <-uploadDone:
-			go doUpload()
-		}
+	stats := runWorkers(ctx, requests, duration, doUpload)
+	counter.stats = stats
+	if err := ctx.Err(); err != nil {
+		return 0, 0, err
+	}
+	log.Debugf("Upload workers: %d/%d alive, %d requests, %d failed", stats.Alive(), stats.Workers, stats.Requests, stats.Failures)
+	if rates := counter.StreamRates(); rates != nil {
+		log.Debugf("Upload streams: %d, %.2f/%.2f/%.2f Mbps min/median/max", rates.Streams, rates.Min, rates.Median, rates.Max)
+	}
+	if stats.Alive() == 0 && (counter.Total() == 0 || stats.Requests == stats.Failures) {
+		return 0, 0, errors.New("all upload requests failed")
+	} else if stats.Exited > 0 {
+		log.Warnf(i18n.T("%d of %d upload workers gave up after failed requests, result might be lower than expected"), stats.Exited, stats.Workers)
+	}
+	if stats.Stalls > 0 {
+		log.Warnf(i18n.T("%d upload requests stalled for %s and were restarted, result might be lower than expected"), stats.Stalls, s.StallTimeout)
 	}
 
 	return counter.AvgMbps(), counter.Total(), nil
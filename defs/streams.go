@@ -0,0 +1,147 @@
+package defs
+
+import (
+	"context"
+	"errors"
+	"io"
+	"math"
+	"sort"
+	"sync/atomic"
+	"time"
+)
+
+// StreamRates is the distribution of the throughput of the single streams of a transfer in Mbps, a wide spread or a
+// low maximum reveals per-flow policing which the aggregate throughput hides
+type StreamRates struct {
+	Streams int     `json:"streams"`
+	Min     float64 `json:"min"`
+	Median  float64 `json:"median"`
+	Max     float64 `json:"max"`
+}
+
+// stream is the bytes transferred by a single connection of a transfer during its lifetime
+type stream struct {
+	bytes    uint64
+	duration time.Duration
+}
+
+// addStream records a finished stream of the transfer, streams which transferred nothing are left out
+func (c *BytesCounter) addStream(bytes uint64, duration time.Duration) {
+	if bytes == 0 || duration <= 0 {
+		return
+	}
+	c.lock.Lock()
+	c.streams = append(c.streams, stream{bytes: bytes, duration: duration})
+	c.lock.Unlock()
+}
+
+// StreamRates returns the distribution of the per-stream throughput, nil if no stream finished yet
+func (c *BytesCounter) StreamRates() *StreamRates {
+	c.lock.Lock()
+	rates := make([]float64, len(c.streams))
+	for i, s := range c.streams {
+		rates[i] = float64(s.bytes) / 125000 / s.duration.Seconds()
+	}
+	c.lock.Unlock()
+	if len(rates) == 0 {
+		return nil
+	}
+
+	sort.Float64s(rates)
+	median := rates[len(rates)/2]
+	if len(rates)%2 == 0 {
+		median = (rates[len(rates)/2-1] + median) / 2
+	}
+	return &StreamRates{
+		Streams: len(rates),
+		Min:     roundRate(rates[0]),
+		Median:  roundRate(median),
+		Max:     roundRate(rates[len(rates)-1]),
+	}
+}
+
+// errStalled is the cause of canceling a request which transferred nothing for the stall timeout
+var errStalled = errors.New("stalled")
+
+// streamActivity counts the bytes of a single request of a transfer, and when it transferred any last
+type streamActivity struct {
+	total atomic.Uint64
+	last  atomic.Int64
+}
+
+// newActivity returns the activity of a request starting now
+func newActivity() *streamActivity {
+	a := &streamActivity{}
+	a.last.Store(time.Now().UnixNano())
+	return a
+}
+
+// add records `n` bytes transferred now
+func (a *streamActivity) add(n int) {
+	if n > 0 {
+		a.total.Add(uint64(n))
+		a.last.Store(time.Now().UnixNano())
+	}
+}
+
+// watch returns a context for the request which is canceled with errStalled once nothing is transferred for
+// `timeout`, never if `timeout` is zero. The returned function stops watching
+func (a *streamActivity) watch(ctx context.Context, timeout time.Duration) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	if timeout <= 0 {
+		return ctx, func() { cancel(nil) }
+	}
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(max(timeout/4, 10*time.Millisecond))
+		defer ticker.Stop()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if time.Since(time.Unix(0, a.last.Load())) >= timeout {
+					cancel(errStalled)
+					return
+				}
+			}
+		}
+	}()
+	return ctx, func() {
+		close(done)
+		cancel(nil)
+	}
+}
+
+// streamReader counts the bytes read from the body of a single upload request
+type streamReader struct {
+	r io.Reader
+	*streamActivity
+}
+
+// Read implements io.Reader
+func (s *streamReader) Read(p []byte) (int, error) {
+	n, err := s.r.Read(p)
+	s.add(n)
+	return n, err
+}
+
+// streamWriter counts the bytes written by a single download request
+type streamWriter struct {
+	w io.Writer
+	*streamActivity
+}
+
+// Write implements io.Writer
+func (s *streamWriter) Write(p []byte) (int, error) {
+	n, err := s.w.Write(p)
+	s.add(n)
+	return n, err
+}
+
+// roundRate rounds a rate to 2 decimal places like the results
+func roundRate(v float64) float64 {
+	return math.Round(v*100) / 100
+}
@@ -0,0 +1,34 @@
+package defs
+
+import (
+	"time"
+)
+
+// TrafficSample is a snapshot of the bytes received and sent by all network interfaces except loopback ones
+type TrafficSample struct {
+	time time.Time
+	rx   uint64
+	tx   uint64
+}
+
+// TrafficRate represents the traffic of the network interfaces between two samples in Mbps, Download is the received
+// and Upload the sent traffic
+type TrafficRate struct {
+	Download float64 `json:"download"`
+	Upload   float64 `json:"upload"`
+}
+
+// RateSince returns the traffic between `prev` and this sample
+func (s *TrafficSample) RateSince(prev *TrafficSample) TrafficRate {
+	var rate TrafficRate
+	if wall := s.time.Sub(prev.time).Seconds(); wall > 0 {
+		// counters may be reset meanwhile, e.g. when an interface goes down
+		if s.rx >= prev.rx {
+			rate.Download = float64(s.rx-prev.rx) * 8 / 1000000 / wall
+		}
+		if s.tx >= prev.tx {
+			rate.Upload = float64(s.tx-prev.tx) * 8 / 1000000 / wall
+		}
+	}
+	return rate
+}
@@ -0,0 +1,38 @@
+package defs
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SampleTraffic takes a snapshot of the bytes received and sent by all network interfaces except loopback ones, from
+// the link level statistics of netstat
+func SampleTraffic() (*TrafficSample, error) {
+	out, err := exec.Command("netstat", "-ibn").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	sample := &TrafficSample{time: time.Now()}
+	var links int
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		// Name Mtu Network Address Ipkts Ierrs Ibytes Opkts Oerrs Obytes Coll, the address is empty for some links
+		if len(fields) < 10 || !strings.HasPrefix(fields[2], "<Link#") || strings.HasPrefix(fields[0], "lo") {
+			continue
+		}
+		ibytes, obytes := fields[len(fields)-5], fields[len(fields)-2]
+		rx, _ := strconv.ParseUint(ibytes, 10, 64)
+		tx, _ := strconv.ParseUint(obytes, 10, 64)
+		sample.rx += rx
+		sample.tx += tx
+		links++
+	}
+	if links == 0 {
+		return nil, errors.New("no links in the output of netstat")
+	}
+	return sample, nil
+}
@@ -0,0 +1,38 @@
+package defs
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SampleTraffic takes a snapshot of the bytes received and sent by all network interfaces except loopback ones
+func SampleTraffic() (*TrafficSample, error) {
+	dev, err := os.ReadFile("/proc/net/dev")
+	if err != nil {
+		return nil, err
+	}
+
+	sample := &TrafficSample{time: time.Now()}
+	scanner := bufio.NewScanner(bytes.NewReader(dev))
+	for scanner.Scan() {
+		// the header lines have no colon after the interface name
+		name, counters, ok := strings.Cut(scanner.Text(), ":")
+		if !ok || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			return nil, errors.New("malformed /proc/net/dev")
+		}
+		rx, _ := strconv.ParseUint(fields[0], 10, 64)
+		tx, _ := strconv.ParseUint(fields[8], 10, 64)
+		sample.rx += rx
+		sample.tx += tx
+	}
+	return sample, nil
+}
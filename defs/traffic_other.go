@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !windows
+
+package defs
+
+import (
+	"errors"
+)
+
+// SampleTraffic takes a snapshot of the bytes received and sent by all network interfaces, only available for linux,
+// darwin and windows
+func SampleTraffic() (*TrafficSample, error) {
+	return nil, errors.New("traffic sampling is not supported on this platform")
+}
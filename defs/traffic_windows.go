@@ -0,0 +1,36 @@
+package defs
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SampleTraffic takes a snapshot of the bytes received and sent by all network interfaces, from the Ethernet
+// statistics of netstat
+func SampleTraffic() (*TrafficSample, error) {
+	out, err := exec.Command("netstat", "-e").Output()
+	if err != nil {
+		return nil, err
+	}
+
+	// the labels are localized, bytes are the first row of received and sent counters
+	for _, line := range strings.Split(string(out), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) != 3 {
+			continue
+		}
+		rx, err := strconv.ParseUint(fields[1], 10, 64)
+		if err != nil {
+			continue
+		}
+		tx, err := strconv.ParseUint(fields[2], 10, 64)
+		if err != nil {
+			continue
+		}
+		return &TrafficSample{time: time.Now(), rx: rx, tx: tx}, nil
+	}
+	return nil, errors.New("no byte counters in the output of netstat")
+}
@@ -0,0 +1,46 @@
+package defs
+
+import (
+	"strings"
+)
+
+var (
+	// tunnelPrefixes are the prefixes of the names of tunnel interfaces, e.g. tun0, wg0 or utun3. PPP interfaces are
+	// left out, they are mostly PPPoE links of broadband plans
+	tunnelPrefixes = []string{"tun", "tap", "wg", "utun", "ipsec", "zt", "gpd", "cscotun"}
+	// tunnelKeywords are found in the names of tunnel interfaces of common VPNs, including the friendly names on
+	// windows
+	tunnelKeywords = []string{"tailscale", "wireguard", "zerotier", "nordlynx", "mullvad", "proton", "openvpn", "vpn"}
+	// hostingKeywords are found in the ISP names of clouds and hosting providers, where VPN and proxy servers are run
+	hostingKeywords = []string{"阿里云", "腾讯云", "华为云", "amazon", "aws", "google", "microsoft", "azure", "digitalocean",
+		"linode", "akamai", "vultr", "choopa", "ovh", "hetzner", "m247", "datacamp", "cloudflare", "oracle"}
+)
+
+// IsTunnel checks if the interface named `iface` is a tunnel, the results of tests through it measure the path of the
+// tunnel
+func IsTunnel(iface string) bool {
+	name := strings.ToLower(iface)
+	for _, p := range tunnelPrefixes {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	for _, k := range tunnelKeywords {
+		if strings.Contains(name, k) {
+			return true
+		}
+	}
+	return false
+}
+
+// IsHostingISP checks if `isp` is a cloud or hosting provider, a public IP of one of them on a client usually means
+// its traffic goes through a VPN or proxy
+func IsHostingISP(isp string) bool {
+	name := strings.ToLower(isp)
+	for _, k := range hostingKeywords {
+		if strings.Contains(name, k) {
+			return true
+		}
+	}
+	return false
+}
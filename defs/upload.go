@@ -0,0 +1,12 @@
+//go:build !js
+
+package defs
+
+import (
+	"io"
+)
+
+// uploadBody returns the body of an upload request, which is streamed until the request is canceled
+func uploadBody(c *BytesCounter) io.Reader {
+	return c.NewReader()
+}
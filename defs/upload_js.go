@@ -0,0 +1,13 @@
+//go:build js
+
+package defs
+
+import (
+	"io"
+)
+
+// uploadBody returns the body of an upload request, limited to the upload size as the Fetch API transport reads the
+// whole body into memory before sending it
+func uploadBody(c *BytesCounter) io.Reader {
+	return io.LimitReader(c.NewReader(), int64(c.uploadSize))
+}
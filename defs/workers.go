@@ -0,0 +1,134 @@
+package defs
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+)
+
+const (
+	// the number of consecutive failed requests after which a transfer worker gives up
+	maxWorkerFailures = 3
+	// the delay between starting two workers, and between retries of a failed worker
+	workerInterval = 200 * time.Millisecond
+)
+
+// errEmptyResponse is returned by transfers which got no data, to be retried after workerInterval like other failures
+var errEmptyResponse = errors.New("empty response")
+
+// WorkerStats accounts the requests made by the transfer workers of a test phase
+type WorkerStats struct {
+	Workers  int
+	Requests int
+	Failures int
+	// Stalls are the failed requests which were aborted as nothing was transferred for the stall timeout
+	Stalls int
+	Exited int
+
+	lock sync.Mutex
+}
+
+// runWorkers starts a fixed pool of `n` workers, each one calling `fn` in a loop until `duration` elapsed after all
+// workers are started, or `ctx` is done. A worker exits early when `fn` fails too many times in a row
+func runWorkers(ctx context.Context, n int, duration time.Duration, fn func(ctx context.Context) error) *WorkerStats {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stats := &WorkerStats{Workers: n}
+	var wg sync.WaitGroup
+
+	worker := func(id int) {
+		defer wg.Done()
+
+		failures := 0
+		for ctx.Err() == nil {
+			err := fn(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+
+			stats.lock.Lock()
+			stats.Requests++
+			if err != nil {
+				stats.Failures++
+			}
+			if errors.Is(err, errStalled) {
+				stats.Stalls++
+			}
+			stats.lock.Unlock()
+
+			if err == nil {
+				failures = 0
+				continue
+			}
+
+			if failures++; failures >= maxWorkerFailures {
+				log.Debugf("Worker %d exited after %d failed requests: %s", id, failures, err)
+				stats.lock.Lock()
+				stats.Exited++
+				stats.lock.Unlock()
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+			case <-time.After(workerInterval):
+			}
+		}
+	}
+
+	for i := 0; i < n && ctx.Err() == nil; i++ {
+		wg.Add(1)
+		go worker(i)
+		select {
+		case <-ctx.Done():
+		case <-time.After(workerInterval):
+		}
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		if ctx.Err() == nil {
+			log.Debug("All workers exited before the end of the test")
+		}
+	case <-ctx.Done():
+		<-done
+	case <-time.After(duration):
+		cancel()
+		<-done
+	}
+
+	return stats
+}
+
+// Alive returns the number of workers still running when the phase ended
+func (w *WorkerStats) Alive() int {
+	return w.Workers - w.Exited
+}
+
+// isCanceled checks if an error is caused by the end of the test phase
+func isCanceled(err error) bool {
+	return errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) || os.IsTimeout(err)
+}
+
+// requestError returns the error of a transfer request, which is errStalled if the request was aborted for stalling.
+// Other errors are logged with `format` unless they are caused by the end of the test phase
+func requestError(ctx context.Context, err error, format string) error {
+	if errors.Is(context.Cause(ctx), errStalled) {
+		return errStalled
+	}
+	if !isCanceled(err) {
+		log.Debugf(format, err)
+	}
+	return err
+}
@@ -0,0 +1,96 @@
+package i18n
+
+import (
+	"os"
+	"strings"
+)
+
+// Lang is the language of user-facing messages
+type Lang string
+
+const (
+	// En is English, the language the messages are written in
+	En Lang = "en"
+	// Zh is Simplified Chinese
+	Zh Lang = "zh"
+)
+
+var current = En
+
+// Set sets the language of user-facing messages, `auto` or an empty string detects it from the locale
+func Set(lang string) bool {
+	switch Lang(lang) {
+	case En, Zh:
+		current = Lang(lang)
+	case "", "auto":
+		current = Detect()
+	default:
+		return false
+	}
+	return true
+}
+
+// Current returns the language of user-facing messages
+func Current() Lang {
+	return current
+}
+
+// Detect returns the language of the locale given by the environment, English if it's not Chinese
+func Detect() Lang {
+	for _, env := range []string{"LC_ALL", "LC_MESSAGES", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			if strings.HasPrefix(strings.ToLower(v), "zh") {
+				return Zh
+			}
+			return En
+		}
+	}
+	return En
+}
+
+// T returns the translation of the English message `msg`, which can be a format string. The message is returned
+// as is if it has no translation
+func T(msg string) string {
+	if current == Zh {
+		if s, ok := zh[msg]; ok {
+			return s
+		}
+	}
+	return msg
+}
+
+// Name returns the English name of the Chinese place or ISP name `name`, names of servers ending with an ISP are
+// translated in parts. The name is returned as is in Chinese or if it's unknown
+func Name(name string) string {
+	if current == Zh || name == "" {
+		return name
+	}
+	if s, ok := names[name]; ok {
+		return s
+	}
+	if s, ok := ispNames[name]; ok {
+		return s
+	}
+	for isp, s := range ispNames {
+		if prefix, ok := strings.CutSuffix(name, isp); ok && prefix != "" {
+			if p, ok := names[prefix]; ok {
+				return p + " " + s
+			}
+		}
+	}
+	return name
+}
+
+// Place returns the translated names joined as a place, e.g. the province and ISP of a server
+func Place(parts ...string) string {
+	var names []string
+	for _, p := range parts {
+		if p != "" {
+			names = append(names, Name(p))
+		}
+	}
+	if current == Zh {
+		return strings.Join(names, "")
+	}
+	return strings.Join(names, ", ")
+}
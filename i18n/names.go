@@ -0,0 +1,118 @@
+package i18n
+
+// names are the English names of countries, provinces and their capitals
+var names = map[string]string{
+	"中国":       "China",
+	"北京":       "Beijing",
+	"北京市":      "Beijing",
+	"天津":       "Tianjin",
+	"天津市":      "Tianjin",
+	"河北":       "Hebei",
+	"河北省":      "Hebei",
+	"石家庄":      "Shijiazhuang",
+	"山西":       "Shanxi",
+	"山西省":      "Shanxi",
+	"太原":       "Taiyuan",
+	"内蒙古":      "Inner Mongolia",
+	"内蒙古自治区":   "Inner Mongolia",
+	"呼和浩特":     "Hohhot",
+	"辽宁":       "Liaoning",
+	"辽宁省":      "Liaoning",
+	"沈阳":       "Shenyang",
+	"大连":       "Dalian",
+	"吉林":       "Jilin",
+	"吉林省":      "Jilin",
+	"长春":       "Changchun",
+	"黑龙江":      "Heilongjiang",
+	"黑龙江省":     "Heilongjiang",
+	"哈尔滨":      "Harbin",
+	"上海":       "Shanghai",
+	"上海市":      "Shanghai",
+	"江苏":       "Jiangsu",
+	"江苏省":      "Jiangsu",
+	"南京":       "Nanjing",
+	"苏州":       "Suzhou",
+	"浙江":       "Zhejiang",
+	"浙江省":      "Zhejiang",
+	"杭州":       "Hangzhou",
+	"宁波":       "Ningbo",
+	"安徽":       "Anhui",
+	"安徽省":      "Anhui",
+	"合肥":       "Hefei",
+	"福建":       "Fujian",
+	"福建省":      "Fujian",
+	"福州":       "Fuzhou",
+	"厦门":       "Xiamen",
+	"江西":       "Jiangxi",
+	"江西省":      "Jiangxi",
+	"南昌":       "Nanchang",
+	"山东":       "Shandong",
+	"山东省":      "Shandong",
+	"济南":       "Jinan",
+	"青岛":       "Qingdao",
+	"河南":       "Henan",
+	"河南省":      "Henan",
+	"郑州":       "Zhengzhou",
+	"湖北":       "Hubei",
+	"湖北省":      "Hubei",
+	"武汉":       "Wuhan",
+	"湖南":       "Hunan",
+	"湖南省":      "Hunan",
+	"长沙":       "Changsha",
+	"广东":       "Guangdong",
+	"广东省":      "Guangdong",
+	"广州":       "Guangzhou",
+	"深圳":       "Shenzhen",
+	"广西":       "Guangxi",
+	"广西壮族自治区":  "Guangxi",
+	"南宁":       "Nanning",
+	"海南":       "Hainan",
+	"海南省":      "Hainan",
+	"海口":       "Haikou",
+	"重庆":       "Chongqing",
+	"重庆市":      "Chongqing",
+	"四川":       "Sichuan",
+	"四川省":      "Sichuan",
+	"成都":       "Chengdu",
+	"贵州":       "Guizhou",
+	"贵州省":      "Guizhou",
+	"贵阳":       "Guiyang",
+	"云南":       "Yunnan",
+	"云南省":      "Yunnan",
+	"昆明":       "Kunming",
+	"西藏":       "Tibet",
+	"西藏自治区":    "Tibet",
+	"拉萨":       "Lhasa",
+	"陕西":       "Shaanxi",
+	"陕西省":      "Shaanxi",
+	"西安":       "Xi'an",
+	"甘肃":       "Gansu",
+	"甘肃省":      "Gansu",
+	"兰州":       "Lanzhou",
+	"青海":       "Qinghai",
+	"青海省":      "Qinghai",
+	"西宁":       "Xining",
+	"宁夏":       "Ningxia",
+	"宁夏回族自治区":  "Ningxia",
+	"银川":       "Yinchuan",
+	"新疆":       "Xinjiang",
+	"新疆维吾尔自治区": "Xinjiang",
+	"乌鲁木齐":     "Urumqi",
+	"台湾":       "Taiwan",
+	"台湾省":      "Taiwan",
+	"香港":       "Hong Kong",
+	"香港特别行政区":  "Hong Kong",
+	"澳门":       "Macao",
+	"澳门特别行政区":  "Macao",
+}
+
+// ispNames are the English names of ISPs
+var ispNames = map[string]string{
+	"电信":  "China Telecom",
+	"联通":  "China Unicom",
+	"移动":  "China Mobile",
+	"教育网": "CERNET",
+	"广电网": "China Broadnet",
+	"广电":  "China Broadnet",
+	"鹏博士": "Dr. Peng",
+}
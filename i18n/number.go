@@ -0,0 +1,78 @@
+package i18n
+
+import (
+	"os"
+	"strconv"
+	"strings"
+)
+
+// separators are the decimal and thousands separators of numbers in human output
+type separators struct {
+	decimal   string
+	thousands string
+}
+
+var (
+	// the separators of Chinese and English, also used for unknown locales
+	pointSeparators = separators{".", ","}
+	// localeSeparators are the separators of locales differing from them, by language
+	localeSeparators = map[string]separators{
+		"de": {",", "."},
+		"es": {",", "."},
+		"it": {",", "."},
+		"nl": {",", "."},
+		"pt": {",", "."},
+		"tr": {",", "."},
+		"da": {",", "."},
+		"id": {",", "."},
+		"fr": {",", " "},
+		"ru": {",", " "},
+		"uk": {",", " "},
+		"pl": {",", " "},
+		"cs": {",", " "},
+		"sv": {",", " "},
+		"fi": {",", " "},
+		"nb": {",", " "},
+	}
+)
+
+// numeric returns the separators of the numeric locale given by the environment
+func numeric() separators {
+	for _, env := range []string{"LC_ALL", "LC_NUMERIC", "LANG"} {
+		if v := os.Getenv(env); v != "" {
+			lang, _, _ := strings.Cut(strings.ToLower(v), "_")
+			if s, ok := localeSeparators[lang]; ok {
+				return s
+			}
+			return pointSeparators
+		}
+	}
+	return pointSeparators
+}
+
+var numberSeparators = numeric()
+
+// Number returns `val` with `decimals` decimals and grouped thousands, with the separators of the locale. It's for
+// human output only, machine formats should keep raw numbers
+func Number(val float64, decimals int) string {
+	s := strconv.FormatFloat(val, 'f', decimals, 64)
+	sign := ""
+	if strings.HasPrefix(s, "-") {
+		sign, s = "-", s[1:]
+	}
+	integer, fraction, _ := strings.Cut(s, ".")
+
+	var b strings.Builder
+	b.WriteString(sign)
+	for i, d := range integer {
+		if i > 0 && (len(integer)-i)%3 == 0 {
+			b.WriteString(numberSeparators.thousands)
+		}
+		b.WriteRune(d)
+	}
+	if fraction != "" {
+		b.WriteString(numberSeparators.decimal)
+		b.WriteString(fraction)
+	}
+	return b.String()
+}
@@ -0,0 +1,239 @@
+package i18n
+
+// zh are the Chinese translations of the messages, values are kept aligned to the same tab stops as in English
+var zh = map[string]string{
+	// test output
+	"Testing against %d servers: [ %s ]\n": "测试 %d 个服务器：[ %s ]\n",
+	"No server available":                  "没有可用的服务器",
+	"ISP:\t\t%s\n":                         "运营商：\t%s\n",
+	"Client:\t\t%s\n":                      "客户端：\t%s\n",
+	"Server:\t\t%s (id = %s)\n":            "服务器：\t%s (id = %s)\n",
+	"Location:\t%s\n":                      "位置：\t\t%s\n",
+	"Address:\t%s\n":                       "地址：\t\t%s\n",
+	"Type:\t\t%s\n":                        "类型：\t\t%s\n",
+	"Pinging...":                           "正在测试延迟...",
+	"Downloading...":                       "正在测试下载...",
+	"Uploading...":                         "正在测试上传...",
+	"  %s  current %s, average %s  %ds elapsed, %ds left": "  %s  当前 %s，平均 %s  已用 %d 秒，剩余 %d 秒",
+	"Probing...":                                      "正在测试丢包...",
+	"Latency:\t%s (%s jitter)\n":                      "延迟：\t\t%s（抖动 %s）\n",
+	"Download:\t%s (data used: %s)\n":                 "下载：\t\t%s（使用流量：%s）\n",
+	"Upload:\t\t%s (data used: %s)\n":                 "上传：\t\t%s（使用流量：%s）\n",
+	"Packet loss:\t%s\n":                              "丢包率：\t%s\n",
+	"Packet loss:\tunavailable\n":                     "丢包率：\t不可用\n",
+	"download":                                        "下载",
+	"upload":                                          "上传",
+	"Download test is disabled":                       "已禁用下载测试",
+	"Upload test is disabled":                         "已禁用上传测试",
+	"Retrieving server list":                          "正在获取服务器列表",
+	"%sSelecting the fastest server based on ping":    "%s正在根据延迟选择最快的服务器",
+	"%sSelection timed out, skipped %d of %d servers": "%s选择超时，跳过了 %d 个服务器（共 %d 个）",
+	"%sNo server is currently available":              "%s当前没有可用的服务器",
+	"Selected server %s (%s) is not responding at the moment, try again later": "所选服务器 %s (%s) 暂时无响应，请稍后再试",
+	"Get token failed": "获取测试令牌失败",
+	"Pre-allocation is disabled, performance might be lower!":                                                        "已禁用预分配，性能可能较低！",
+	"%d of %d download workers gave up after failed requests, result might be lower than expected":                   "%d 个下载线程（共 %d 个）因请求失败而退出，结果可能偏低",
+	"%d of %d upload workers gave up after failed requests, result might be lower than expected":                     "%d 个上传线程（共 %d 个）因请求失败而退出，结果可能偏低",
+	"CPU usage was %.0f%% during %s test, the result is likely limited by this device rather than the network":       "%[2]s测试期间 CPU 使用率达到 %.0[1]f%%，结果可能受限于本设备而非网络",
+	"Other traffic of %.2f Mbps down and %.2f Mbps up is running, the result might be lower than expected":           "存在下行 %.2f Mbps、上行 %.2f Mbps 的其他流量，结果可能偏低",
+	"Waiting for other traffic of %.2f Mbps down and %.2f Mbps up to settle":                                         "正在等待下行 %.2f Mbps、上行 %.2f Mbps 的其他流量平息",
+	"The link of %s negotiated only %d Mbps, check the cable and the port if your plan is faster":                    "%s 的链路仅协商到 %d Mbps，如果您的套餐更快，请检查网线和端口",
+	"The result is close to the link speed of %s (%d Mbps), it's likely limited by the link rather than the network": "结果接近 %s 的链路速率（%d Mbps），可能受限于链路而非网络",
+	"The server is reached through the tunnel %s, the result measures the path of the VPN":                           "通过隧道 %s 连接服务器，结果测量的是 VPN 的路径",
+	"The public IP %s belongs to %s, a hosting provider, the results likely measure the path of a VPN or proxy":      "公网 IP %s 属于托管服务商 %s，结果可能测量的是 VPN 或代理的路径",
+	"Confidence:\t%s\n":                   "可信度：\t%s\n",
+	"unstable throughput":                 "速度不稳定",
+	"short test":                          "测试时间过短",
+	"failed requests":                     "请求失败",
+	"CPU-bound":                           "受 CPU 限制",
+	"other traffic":                       "存在其他流量",
+	"limited by the link":                 "受链路速率限制",
+	"tunnel":                              "隧道",
+	"Unknown output format: %s is given":  "未知的输出格式：给定的是 %s",
+	"Failed to read the history: %s":      "读取历史记录失败：%s",
+	"Failed to write the history: %s":     "写入历史记录失败：%s",
+	"No file to import is given":          "未指定要导入的文件",
+	"Failed to read %s: %s":               "读取 %s 失败：%s",
+	"Imported %d of %d records from %s\n": "已从 %[3]s 导入 %[1]d 条记录（共 %[2]d 条）\n",
+	"No key is given with --%s":           "未通过 --%s 指定密钥",
+	"Failed to sign the report: %s":       "签名报告失败：%s",
+	"Invalid key in %s: %s":               "%s 中的密钥无效：%s",
+	"%s is not signed":                    "%s 未签名",
+	"The signature of %s doesn't match, the report was modified or signed with another key": "%s 的签名不匹配，报告已被修改或使用了其他密钥签名",
+	"Failed to verify %s: %s":                                        "验证 %s 失败：%s",
+	"%s is signed with the %s key %s\n":                              "%s 已使用 %s 密钥 %s 签名\n",
+	"%s is signed with the %s key\n":                                 "%s 已使用 %s 密钥签名\n",
+	"Rounds must be at least 2: %d is given":                         "轮数至少为 2：给定的是 %d",
+	"Comparing needs exactly 2 servers: %d are given":                "比较需要恰好 2 个服务器：给定的是 %d 个",
+	"Server %s:\t%s (id = %s)\n":                                     "服务器 %s：\t%s (id = %s)\n",
+	"Round %d/%d %s:\t%s\n":                                          "第 %d/%d 轮 %s：\t%s\n",
+	"no significant difference":                                      "无显著差异",
+	"B is better by %s%%":                                            "B 比 A 好 %s%%",
+	"B is worse by %s%%":                                             "B 比 A 差 %s%%",
+	"Ping:\t\tA %s, B %s, %s (p = %s)\n":                             "延迟：\t\tA %s，B %s，%s（p = %s）\n",
+	"Jitter:\t\tA %s, B %s, %s (p = %s)\n":                           "抖动：\t\tA %s，B %s，%s（p = %s）\n",
+	"Download:\tA %s, B %s, %s (p = %s)\n":                           "下载：\t\tA %s，B %s，%s（p = %s）\n",
+	"Upload:\t\tA %s, B %s, %s (p = %s)\n":                           "上传：\t\tA %s，B %s，%s（p = %s）\n",
+	"MPTCP:\t\t%s\n":                                                 "MPTCP：\t\t%s\n",
+	"not used, the kernel or the server doesn't support it":          "未使用，内核或服务器不支持",
+	"%d of %d connections, %d using multiple paths":                  "%d 个连接（共 %d 个），%d 个使用了多路径",
+	"Unknown interface: %s is given":                                 "未知的网络接口：给定的是 %s",
+	"\nInterface:\t%s\n":                                             "\n网络接口：\t%s\n",
+	"Server %s (%s) is not reachable through %s, skipping":           "无法通过 %[3]s 访问服务器 %[1]s (%[2]s)，跳过",
+	"Test through %s failed: %s":                                     "通过 %s 测试失败：%s",
+	"Interface\tPing\t\tJitter\t\tDownload\tUpload":                  "网络接口\t延迟\t\t抖动\t\t下载\t\t上传",
+	"Failed to load the pinned metadata: %s":                         "加载固定版本的元数据失败：%s",
+	"Invalid metadata version: %s is given":                          "无效的元数据版本：给定的是 %s",
+	"Metadata checksum must be a SHA-256 in hex: %s is given":        "元数据校验和必须是十六进制的 SHA-256：给定的是 %s",
+	"Servers as of %s\n":                                             "服务器列表更新于 %s\n",
+	"Code\tName\t\tServers":                                          "代码\t名称\t\t服务器数",
+	"Code\tASN\tName\t\tServers":                                     "代码\tASN\t名称\t\t服务器数",
+	"Failed to read the server aliases: %s":                          "读取服务器别名失败：%s",
+	"Failed to write the server aliases: %s":                         "写入服务器别名失败：%s",
+	"An alias and a server ID are needed: %q is given":               "需要别名和服务器 ID：给定的是 %q",
+	"Alias %s is not assigned":                                       "别名 %s 未设置",
+	"Failed to read the favorite servers: %s":                        "读取收藏的服务器失败：%s",
+	"Failed to write the favorite servers: %s":                       "写入收藏的服务器失败：%s",
+	"No favorite server is added, add one with the favorite command": "没有收藏的服务器，请使用 favorite 命令添加",
+	"No server is given":                                             "未给定服务器",
+	"Server %s is not a favorite":                                    "服务器 %s 未被收藏",
+	"DNS:\t\t%s ms\n":                                                "DNS 解析:\t%s ms\n",
+	"Up check timeout must be positive: %s seconds is given":         "服务器可用性检查的超时时间必须大于 0：给定的是 %s 秒",
+	"Stall timeout cannot be negative: %d is given":                  "停滞超时时间不能为负数：给定的是 %d",
+	"%d download requests stalled for %s and were restarted, result might be lower than expected": "%d 个下载请求停滞 %s 后被重启，结果可能低于预期",
+	"%d upload requests stalled for %s and were restarted, result might be lower than expected":   "%d 个上传请求停滞 %s 后被重启，结果可能低于预期",
+	"Unknown period: %s is given":              "未知的时间段：给定的是 %s",
+	"No results in the history of the last %s": "最近%s的历史记录中没有结果",
+	"Speed test report of the last %s":         "最近%s的测速报告",
+	"%s to %s, %d tests":                       "%s 至 %s，共 %d 次测试",
+	"week":                                     "一周",
+	"month":                                    "一个月",
+	"Overview":                                 "概览",
+	"Average":                                  "平均",
+	"Median":                                   "中位数",
+	"5th percentile":                           "第 5 百分位",
+	"95th percentile":                          "第 95 百分位",
+	"Min":                                      "最低",
+	"Max":                                      "最高",
+	"Daily averages":                           "每日平均",
+	"Date":                                     "日期",
+	"Tests":                                    "测试次数",
+	"Worst hours of the day":                   "一天中最差的时段",
+	"Hour":                                     "时段",
+	"Servers":                                  "服务器",
+	"Server":                                   "服务器",
+	"Anomaly threshold cannot be negative: %s%% is given": "异常阈值不能为负数：给定的是 %s%%",
+	"--%s needs --%s": "--%s 需要 --%s",
+	"%s worse than the median of the last %d results by more than %s%%, testing again": "%s比最近 %d 次结果的中位数差 %s%% 以上，正在重新测试",
+	"Can't test again against %s: %s":                                                  "无法对 %s 重新测试：%s",
+	"not verified":                                                                     "未验证",
+	"reproduced against %s":                                                            "在 %s 上复现",
+	"not reproduced against %s, likely a one-off":                                      "在 %s 上未复现，可能是偶然现象",
+	"Anomaly:\t%s\n":                                                                   "异常：\t\t%s\n",
+	"Failed to write the Prometheus textfile: %s":                                      "写入 Prometheus 文本文件失败：%s",
+	"Prometheus textfile must end with .prom: %s is given":                             "Prometheus 文本文件必须以 .prom 结尾：给定的是 %s",
+	"--%s is only available for linux":                                                 "--%s 仅适用于 linux",
+	"not judged, too few results of %s in the history":                                 "未判断，历史记录中 %s 的结果太少",
+	"CPU usage was %.0f%% of a core during %s test, the result is likely limited by this device rather than the network": "%[2]s测试期间单个 CPU 核心使用率达到 %.0[1]f%%，结果可能受限于本设备而非网络",
+	"Failed to get ping and jitter: %s":   "获取延迟和抖动失败：%s",
+	"Failed to get packet loss: %s":       "获取丢包率失败：%s",
+	"Failed to get download speed: %s":    "获取下载速度失败：%s",
+	"Failed to get upload speed: %s":      "获取上传速度失败：%s",
+	"Failed to generate random data: %s":  "生成随机数据失败：%s",
+	"Error generating CSV report: %s":     "生成 CSV 报告出错：%s",
+	"Error generating JSON report: %s":    "生成 JSON 报告出错：%s",
+	"Error when fetching server list: %s": "获取服务器列表出错：%s",
+	"Error when parsing server list: %s":  "解析服务器列表出错：%s",
+	"Terminated due to error":             "因错误而终止",
+
+	// probe and watch modes
+	"Probing %d servers every %s":                   "每 %[2]s 探测 %[1]d 个服务器",
+	"  %s: %s (%s) %s, %s%% available\n":            "  %s：%s（%s）%s，可用率 %s%%\n",
+	"down":                                          "离线",
+	"up, %s ms":                                     "在线，%s ms",
+	"Pinging %d servers %d times every %s":          "每 %[3]s 对 %[1]d 个服务器进行 %[2]d 次延迟测试",
+	"%s\t%s: median %s (%s - %s ms), loss %s\t%s\n": "%s\t%s：中位数 %s（%s - %s ms），丢包率 %s\t%s\n",
+	"\nHourly stats of %s (id = %s):\n":             "\n%s (id = %s) 的每小时统计：\n",
+	"  %02d:00\tmedian %s ms, 95th percentile %s ms, loss %s%% of %d pings\n": "  %02d:00\t中位数 %s ms，95 分位 %s ms，%[5]d 次延迟测试丢包率 %[4]s%%\n",
+	"Measuring idle latency":                                                    "正在测量空闲延迟",
+	"Measuring %s capacity":                                                     "正在测量%s容量",
+	"Idle:\t\tlatency %s (95th percentile %s ms), loss %s%%\n":                  "空闲：\t\t延迟 %s（95 分位 %s ms），丢包率 %s%%\n",
+	"%s %s%%:\toffered %s, got %s, latency %s (%s), loss %s%%\n":                "%s %s%%：\t提供 %s，实际 %s，延迟 %s（%s），丢包率 %s%%\n",
+	"Step at %s%% of %s capacity failed: %s":                                    "%[2]s容量 %[1]s%% 的测试失败：%[3]s",
+	"Buffers bloat at %s%% of %s capacity, latency rises by %d ms or more\n":    "在%[2]s容量的 %[1]s%% 时出现缓冲区膨胀，延迟上升 %[3]d ms 或更多\n",
+	"No bufferbloat in %s up to %s%% of capacity\n":                             "%s在容量的 %s%% 以内无缓冲区膨胀\n",
+	"Running %d download and %d upload flows for %s":                            "运行 %d 个下载流和 %d 个上传流，持续 %s",
+	"Idle latency:\t%s ms\n":                                                    "空闲延迟：\t%s ms\n",
+	"Download:\t%s (%d flows)\n":                                                "下载：\t\t%s（%d 个流）\n",
+	"Upload:\t\t%s (%d flows)\n":                                                "上传：\t\t%s（%d 个流）\n",
+	"Total:\t\t%s\n":                                                            "合计：\t\t%s\n",
+	"Loaded latency:\t%s ms (95th percentile %s ms, max %s ms, jitter %s ms)\n": "负载延迟：\t%s ms（95 分位 %s ms，最高 %s ms，抖动 %s ms）\n",
+	"Bufferbloat:\t%s (+%s ms under load)\n":                                    "缓冲区膨胀：\t%s（负载下 +%s ms）\n",
+	"Watching %d servers, testing one every %s":                                 "监测 %d 个服务器，每 %s 测试一个",
+	"%s\tServer: %s (id = %s)\n":                                                "%s\t服务器：%s (id = %s)\n",
+	"Test against %s (%s) failed: %s":                                           "测试 %s (%s) 失败：%s",
+	"%s monitor, testing every %s over the last %s":                             "%s 监测，每 %s 测试一次，显示最近 %s",
+	"Download":                       "下载",
+	"Upload":                         "上传",
+	"Ping":                           "延迟",
+	"Jitter":                         "抖动",
+	"now":                            "现在",
+	"%s  now %s, average %s, max %s": "%s  当前 %s，平均 %s，最高 %s",
+	"Testing against %s":             "正在测试 %s",
+	"Testing against %s: %s %s":      "正在测试 %s：%s %s",
+	"Next test in %ds":               "%d 秒后进行下一次测试",
+	"Last %d:\t%s ms ping, download %s (%s - %s), upload %s (%s - %s), %d of %d tests failed\n\n": "最近 %d 次：\t延迟 %s ms，下载 %s（%s - %s），上传 %s（%s - %s），%d 次测试失败（共 %d 次）\n\n",
+
+	// test servers
+	"Serving speed test on %s, test against it with --%s HOST:%d":         "测速服务运行于 %s，使用 --%s HOST:%d 进行测试",
+	"Serving speed test on %s, test against it with --%s HOST:%d --%s %s": "测速服务运行于 %s，使用 --%s HOST:%d --%s %s 进行测试",
+	"Served %d downloads (%.2f MB) and %d uploads (%.2f MB)":              "共服务 %d 次下载（%.2f MB）和 %d 次上传（%.2f MB）",
+	"Failed to listen on %s: %s":                                          "监听 %s 失败：%s",
+	"Failed to serve: %s":                                                 "服务出错：%s",
+	"Failed to start the built-in server: %s":                             "启动内置服务器失败：%s",
+	"These are the maximum rates this device can sustain, results close to them are limited by this device rather than the network": "以上是本设备能承受的最大速率，接近该速率的结果受限于本设备而非网络",
+
+	// version
+	"%s %s (built on %s %s)":                              "%s %s（构建于 %s %s）",
+	"Powered by TaierSpeed":                               "由泰尔测速提供支持",
+	"Project: https://github.com/ztelliot/taierspeed-cli": "项目：https://github.com/ztelliot/taierspeed-cli",
+	"Forked: https://github.com/librespeed/speedtest-cli": "派生自：https://github.com/librespeed/speedtest-cli",
+	"Error when fetching latest version: %s":              "获取最新版本出错：%s",
+	"Current version: %s":                                 "当前版本：%s",
+	"New version available: %s":                           "有可用的新版本：%s",
+	"Download Url: %s":                                    "下载地址：%s",
+	"You are using the latest version":                    "当前已是最新版本",
+
+	// invalid options
+	"CSV delimiter must be a single character: %q is given":                "CSV 分隔符必须是单个字符：给定的是 %q",
+	"Concurrent requests cannot be lower than 1: %d is given":              "并发请求数不能小于 1：给定的是 %d",
+	"Selection concurrency cannot be lower than 1: %d is given":            "选择并发数不能小于 1：给定的是 %d",
+	"Unknown server type: %s is given":                                     "未知的服务器类型：给定的是 %s",
+	"Path must start with /: %s is given for --%s":                         "--%[2]s 的路径必须以 / 开头：给定的是 %[1]s",
+	"Ping URL must be an absolute http or https URL: %s is given":          "延迟测试 URL 必须是绝对的 http 或 https URL：给定的是 %s",
+	"Invalid memory limit: %s":                                             "无效的内存限制：%s",
+	"Failed to map upload data from %s: %s":                                "从 %s 映射上传数据失败：%s",
+	"Address %s is not a valid IPv6 address":                               "地址 %s 不是有效的 IPv6 地址",
+	"Address %s is not a valid IPv4 address":                               "地址 %s 不是有效的 IPv4 地址",
+	"Error parsing source IP: %s":                                          "解析源 IP 出错：%s",
+	"Error parsing proxy URL: %s":                                          "解析代理 URL 出错：%s",
+	"Peer must be given as HOST:PORT: %s is given":                         "对端必须以 HOST:PORT 的形式给定：给定的是 %s",
+	"Can't test against LAN host: %s":                                      "无法测试局域网主机：%s",
+	"Invalid URL: %s":                                                      "无效的 URL：%s",
+	"Selection timeout must be at least 1 second: %d is given":             "选择超时至少为 1 秒：给定的是 %d",
+	"Interval must be at least 1 second: %d is given":                      "间隔至少为 1 秒：给定的是 %d",
+	"History must be at least 1 minute: %s hours is given":                 "历史时长至少为 1 分钟：给定的是 %s 小时",
+	"--%s requires a terminal":                                             "--%s 需要在终端中运行",
+	"Pings cannot be lower than 1: %d is given":                            "延迟测试次数不能小于 1：给定的是 %d",
+	"Steps must be positive percentages: %s is given":                      "阶梯必须是正的百分比：给定的是 %s",
+	"--%s needs --%s or --%s":                                              "--%s 需要 --%s 或 --%s",
+	"Share URL must be an absolute http or https URL: %s is given":         "分享 URL 必须是绝对的 http 或 https URL：给定的是 %s",
+	"Failed to share the report: %s":                                       "分享报告失败：%s",
+	"Share:\t\t%s\n":                                                       "分享：\t%s\n",
+	"--%s needs both download and upload tests":                            "--%s 需要同时进行下载和上传测试",
+	"Jitter must be between 0 and the interval of %d seconds: %d is given": "随机延迟必须在 0 到间隔 %d 秒之间：给定的是 %d",
+	"Window cannot be lower than 1: %d is given":                           "窗口不能小于 1：给定的是 %d",
+	"Port must be between 1 and 65535: %d is given":                        "端口必须在 1 到 65535 之间：给定的是 %d",
+	"Unknown unit: %s is given":                                            "未知的单位：给定的是 %s",
+	"Unknown language: %s is given":                                        "未知的语言：给定的是 %s",
+}
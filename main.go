@@ -1,12 +1,15 @@
 package main
 
 import (
+	"context"
 	"os"
+	"os/signal"
 
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 
 	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
 	"github.com/ztelliot/taierspeed-cli/speedtest"
 )
 
@@ -32,6 +35,172 @@ func main() {
 		Usage:    "Test your Internet speed with TaierSpeed",
 		Action:   speedtest.SpeedTest,
 		HideHelp: true,
+		Commands: []*cli.Command{
+			{
+				Name:   "serve",
+				Usage:  "Host a test server for private tests between two machines",
+				Action: speedtest.Serve,
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:    defs.OptionPort,
+						Aliases: []string{defs.OptionPortAlt},
+						Usage:   "`PORT` to listen on",
+						Value:   8080,
+					},
+					&cli.StringFlag{
+						Name:  defs.OptionBind,
+						Usage: "`ADDRESS` to listen on, all addresses by default",
+					},
+					&cli.StringFlag{
+						Name: defs.OptionType,
+						Usage: "Emulate the endpoints of `TYPE` servers, one of\n" +
+							"\t{globalspeed, perception, wirelessspeed}",
+						Value: "globalspeed",
+					},
+				},
+			},
+			{
+				Name:  "history",
+				Usage: "Migrate the local history of results between machines",
+				Subcommands: []*cli.Command{
+					{
+						Name:   "export",
+						Usage:  "Write the local history to stdout",
+						Action: speedtest.HistoryExport,
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:  defs.OptionFormat,
+								Usage: "Export in `FORMAT`, one of {jsonl, csv}",
+								Value: defs.FormatJSONL,
+							},
+						},
+					},
+					{
+						Name:      "import",
+						Usage:     "Merge exported histories into the local history",
+						ArgsUsage: "FILE...",
+						Action:    speedtest.HistoryImport,
+					},
+				},
+			},
+			{
+				Name:   "report",
+				Usage:  "Summarize the local history of the last week or month",
+				Action: speedtest.Report,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  defs.OptionPeriod,
+						Usage: "Summarize the last `PERIOD`, one of {week, month}",
+						Value: "week",
+					},
+					&cli.StringFlag{
+						Name:  defs.OptionFormat,
+						Usage: "Print in `FORMAT`, one of {text, markdown, html}",
+						Value: defs.FormatText,
+					},
+				},
+			},
+			{
+				Name: "compare",
+				Usage: "Test two servers in interleaved rounds and tell whether\n" +
+					"\tone is significantly better, global options go before it",
+				Action: speedtest.SpeedTest,
+				Flags: []cli.Flag{
+					&cli.StringSliceFlag{
+						Name:    defs.OptionServer,
+						Aliases: []string{defs.OptionServerAlt},
+						Usage: "Compare the server `ID` or HOST:PORT of a server hosted\n" +
+							"\twith `serve`. Must be supplied twice",
+					},
+					&cli.IntFlag{
+						Name:  defs.OptionRounds,
+						Usage: "Number of rounds of testing both servers",
+						Value: 5,
+					},
+				},
+			},
+			{
+				Name:  "alias",
+				Usage: "Assign aliases to servers, usable wherever a server ID is",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "set",
+						Usage:     "Assign ALIAS to the server ID or HOST:PORT",
+						ArgsUsage: "ALIAS ID",
+						Action:    speedtest.AliasSet,
+					},
+					{
+						Name:      "remove",
+						Usage:     "Remove the aliases",
+						ArgsUsage: "ALIAS...",
+						Action:    speedtest.AliasRemove,
+					},
+					{
+						Name:   "list",
+						Usage:  "List the assigned aliases",
+						Action: speedtest.AliasList,
+					},
+				},
+			},
+			{
+				Name:  "favorite",
+				Usage: "Keep the favorite servers --favorite selects from",
+				Subcommands: []*cli.Command{
+					{
+						Name:      "add",
+						Usage:     "Add the server IDs or HOST:PORT to the favorites",
+						ArgsUsage: "ID...",
+						Action:    speedtest.FavoriteAdd,
+					},
+					{
+						Name:      "remove",
+						Usage:     "Remove the servers from the favorites",
+						ArgsUsage: "ID...",
+						Action:    speedtest.FavoriteRemove,
+					},
+					{
+						Name:   "list",
+						Usage:  "List the favorite servers",
+						Action: speedtest.FavoriteList,
+					},
+				},
+			},
+			{
+				Name:   "provinces",
+				Usage:  "List the province codes of --group and their number of servers",
+				Action: speedtest.Provinces,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  defs.OptionRefresh,
+						Usage: "Fetch the server list again instead of using the cached one",
+					},
+				},
+			},
+			{
+				Name:   "isps",
+				Usage:  "List the ISP codes of --group and their number of servers",
+				Action: speedtest.ISPs,
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  defs.OptionRefresh,
+						Usage: "Fetch the server list again instead of using the cached one",
+					},
+				},
+			},
+			{
+				Name:      "verify",
+				Usage:     "Check the signature of reports signed with --sign-key",
+				ArgsUsage: "[FILE]",
+				Action:    speedtest.Verify,
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name: defs.OptionSignKey,
+						Usage: "Check with the key in `FILE`, the key used for signing\n" +
+							"\tor the public key of an ed25519 key",
+					},
+				},
+			},
+		},
 		Flags: []cli.Flag{
 			cli.HelpFlag,
 			&cli.BoolFlag{
@@ -55,14 +224,16 @@ func main() {
 				Usage:   "Force IPv6 only",
 			},
 			&cli.BoolFlag{
-				Name:   defs.OptionNoDownload,
-				Usage:  "Do not perform download test",
-				Hidden: true,
+				Name:  defs.OptionNoDownload,
+				Usage: "Do not perform download test",
 			},
 			&cli.BoolFlag{
-				Name:   defs.OptionNoUpload,
-				Usage:  "Do not perform upload test",
-				Hidden: true,
+				Name:  defs.OptionNoUpload,
+				Usage: "Do not perform upload test",
+			},
+			&cli.BoolFlag{
+				Name:  defs.OptionPingOnly,
+				Usage: "Only measure ping and jitter, same as --no-download --no-upload",
 			},
 			&cli.BoolFlag{
 				Name: defs.OptionNoICMP,
@@ -77,12 +248,20 @@ func main() {
 			},
 			&cli.BoolFlag{
 				Name: defs.OptionBytes,
-				Usage: "Display values in bytes instead of bits. Does not affect\n" +
-					"\toutput from --json or --csv",
+				Usage: "Display rates in bytes instead of bits. Does not affect\n" +
+					"\toutput from --json or --csv, which is in Mbps",
 			},
 			&cli.BoolFlag{
-				Name:  defs.OptionMebiBytes,
-				Usage: "Use 1024 bytes as 1 kilobyte instead of 1000\n\t",
+				Name: defs.OptionMebiBytes,
+				Usage: "Use IEC units in powers of 1024 (KiB, Mibps...) instead\n" +
+					"\tof SI units in powers of 1000",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionUnit,
+				Usage: "Display rates in `UNIT`, one of {Kbps, Mbps, Gbps, Kibps,\n" +
+					"\tMibps, Gibps, KB/s, MB/s, GB/s, KiB/s, MiB/s, GiB/s}.\n" +
+					"\tRates are scaled to their magnitude by default. Does not\n" +
+					"\taffect output from --json or --csv, which is in Mbps",
 			},
 			&cli.BoolFlag{
 				Name:    defs.OptionSimple,
@@ -108,6 +287,81 @@ func main() {
 				Usage: "Suppress verbose output. Speeds listed in bit/s and not\n" +
 					"\taffected by --bytes",
 			},
+			&cli.StringFlag{
+				Name: defs.OptionFormat,
+				Usage: "Suppress verbose output and print results in `FORMAT`,\n" +
+					"\tlegacy-csv for the CSV layout of sivel/speedtest-cli with\n" +
+					"\tspeeds in bit/s. Works with --csv-delimiter and --csv-header",
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionJSONProgress,
+				Usage: "Write the progress of tests as NDJSON events to stderr,\n" +
+					"\twith elapsed and remaining seconds of transfers",
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionStdioRPC,
+				Usage: "Serve JSON-RPC requests (listServers, startTest, cancel)\n" +
+					"\ton stdin and stream progress and results to stdout, for\n" +
+					"\tGUIs running the engine as a child process",
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionBrief,
+				Usage: "Suppress verbose output and warnings, only print one line\n" +
+					"\tper server (srv=... ping=... jitter=... down=... up=...)",
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionShare,
+				Usage: "Upload the JSON report to the share service and print\n" +
+					"\tthe URL it's shared at",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionShareURL,
+				Usage: "Share to `URL` instead, e.g. a paste service. The report\n" +
+					"\tis posted as JSON, the endpoint responds with the URL in\n" +
+					"\tplain text or in the url field of a JSON object",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionSignKey,
+				Usage: "Sign the report of --json or --share with the key in\n" +
+					"\t`FILE`, an ed25519 private key in PEM or else a secret for\n" +
+					"\tHMAC-SHA256. Check the signature with the verify command",
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionNoMetadataUpdate,
+				Usage: "Do not update the ISP, province and server alias mappings\n" +
+					"\tfrom the project repository, the cached ones are used",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionMetadataVersion,
+				Usage: "Pin the mappings to the metadata bundle `VERSION`, which is\n" +
+					"\tfetched once and reported in the JSON output",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionMetadataSHA256,
+				Usage: "Fail unless the metadata bundle matches the SHA-256\n" +
+					"\t`CHECKSUM` in hex",
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionNoHistory,
+				Usage: "Do not record the results in the local history, which is\n" +
+					"\tmigrated with the history command",
+			},
+			&cli.Float64Flag{
+				Name: defs.OptionVerifyAnomalies,
+				Usage: "Test again when a result is worse than the median of the\n" +
+					"\trecent results of the server in the history by more than\n" +
+					"\t`PERCENT`, and mark whether the deviation reproduced",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionVerifyServer,
+				Usage: "Test again against `SERVER` instead of the same server\n" +
+					"\twith --verify-anomalies, by ID or HOST:PORT",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionPromTextfile,
+				Usage: "Write the results to `PATH` in the Prometheus text format\n" +
+					"\tafter the test, for the textfile collector of node_exporter",
+			},
 			&cli.BoolFlag{
 				Name:    defs.OptionList,
 				Aliases: []string{defs.OptionListAlt},
@@ -116,8 +370,19 @@ func main() {
 			&cli.StringSliceFlag{
 				Name:    defs.OptionServer,
 				Aliases: []string{defs.OptionServerAlt},
-				Usage: "Specify a server `ID` to test against. Can be supplied\n" +
-					"\tmultiple times",
+				Usage: "Specify a server `ID` to test against, or HOST:PORT of a\n" +
+					"\tserver hosted with `serve`. Can be supplied multiple times",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionServerType,
+				Usage: "`TYPE` of the servers given by HOST:PORT, one of\n" +
+					"\t{globalspeed, perception, wirelessspeed}",
+				Value: "globalspeed",
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionFavorite,
+				Usage: "Select the fastest of the favorite servers kept with the\n" +
+					"\tfavorite command instead of the fastest nearby",
 			},
 			&cli.StringSliceFlag{
 				Name:    defs.OptionServerGroup,
@@ -128,6 +393,121 @@ func main() {
 					"\tISP can be {ct, cu, cm, cernet, catv, drpeng} or `ASN`.\n" +
 					"\tYou can use `lo` to refer to the current province or ISP",
 			},
+			&cli.StringFlag{
+				Name: defs.OptionListen,
+				Usage: "Listen on `ADDRESS` (e.g. :8080) for tests from another\n" +
+					"\tmachine running with --peer",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionPeer,
+				Usage: "Test against another machine running with --listen at\n" +
+					"\t`HOST:PORT`, also measuring packet loss",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionLAN,
+				Usage: "Test against a `HOST[:PORT]` on the local network running\n" +
+					"\tthe built-in server or iperf3 (port 5201), with 8\n" +
+					"\tconcurrent streams unless --concurrent is given",
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionProbe,
+				Usage: "Check the servers given by --server and --group are up\n" +
+					"\tand ping them periodically instead of testing, all\n" +
+					"\tservers are checked if none is given",
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionWatch,
+				Usage: "Test the servers given by --server and --group round-robin\n" +
+					"\tcontinuously, exporting the rolling stats of each server",
+			},
+			&cli.IntFlag{
+				Name:  defs.OptionInterval,
+				Usage: "`SECONDS` between two rounds of --probe and --ping-monitor\n\tor tests of --watch and --monitor",
+				Value: 60,
+			},
+			&cli.IntFlag{
+				Name:  defs.OptionCount,
+				Usage: "Number of rounds of --probe and --ping-monitor or tests of\n\t--watch and --monitor, unlimited when 0",
+			},
+			&cli.IntFlag{
+				Name: defs.OptionJitter,
+				Usage: "Delay every round of --probe and --ping-monitor or test of\n" +
+					"\t--watch and --monitor by a random time up to `SECONDS`,\n" +
+					"\tso that many clients don't test at once",
+			},
+			&cli.IntFlag{
+				Name:  defs.OptionWindow,
+				Usage: "Number of recent results in the rolling stats of --watch",
+				Value: 10,
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionMonitor,
+				Usage: "Test the servers given by --server and --group round-robin\n" +
+					"\tcontinuously, rendering charts of the download, upload\n" +
+					"\tand ping of the last --history hours in the terminal",
+			},
+			&cli.Float64Flag{
+				Name:  defs.OptionHistory,
+				Usage: "`HOURS` of results in the charts of --monitor",
+				Value: 24,
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionPingMonitor,
+				Usage: "Ping the servers given by --server and --group, or the\n" +
+					"\tfastest server nearby, periodically for hours without\n" +
+					"\ttesting, exporting the latency distribution and loss of\n" +
+					"\tevery round",
+			},
+			&cli.IntFlag{
+				Name:  defs.OptionPings,
+				Usage: "Number of pings of every round of --ping-monitor",
+				Value: 20,
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionStaircase,
+				Usage: "Measure the capacity of the server, then offer the loads\n" +
+					"\tgiven by --steps while measuring latency, to find the load\n" +
+					"\tat which buffers bloat",
+			},
+			&cli.StringFlag{
+				Name:  defs.OptionSteps,
+				Usage: "Comma separated `PERCENTS` of the capacity offered by\n\t--staircase",
+				Value: "10,25,50,100",
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionRRUL,
+				Usage: "Run download and upload flows simultaneously while\n" +
+					"\tmeasuring latency (Realtime Response Under Load), with 4\n" +
+					"\tflows in each direction unless --concurrent is given,\n" +
+					"\tgrading the bufferbloat",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionDownloadURL,
+				Usage: "Test downloading from any HTTP `URL` instead of a server,\n" +
+					"\tthe object is downloaded repeatedly",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionUploadURL,
+				Usage: "Test uploading to any HTTP `URL` instead of a server, with\n" +
+					"\tPOST requests",
+			},
+			&cli.StringFlag{
+				Name:  defs.OptionDownloadPath,
+				Usage: "Override the download `PATH` of the servers",
+			},
+			&cli.StringFlag{
+				Name:  defs.OptionUploadPath,
+				Usage: "Override the upload `PATH` of the servers",
+			},
+			&cli.StringFlag{
+				Name:  defs.OptionPingPath,
+				Usage: "Override the ping `PATH` of the servers",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionPingURL,
+				Usage: "Measure latency with HTTP ping against `URL` instead of\n" +
+					"\tthe servers, also used for checking they are up",
+			},
 			&cli.StringSliceFlag{
 				Name: defs.OptionExclude,
 				Usage: "`EXCLUDE` a server from selection. Can be supplied\n" +
@@ -143,11 +523,44 @@ func main() {
 				Aliases: []string{defs.OptionInterfaceAlt},
 				Usage:   "Network `INTERFACE` to bind to, only available for linux",
 			},
+			&cli.StringSliceFlag{
+				Name: defs.OptionInterfaces,
+				Usage: "Test once through each of the comma separated network\n" +
+					"\t`INTERFACES` and compare them, only available for linux",
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionMPTCP,
+				Usage: "Use Multipath TCP for the test connections where the\n" +
+					"\tkernel supports it, only available for linux",
+			},
 			&cli.IntFlag{
 				Name:  defs.OptionTimeout,
 				Usage: "HTTP `TIMEOUT` in seconds",
 				Value: 15,
 			},
+			&cli.IntFlag{
+				Name: defs.OptionSelectionConcurrency,
+				Usage: "Number of servers checked and pinged concurrently when\n" +
+					"\tselecting the fastest server",
+				Value: 10,
+			},
+			&cli.IntFlag{
+				Name: defs.OptionSelectionTimeout,
+				Usage: "Overall `TIMEOUT` in seconds for selecting the fastest\n" +
+					"\tserver, servers not pinged in time are skipped",
+				Value: 10,
+			},
+			&cli.Float64Flag{
+				Name: defs.OptionUpCheckTimeout,
+				Usage: "`TIMEOUT` in seconds of checking a server is up before\n" +
+					"\tpinging or testing it",
+				Value: 2,
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionSkipUpCheck,
+				Usage: "Don't check the servers are up before pinging or testing\n" +
+					"\tthem, servers which are down fail when pinged instead",
+			},
 			&cli.IntFlag{
 				Name:    defs.OptionDuration,
 				Aliases: []string{defs.OptionDurationAlt},
@@ -155,6 +568,23 @@ func main() {
 				Value:   15,
 				Hidden:  true,
 			},
+			&cli.IntFlag{
+				Name: defs.OptionStallTimeout,
+				Usage: "Abort and restart a download or upload request which\n" +
+					"\ttransferred nothing for `SECONDS`, 0 to never abort",
+				Value: 5,
+			},
+			&cli.Float64Flag{
+				Name: defs.OptionCrossTraffic,
+				Usage: "Sample the traffic of the network interfaces before each\n" +
+					"\ttest and warn if other traffic is above `MBPS`, the\n" +
+					"\ttraffic is added to JSON results",
+			},
+			&cli.IntFlag{
+				Name: defs.OptionCrossTrafficWait,
+				Usage: "Wait for up to `SECONDS` for other traffic to drop below\n" +
+					"\t--cross-traffic before testing anyway",
+			},
 			&cli.IntFlag{
 				Name:   defs.OptionUploadSize,
 				Usage:  "Size of payload being uploaded in KiB",
@@ -168,6 +598,33 @@ func main() {
 					"\tsupport systems with insufficient memory, use this\n" +
 					"\toption to avoid out of memory errors",
 			},
+			&cli.StringFlag{
+				Name: defs.OptionBlobFile,
+				Usage: "Memory-map upload data from `FILE` instead of generating\n" +
+					"\tit in memory, the file is created if missing",
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionLowMemory,
+				Usage: "Reduce memory usage for devices like routers, shrinks\n" +
+					"\tupload data and buffers and caps concurrent requests",
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionAndroid,
+				Usage: "Preset for Android and Termux, uses unprivileged ICMP ping\n" +
+					"\tand smaller buffers, and keeps the data in the temporary\n" +
+					"\tdirectory if the config directory isn't writable. Enabled\n" +
+					"\tautomatically on Android, use --android=false to disable",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionMemLimit,
+				Usage: "Soft memory `LIMIT` for the Go runtime (e.g. 64MiB), also\n" +
+					"\tmakes the garbage collector more aggressive",
+			},
+			&cli.BoolFlag{
+				Name: defs.OptionSelfTest,
+				Usage: "Test against a built-in server on the loopback interface,\n" +
+					"\tshowing the maximum rate this device can sustain",
+			},
 			&cli.StringFlag{
 				Name:   defs.OptionAPIBase,
 				Usage:  "Core API `URL`",
@@ -180,12 +637,27 @@ func main() {
 				Value:  "v1",
 				Hidden: true,
 			},
+			&cli.StringFlag{
+				Name: defs.OptionProxy,
+				Usage: "Send all test traffic through proxy `URL`, supports\n" +
+					"\thttp, https and socks5 schemes",
+			},
 			&cli.BoolFlag{
 				Name:   defs.OptionTLSInsecure,
 				Usage:  "Disable TLS certificate verification",
 				Value:  false,
 				Hidden: true,
 			},
+			&cli.BoolFlag{
+				Name: defs.OptionNoColor,
+				Usage: "Disable colored output, which is also disabled if not\n" +
+					"\toutput to a terminal or NO_COLOR is set",
+			},
+			&cli.StringFlag{
+				Name: defs.OptionLang,
+				Usage: "`LANG` of the output, {zh, en}. Detected from the locale\n" +
+					"\tby default",
+			},
 			&cli.BoolFlag{
 				Name:    defs.OptionDebug,
 				Aliases: []string{"verbose"},
@@ -195,9 +667,13 @@ func main() {
 		},
 	}
 
+	// cancel the running test on interrupt, so the server queue is released
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
 	// run main function with cli options
-	err := app.Run(os.Args)
+	err := app.RunContext(ctx, os.Args)
 	if err != nil {
-		log.Fatal("Terminated due to error")
+		log.Fatal(i18n.T("Terminated due to error"))
 	}
 }
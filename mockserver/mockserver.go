@@ -0,0 +1,288 @@
+// Package mockserver emulates the endpoints of GlobalSpeed, Perception and WirelessSpeed servers, for testing the
+// speed test engine and integrations of it offline
+package mockserver
+
+import (
+	"crypto/rand"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+)
+
+// the size of the download file of GlobalSpeed servers
+const globalSpeedFileSize = 1 << 30
+
+// Config configures the behaviour of a mock server
+type Config struct {
+	// Type of the emulated server
+	Type defs.ServerType
+	// Latency is added to every response
+	Latency time.Duration
+	// DownloadSize is the size of every download response in bytes, unlimited when zero except for GlobalSpeed
+	// servers, which serve a file of 1 GiB
+	DownloadSize int64
+	// RateLimit limits the throughput of every request in bytes per second, unlimited when zero
+	RateLimit int64
+	// NoToken makes GlobalSpeed servers refuse to hand out test tokens
+	NoToken bool
+	// StallEvery makes every StallEvery-th download response stop sending after the first chunk until the request is
+	// canceled, no response stalls when zero
+	StallEvery int64
+}
+
+// Stats are the requests served by a mock server so far
+type Stats struct {
+	Pings         int64
+	Downloads     int64
+	Uploads       int64
+	BytesSent     int64
+	BytesReceived int64
+	// Tokens is the number of test tokens handed out and not released yet
+	Tokens int64
+}
+
+// Handler serves the endpoints of a speed test server
+type Handler struct {
+	config Config
+	chunk  []byte
+
+	pings, downloads, uploads atomic.Int64
+	bytesSent, bytesReceived  atomic.Int64
+
+	lock   sync.Mutex
+	tokens map[string]bool
+}
+
+// NewHandler returns the handler for the endpoints of the server type in `config`
+func NewHandler(config Config) *Handler {
+	h := &Handler{config: config, chunk: make([]byte, 32*1024), tokens: make(map[string]bool)}
+	rand.Read(h.chunk)
+	return h
+}
+
+// Stats returns the requests served so far
+func (h *Handler) Stats() Stats {
+	h.lock.Lock()
+	tokens := int64(len(h.tokens))
+	h.lock.Unlock()
+
+	return Stats{
+		Pings:         h.pings.Load(),
+		Downloads:     h.downloads.Load(),
+		Uploads:       h.uploads.Load(),
+		BytesSent:     h.bytesSent.Load(),
+		BytesReceived: h.bytesReceived.Load(),
+		Tokens:        tokens,
+	}
+}
+
+func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if h.config.Latency > 0 {
+		select {
+		case <-time.After(h.config.Latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	var ping, download, upload string
+	switch h.config.Type {
+	case defs.Perception:
+		ping, download, upload = "/speedtest/ping", "/speedtest/download", "/speedtest/upload"
+	case defs.WirelessSpeed:
+		ping, download, upload = "/GSpeedTestServer/", "/GSpeedTestServer/download", "/GSpeedTestServer/upload"
+	default:
+		ping, download, upload = "/speed/", "/speed/File(1G).dl", "/speed/doAnalsLoad.do"
+		if r.URL.Path == "/speed/dovalid" {
+			h.serveToken(w, r)
+			return
+		}
+	}
+
+	switch r.URL.Path {
+	case ping:
+		h.pings.Add(1)
+		w.WriteHeader(http.StatusOK)
+	case download:
+		if !h.validToken(r.URL.Query().Get("key")) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		h.serveDownload(w, r, h.downloads.Add(1))
+	case upload:
+		if r.Method != http.MethodPost {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		if !h.validToken(r.Header.Get("Key")) {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		h.uploads.Add(1)
+		h.serveUpload(w, r)
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// serveToken hands out test tokens on GET, and releases them on POST
+func (h *Handler) serveToken(w http.ResponseWriter, r *http.Request) {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+
+	if r.Method == http.MethodPost {
+		key := r.URL.Query().Get("key")
+		if !h.tokens[key] {
+			fmt.Fprint(w, "0")
+			return
+		}
+		delete(h.tokens, key)
+		fmt.Fprint(w, "1")
+		return
+	}
+
+	if h.config.NoToken {
+		fmt.Fprint(w, "0,-")
+		return
+	}
+	key := strconv.FormatInt(time.Now().UnixNano(), 36)
+	h.tokens[key] = true
+	fmt.Fprintf(w, "1,%s", key)
+}
+
+// validToken checks the test token of a request to a GlobalSpeed server
+func (h *Handler) validToken(key string) bool {
+	if h.config.Type != defs.GlobalSpeed {
+		return true
+	}
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.tokens[key]
+}
+
+// serveDownload serves the `nth` download request
+func (h *Handler) serveDownload(w http.ResponseWriter, r *http.Request, nth int64) {
+	size := h.config.DownloadSize
+	if size == 0 && h.config.Type == defs.GlobalSpeed {
+		size = globalSpeedFileSize
+	}
+	if size > 0 {
+		w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+	}
+	w.Header().Set("Content-Type", "application/octet-stream")
+	w.WriteHeader(http.StatusOK)
+
+	stall := h.config.StallEvery > 0 && nth%h.config.StallEvery == 0
+	limit := newLimiter(h.config.RateLimit)
+	for sent := int64(0); size == 0 || sent < size; {
+		if stall && sent > 0 {
+			<-r.Context().Done()
+			return
+		}
+		chunk := h.chunk
+		if size > 0 && size-sent < int64(len(chunk)) {
+			chunk = chunk[:size-sent]
+		}
+		n, err := w.Write(chunk)
+		sent += int64(n)
+		h.bytesSent.Add(int64(n))
+		if err != nil || !limit.wait(r, n) {
+			return
+		}
+	}
+}
+
+func (h *Handler) serveUpload(w http.ResponseWriter, r *http.Request) {
+	limit := newLimiter(h.config.RateLimit)
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Body.Read(buf)
+		h.bytesReceived.Add(int64(n))
+		if err == io.EOF {
+			break
+		} else if err != nil || !limit.wait(r, n) {
+			return
+		}
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// limiter throttles a single request to a rate in bytes per second
+type limiter struct {
+	rate  int64
+	start time.Time
+	total int64
+}
+
+func newLimiter(rate int64) *limiter {
+	return &limiter{rate: rate, start: time.Now()}
+}
+
+// wait accounts `n` bytes and sleeps until they are due, it returns false if the request is canceled meanwhile
+func (l *limiter) wait(r *http.Request, n int) bool {
+	if l.rate <= 0 {
+		return r.Context().Err() == nil
+	}
+	l.total += int64(n)
+	due := l.start.Add(time.Duration(float64(l.total) / float64(l.rate) * float64(time.Second)))
+	select {
+	case <-time.After(time.Until(due)):
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// Server is a mock server listening on the loopback interface
+type Server struct {
+	*Handler
+
+	listener net.Listener
+	server   *http.Server
+}
+
+// Start starts a mock server on a random port of the loopback interface
+func Start(config Config) (*Server, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{Handler: NewHandler(config), listener: ln}
+	s.server = &http.Server{Handler: s.Handler}
+	go s.server.Serve(ln)
+	return s, nil
+}
+
+// Server returns the server definition for testing against the mock server
+func (s *Server) Server() defs.Server {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return defs.Server{
+		ID:       "mock",
+		Name:     fmt.Sprintf("Mock %s", strings.TrimPrefix(s.URL(), "http://")),
+		IP:       addr.IP.String(),
+		Host:     addr.IP.String(),
+		Port:     uint16(addr.Port),
+		Province: "Local",
+		City:     "Local",
+		Type:     s.config.Type,
+	}
+}
+
+// URL returns the base URL of the mock server
+func (s *Server) URL() string {
+	return fmt.Sprintf("http://%s", s.listener.Addr())
+}
+
+// Close stops the mock server and closes all connections
+func (s *Server) Close() error {
+	return s.server.Close()
+}
@@ -0,0 +1,158 @@
+package mockserver
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+)
+
+// startServer starts a mock server of `config` which is closed at the end of the test
+func startServer(t *testing.T, config Config) *Server {
+	t.Helper()
+	s, err := Start(config)
+	if err != nil {
+		t.Fatalf("failed to start mock server: %s", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+// request sends a request to `path` of the mock server, and returns the status and body of the response
+func request(t *testing.T, s *Server, method, path string, body io.Reader) (int, string) {
+	t.Helper()
+	req, err := http.NewRequest(method, s.URL()+path, body)
+	if err != nil {
+		t.Fatalf("failed to create request: %s", err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request to %s failed: %s", path, err)
+	}
+	defer resp.Body.Close()
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("failed to read response of %s: %s", path, err)
+	}
+	return resp.StatusCode, string(b)
+}
+
+func TestEndpoints(t *testing.T) {
+	tests := []struct {
+		typ                    defs.ServerType
+		ping, download, upload string
+	}{
+		{defs.Perception, "/speedtest/ping", "/speedtest/download", "/speedtest/upload"},
+		{defs.WirelessSpeed, "/GSpeedTestServer/", "/GSpeedTestServer/download", "/GSpeedTestServer/upload"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.typ.String(), func(t *testing.T) {
+			s := startServer(t, Config{Type: tt.typ, DownloadSize: 100 << 10})
+
+			if status, _ := request(t, s, http.MethodGet, tt.ping, nil); status != http.StatusOK {
+				t.Errorf("expected ping status 200, got %d", status)
+			}
+			if status, body := request(t, s, http.MethodGet, tt.download, nil); status != http.StatusOK || len(body) != 100<<10 {
+				t.Errorf("expected 100 KiB of download with status 200, got %d bytes with %d", len(body), status)
+			}
+			if status, _ := request(t, s, http.MethodPost, tt.upload, bytes.NewReader(make([]byte, 50<<10))); status != http.StatusOK {
+				t.Errorf("expected upload status 200, got %d", status)
+			}
+			if status, _ := request(t, s, http.MethodGet, tt.upload, nil); status != http.StatusMethodNotAllowed {
+				t.Errorf("expected upload by GET refused with 405, got %d", status)
+			}
+			if status, _ := request(t, s, http.MethodGet, "/speed/", nil); status != http.StatusNotFound {
+				t.Errorf("expected endpoints of other server types not found, got %d", status)
+			}
+
+			want := Stats{Pings: 1, Downloads: 1, Uploads: 1, BytesSent: 100 << 10, BytesReceived: 50 << 10}
+			if stats := s.Stats(); stats != want {
+				t.Errorf("expected %+v, got %+v", want, stats)
+			}
+		})
+	}
+}
+
+func TestTokens(t *testing.T) {
+	s := startServer(t, Config{Type: defs.GlobalSpeed, DownloadSize: 1 << 10})
+
+	if status, _ := request(t, s, http.MethodGet, "/speed/File(1G).dl", nil); status != http.StatusForbidden {
+		t.Errorf("expected download without token refused with 403, got %d", status)
+	}
+	_, body := request(t, s, http.MethodGet, "/speed/dovalid", nil)
+	ok, key, _ := strings.Cut(body, ",")
+	if ok != "1" || key == "" {
+		t.Fatalf("expected a token, got %q", body)
+	}
+	if stats := s.Stats(); stats.Tokens != 1 {
+		t.Errorf("expected 1 token handed out, got %d", stats.Tokens)
+	}
+
+	if status, body := request(t, s, http.MethodGet, "/speed/File(1G).dl?key="+key, nil); status != http.StatusOK || len(body) != 1<<10 {
+		t.Errorf("expected 1 KiB of download with status 200, got %d bytes with %d", len(body), status)
+	}
+	req, _ := http.NewRequest(http.MethodPost, s.URL()+"/speed/doAnalsLoad.do", strings.NewReader("data"))
+	req.Header.Set("Key", key)
+	if resp, err := http.DefaultClient.Do(req); err != nil || resp.StatusCode != http.StatusOK {
+		t.Errorf("expected upload with token accepted, got %v", err)
+	} else {
+		resp.Body.Close()
+	}
+
+	if _, body := request(t, s, http.MethodPost, "/speed/dovalid?key="+key, nil); body != "1" {
+		t.Errorf("expected the token released, got %q", body)
+	}
+	if _, body := request(t, s, http.MethodPost, "/speed/dovalid?key="+key, nil); body != "0" {
+		t.Errorf("expected a released token unknown, got %q", body)
+	}
+	if stats := s.Stats(); stats.Tokens != 0 {
+		t.Errorf("expected no tokens left, got %d", stats.Tokens)
+	}
+}
+
+func TestNoToken(t *testing.T) {
+	s := startServer(t, Config{Type: defs.GlobalSpeed, NoToken: true})
+	if _, body := request(t, s, http.MethodGet, "/speed/dovalid", nil); body != "0,-" {
+		t.Errorf("expected the token refused, got %q", body)
+	}
+}
+
+func TestRateLimit(t *testing.T) {
+	s := startServer(t, Config{Type: defs.Perception, DownloadSize: 256 << 10, RateLimit: 1 << 20, Latency: 100 * time.Millisecond})
+
+	start := time.Now()
+	request(t, s, http.MethodGet, "/speedtest/download", nil)
+	// the latency and a quarter second for 256 KiB at 1 MiB/s, less the first chunk sent at once
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("expected the download to take at least 300ms, took %s", elapsed)
+	}
+}
+
+func TestStallEvery(t *testing.T) {
+	s := startServer(t, Config{Type: defs.Perception, DownloadSize: 1 << 20, StallEvery: 2})
+
+	if _, body := request(t, s, http.MethodGet, "/speedtest/download", nil); len(body) != 1<<20 {
+		t.Errorf("expected the first download complete, got %d bytes", len(body))
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	req, _ := http.NewRequestWithContext(ctx, http.MethodGet, s.URL()+"/speedtest/download", nil)
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %s", err)
+	}
+	defer resp.Body.Close()
+	n, _ := io.Copy(io.Discard, resp.Body)
+	if n == 0 || n >= 1<<20 {
+		t.Errorf("expected the second download to stall after the first chunk, got %d bytes", n)
+	}
+	if ctx.Err() == nil {
+		t.Error("expected the stalled download to last until canceled")
+	}
+}
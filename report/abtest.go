@@ -0,0 +1,154 @@
+package report
+
+import "math"
+
+// significance is the p-value below which a difference of two servers is considered significant
+const significance = 0.05
+
+// ABTest is the comparison of two servers tested in interleaved rounds by the compare command
+type ABTest struct {
+	A Summary `json:"a"`
+	B Summary `json:"b"`
+	// the differences of B to A, positive values are better for every measurement like in Comparison
+	Ping     Difference `json:"ping"`
+	Jitter   Difference `json:"jitter"`
+	Download Difference `json:"download"`
+	Upload   Difference `json:"upload"`
+}
+
+// Difference is the relative difference of the means of a measurement of two servers in percent, with the two-sided
+// p-value of Welch's t-test telling whether the difference is beyond the variation between rounds
+type Difference struct {
+	Delta       float64 `json:"delta"`
+	P           float64 `json:"p"`
+	Significant bool    `json:"significant"`
+}
+
+// CompareServers returns the comparison of the results of two servers, failed rounds are counted from `failuresA`
+// and `failuresB`
+func CompareServers(a, b []Result, failuresA, failuresB int) ABTest {
+	t := ABTest{A: Summarize(a), B: Summarize(b)}
+	t.A.Tests, t.A.Failures = len(a)+failuresA, failuresA
+	t.B.Tests, t.B.Failures = len(b)+failuresB, failuresB
+
+	field := func(results []Result, get func(Result) float64) []float64 {
+		values := make([]float64, len(results))
+		for i, r := range results {
+			values[i] = get(r)
+		}
+		return values
+	}
+	t.Ping = difference(field(a, func(r Result) float64 { return r.Ping }), field(b, func(r Result) float64 { return r.Ping }), true)
+	t.Jitter = difference(field(a, func(r Result) float64 { return r.Jitter }), field(b, func(r Result) float64 { return r.Jitter }), true)
+	t.Download = difference(field(a, func(r Result) float64 { return r.Download }), field(b, func(r Result) float64 { return r.Download }), false)
+	t.Upload = difference(field(a, func(r Result) float64 { return r.Upload }), field(b, func(r Result) float64 { return r.Upload }), false)
+	return t
+}
+
+// difference returns the difference of the mean of `b` to the mean of `a`, lower values count as positive if
+// `lowerBetter` is set
+func difference(a, b []float64, lowerBetter bool) Difference {
+	meanA, _ := meanVariance(a)
+	meanB, _ := meanVariance(b)
+
+	var d Difference
+	if lowerBetter {
+		d.Delta = round(decrease(meanB, meanA))
+	} else {
+		d.Delta = round(relative(meanB, meanA))
+	}
+	d.P = round(welch(a, b)*1e4) / 1e4
+	d.Significant = d.P < significance
+	return d
+}
+
+// welch returns the two-sided p-value of Welch's t-test of the means of `a` and `b`, at least 2 values of each are
+// needed for a p-value below 1
+func welch(a, b []float64) float64 {
+	if len(a) < 2 || len(b) < 2 {
+		return 1
+	}
+	meanA, varA := meanVariance(a)
+	meanB, varB := meanVariance(b)
+	sa, sb := varA/float64(len(a)), varB/float64(len(b))
+	if sa+sb == 0 {
+		// no variation at all, any difference is beyond it
+		if meanA == meanB {
+			return 1
+		}
+		return 0
+	}
+
+	t := (meanA - meanB) / math.Sqrt(sa+sb)
+	// Welch–Satterthwaite degrees of freedom
+	df := (sa + sb) * (sa + sb) / (sa*sa/float64(len(a)-1) + sb*sb/float64(len(b)-1))
+	return incompleteBeta(df/2, 0.5, df/(df+t*t))
+}
+
+// meanVariance returns the mean and the sample variance of values
+func meanVariance(values []float64) (mean, variance float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	return mean, variance / float64(len(values)-1)
+}
+
+// incompleteBeta returns the regularized incomplete beta function I_x(a, b), evaluated by its continued fraction
+func incompleteBeta(a, b, x float64) float64 {
+	if x <= 0 {
+		return 0
+	}
+	if x >= 1 {
+		return 1
+	}
+	// the continued fraction converges quickly below this point, above it the symmetry relation is used
+	if x > (a+1)/(a+b+2) {
+		return 1 - incompleteBeta(b, a, 1-x)
+	}
+
+	la, _ := math.Lgamma(a)
+	lb, _ := math.Lgamma(b)
+	lab, _ := math.Lgamma(a + b)
+	front := math.Exp(lab-la-lb+a*math.Log(x)+b*math.Log(1-x)) / a
+
+	// modified Lentz's method
+	const tiny = 1e-30
+	f, c, d := 1.0, 1.0, 0.0
+	for i := 0; i <= 200; i++ {
+		m := float64(i / 2)
+		var num float64
+		switch {
+		case i == 0:
+			num = 1
+		case i%2 == 0:
+			num = m * (b - m) * x / ((a + 2*m - 1) * (a + 2*m))
+		default:
+			num = -(a + m) * (a + b + m) * x / ((a + 2*m) * (a + 2*m + 1))
+		}
+
+		d = 1 + num*d
+		if math.Abs(d) < tiny {
+			d = tiny
+		}
+		d = 1 / d
+		c = 1 + num/c
+		if math.Abs(c) < tiny {
+			c = tiny
+		}
+		f *= c * d
+		if math.Abs(1-c*d) < 1e-10 {
+			break
+		}
+	}
+	return front * (f - 1)
+}
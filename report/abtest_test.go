@@ -0,0 +1,76 @@
+package report
+
+import (
+	"math"
+	"testing"
+)
+
+func TestIncompleteBeta(t *testing.T) {
+	// closed forms of the regularized incomplete beta function
+	tests := []struct {
+		name    string
+		a, b, x float64
+		want    float64
+	}{
+		{"uniform", 1, 1, 0.3, 0.3},
+		{"power", 3, 1, 0.6, math.Pow(0.6, 3)},
+		{"reflected power", 1, 4, 0.2, 1 - math.Pow(0.8, 4)},
+		{"symmetric", 7.5, 7.5, 0.5, 0.5},
+		{"cubic", 2, 2, 0.9, 3*0.81 - 2*0.729},
+		{"below range", 2, 3, 0, 0},
+		{"above range", 2, 3, 1, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := incompleteBeta(tt.a, tt.b, tt.x); math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("expected I_%g(%g, %g) = %g, got %g", tt.x, tt.a, tt.b, tt.want, got)
+			}
+		})
+	}
+}
+
+func TestWelch(t *testing.T) {
+	// the p-values of samples with equal variances are those of Student's t-distribution with integer degrees of
+	// freedom, which have closed forms
+	tests := []struct {
+		name string
+		a, b []float64
+		want float64
+	}{
+		{"2 degrees of freedom", []float64{0, 2}, []float64{3, 5}, 0.1679497056621564},
+		{"4 degrees of freedom", []float64{1, 2, 3}, []float64{4, 5, 6}, 0.02131164112875661},
+		{"swapped", []float64{4, 5, 6}, []float64{1, 2, 3}, 0.02131164112875661},
+		{"equal means", []float64{1, 2, 3}, []float64{0, 2, 4}, 1},
+		{"too few values", []float64{1}, []float64{4, 5, 6}, 1},
+		{"no variation", []float64{2, 2}, []float64{3, 3}, 0},
+		{"no variation nor difference", []float64{2, 2}, []float64{2, 2}, 1},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := welch(tt.a, tt.b); math.Abs(got-tt.want) > 1e-6 {
+				t.Errorf("expected p = %g, got %g", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCompareServers(t *testing.T) {
+	results := func(downloads ...float64) []Result {
+		r := make([]Result, len(downloads))
+		for i, d := range downloads {
+			r[i] = Result{Ping: 10, Download: d}
+		}
+		return r
+	}
+
+	ab := CompareServers(results(100, 110, 90), results(200, 210, 190), 1, 0)
+	if ab.A.Tests != 4 || ab.A.Failures != 1 || ab.B.Tests != 3 {
+		t.Errorf("expected 4 tests with 1 failure of A and 3 of B, got %+v and %+v", ab.A, ab.B)
+	}
+	if ab.Download.Delta != 100 || !ab.Download.Significant {
+		t.Errorf("expected a significant download difference of 100%%, got %+v", ab.Download)
+	}
+	if ab.Ping.Delta != 0 || ab.Ping.Significant {
+		t.Errorf("expected no ping difference, got %+v", ab.Ping)
+	}
+}
@@ -0,0 +1,95 @@
+package report
+
+import "sort"
+
+const (
+	// the number of recent results of a server its baseline is taken from
+	baselineResults = 10
+	// the fewest recent results of a server needed for a baseline
+	minBaselineResults = 3
+)
+
+// the measurements checked for deviating from the baseline
+const (
+	MetricPing     = "ping"
+	MetricDownload = "download"
+	MetricUpload   = "upload"
+)
+
+// Anomaly represents a result deviating from the baseline of the recent results of its server, and whether a re-test
+// reproduced the deviation
+type Anomaly struct {
+	// Threshold is the deviation in percent above which a measurement is anomalous
+	Threshold float64  `json:"threshold"`
+	Baseline  Baseline `json:"baseline"`
+	// Deviation is the relative difference of the result to the baseline, see Compare
+	Deviation Comparison `json:"deviation"`
+	// Metrics are the measurements deviating by more than Threshold
+	Metrics []string `json:"metrics"`
+	// Retest is the result of the re-test, against the server given with --verify-server if any
+	Retest *Result `json:"retest,omitempty"`
+	// Reproduced tells whether the re-test deviated in any of Metrics too, it's left out if the re-test couldn't be
+	// judged as the other server has too few results in the history
+	Reproduced *bool `json:"reproduced,omitempty"`
+}
+
+// Baseline is the median of the recent results of a server
+type Baseline struct {
+	Tests    int     `json:"tests"`
+	Ping     float64 `json:"ping"`
+	Jitter   float64 `json:"jitter"`
+	Download float64 `json:"download"`
+	Upload   float64 `json:"upload"`
+}
+
+// BaselineOf returns the baseline of the latest results of the server `id` in the history, false if there are too
+// few of them
+func BaselineOf(records []Record, id string) (Baseline, bool) {
+	var results []Result
+	for _, r := range records {
+		if r.ID == id {
+			results = append(results, r.Result)
+		}
+	}
+	if len(results) < minBaselineResults {
+		return Baseline{Tests: len(results)}, false
+	}
+	sort.Slice(results, func(i, j int) bool { return results[i].Timestamp.After(results[j].Timestamp) })
+	results = results[:min(len(results), baselineResults)]
+
+	var ping, jitter, download, upload []float64
+	for _, r := range results {
+		ping = append(ping, r.Ping)
+		jitter = append(jitter, r.Jitter)
+		download = append(download, r.Download)
+		upload = append(upload, r.Upload)
+	}
+	median := func(values []float64) float64 {
+		sort.Float64s(values)
+		return round(Percentile(values, 50))
+	}
+	return Baseline{
+		Tests:    len(results),
+		Ping:     median(ping),
+		Jitter:   median(jitter),
+		Download: median(download),
+		Upload:   median(upload),
+	}, true
+}
+
+// Deviate returns the relative difference of this result to `base`, and the measurements worse than it by more than
+// `threshold` percent. Measurements which weren't taken are left out
+func (r Result) Deviate(base Baseline, threshold float64) (Comparison, []string) {
+	d := r.Compare(Result{Ping: base.Ping, Jitter: base.Jitter, Download: base.Download, Upload: base.Upload})
+	var metrics []string
+	if r.Ping > 0 && d.Ping < -threshold {
+		metrics = append(metrics, MetricPing)
+	}
+	if r.Download > 0 && d.Download < -threshold {
+		metrics = append(metrics, MetricDownload)
+	}
+	if r.Upload > 0 && d.Upload < -threshold {
+		metrics = append(metrics, MetricUpload)
+	}
+	return d, metrics
+}
@@ -0,0 +1,25 @@
+package report
+
+// Confidence rates how well a result reflects the network, from 0 to 100. Reasons lists what lowered the score
+type Confidence struct {
+	Score   int      `json:"score"`
+	Reasons []string `json:"reasons,omitempty"`
+}
+
+// the reasons lowering the confidence of a result
+const (
+	// ReasonUnstable is given when the throughput varied a lot during a transfer
+	ReasonUnstable = "unstable"
+	// ReasonShort is given when the transfers were too short to reach a steady rate
+	ReasonShort = "short"
+	// ReasonRetries is given when transfer requests failed and were retried
+	ReasonRetries = "retries"
+	// ReasonCPU is given when the CPU usage likely limited a transfer
+	ReasonCPU = "cpu"
+	// ReasonCrossTraffic is given when other traffic was running before the test
+	ReasonCrossTraffic = "cross_traffic"
+	// ReasonLink is given when the result is close to the link speed of the network interface
+	ReasonLink = "link"
+	// ReasonTunnel is given when the server is reached through a tunnel
+	ReasonTunnel = "tunnel"
+)
@@ -0,0 +1,74 @@
+package report
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Record is a result in the local history, with the machine and the public IP of the client which measured it
+type Record struct {
+	Result
+	Probe  string `json:"probe,omitempty" csv:"Probe"`
+	Client string `json:"client,omitempty" csv:"Client"`
+}
+
+// key identifies a record when merging histories, a result is measured by one probe against one server at a time
+func (r *Record) key() string {
+	return fmt.Sprintf("%s|%s|%d", r.Probe, r.ID, r.Timestamp.UnixNano())
+}
+
+// ReadHistory decodes records in JSON Lines, one record per line
+func ReadHistory(r io.Reader) ([]Record, error) {
+	var records []Record
+	dec := json.NewDecoder(bufio.NewReader(r))
+	for {
+		var rec Record
+		if err := dec.Decode(&rec); errors.Is(err, io.EOF) {
+			return records, nil
+		} else if err != nil {
+			return records, err
+		}
+		records = append(records, rec)
+	}
+}
+
+// WriteHistory encodes records in JSON Lines, one record per line
+func WriteHistory(w io.Writer, records []Record) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	for i := range records {
+		if err := enc.Encode(&records[i]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// MergeHistory returns the records of both histories ordered by time, with the records of `others` which are already
+// in `records` dropped, and the number of records added
+func MergeHistory(records, others []Record) ([]Record, int) {
+	seen := make(map[string]bool, len(records))
+	for i := range records {
+		seen[records[i].key()] = true
+	}
+	merged := append([]Record(nil), records...)
+	for i := range others {
+		if k := others[i].key(); !seen[k] {
+			seen[k] = true
+			merged = append(merged, others[i])
+		}
+	}
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].Timestamp.Before(merged[j].Timestamp)
+	})
+	return merged, len(merged) - len(records)
+}
+
+// MarshalHistoryCSV returns the CSV encoding of records separated by `delimiter`, optionally with the header line
+func MarshalHistoryCSV(records []Record, delimiter rune, header bool) ([]byte, error) {
+	return marshalCSV(&records, delimiter, header)
+}
@@ -9,9 +9,17 @@ import (
 type JSONReport struct {
 	Client  defs.IPInfoResponse `json:"client"`
 	Results []Result            `json:"results"`
+	// Tunnel is set when the results likely measure the path of a VPN or proxy, as a server is reached through a
+	// tunnel interface or the public IP belongs to a hosting provider
+	Tunnel bool `json:"tunnel,omitempty"`
+	// Metadata is the version of the metadata bundle classifying the servers, left out for the built-in mappings
+	Metadata string `json:"metadata,omitempty"`
+	// Signature is the signature of the report if signed with --sign-key
+	Signature *Signature `json:"signature,omitempty"`
 }
 
-// Result represents the test's information
+// Result represents the test's information, Upload and Download are in Mbps (10^6 bits per second) regardless of the
+// display units, Ping and Jitter in milliseconds
 type Result struct {
 	ID            string    `json:"id" csv:"ID"`
 	Name          string    `json:"name" csv:"Name"`
@@ -24,6 +32,35 @@ type Result struct {
 	BytesReceived uint64    `json:"bytes_received" csv:"Received"`
 	Ping          float64   `json:"ping" csv:"Ping"`
 	Jitter        float64   `json:"jitter" csv:"Jitter"`
+	Loss          *float64  `json:"loss,omitempty" csv:"-"`
 	Upload        float64   `json:"upload" csv:"Upload"`
 	Download      float64   `json:"download" csv:"Download"`
+	CPU           *CPU      `json:"cpu,omitempty" csv:"-"`
+	// DNS is the time of resolving the server before testing in milliseconds, left out for servers given by IP
+	// address
+	DNS float64 `json:"dns,omitempty" csv:"-"`
+	// Link is the network interface the server is reached through, if its link speed is known
+	Link *defs.Link `json:"link,omitempty" csv:"-"`
+	// CrossTraffic is the other traffic of the network interfaces before the test, if checked
+	CrossTraffic *defs.TrafficRate `json:"cross_traffic,omitempty" csv:"-"`
+	// Confidence is the quality score of the result, for discarding measurements distorted by the device or interference
+	Confidence *Confidence `json:"confidence,omitempty" csv:"-"`
+	// MPTCP is how many connections of the test used Multipath TCP, if enabled with --mptcp
+	MPTCP *defs.MPTCPUsage `json:"mptcp,omitempty" csv:"-"`
+	// Streams is the distribution of the per-connection throughput of the transfer tests
+	Streams *Streams `json:"streams,omitempty" csv:"-"`
+	// Anomaly is set if the result deviated from the baseline of the server with --verify-anomalies
+	Anomaly *Anomaly `json:"anomaly,omitempty" csv:"-"`
+}
+
+// Streams represents the per-connection throughput of the transfer tests
+type Streams struct {
+	Download *defs.StreamRates `json:"download,omitempty"`
+	Upload   *defs.StreamRates `json:"upload,omitempty"`
+}
+
+// CPU represents the CPU utilization during the throughput tests
+type CPU struct {
+	Download *defs.CPUUsage `json:"download,omitempty"`
+	Upload   *defs.CPUUsage `json:"upload,omitempty"`
 }
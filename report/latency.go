@@ -0,0 +1,73 @@
+package report
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// Latency represents the latency distribution of a round of pings of the ping monitor, latencies are in ms
+type Latency struct {
+	ID        string    `json:"id" csv:"ID"`
+	Name      string    `json:"name" csv:"Name"`
+	IP        string    `json:"ip" csv:"IP"`
+	Timestamp time.Time `json:"timestamp" csv:"Timestamp"`
+	Sent      int       `json:"sent" csv:"Sent"`
+	Received  int       `json:"received" csv:"Received"`
+	// Loss is the percentage of pings without reply
+	Loss   float64 `json:"loss" csv:"Loss"`
+	Min    float64 `json:"min" csv:"Min"`
+	Median float64 `json:"median" csv:"Median"`
+	Avg    float64 `json:"avg" csv:"Avg"`
+	P95    float64 `json:"p95" csv:"P95"`
+	Max    float64 `json:"max" csv:"Max"`
+	StdDev float64 `json:"stddev" csv:"StdDev"`
+}
+
+// Distribute fills the distribution fields from the RTTs of `sent` pings, RTTs beyond `sent` are ignored
+func (l *Latency) Distribute(rtts []float64, sent int) {
+	if len(rtts) > sent {
+		rtts = rtts[:sent]
+	}
+	l.Sent, l.Received = sent, len(rtts)
+	if sent > 0 {
+		l.Loss = round(float64(sent-len(rtts)) / float64(sent) * 100)
+	}
+	if len(rtts) == 0 {
+		return
+	}
+
+	sorted := append([]float64(nil), rtts...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	avg := sum / float64(len(sorted))
+	var variance float64
+	for _, v := range sorted {
+		variance += (v - avg) * (v - avg)
+	}
+
+	l.Min, l.Max = round(sorted[0]), round(sorted[len(sorted)-1])
+	l.Median = round(Percentile(sorted, 50))
+	l.P95 = round(Percentile(sorted, 95))
+	l.Avg = round(avg)
+	l.StdDev = round(math.Sqrt(variance / float64(len(sorted))))
+}
+
+// Percentile returns the `p`th percentile of sorted values by linear interpolation
+func Percentile(sorted []float64, p float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	rank := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(rank))
+	hi := min(lo+1, len(sorted)-1)
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(rank-float64(lo))
+}
+
+// MarshalLatenciesCSV returns the CSV encoding of latencies separated by `delimiter`, optionally with the header line
+func MarshalLatenciesCSV(latencies []Latency, delimiter rune, header bool) ([]byte, error) {
+	return marshalCSV(&latencies, delimiter, header)
+}
@@ -0,0 +1,40 @@
+package report
+
+// LegacyResult represents a result in the CSV layout of sivel/speedtest-cli, for scripts expecting exactly that
+// layout. Download and Upload are in bit/s, Ping in milliseconds and IPAddress is the public IP of the client
+type LegacyResult struct {
+	ServerID   string  `csv:"Server ID"`
+	Sponsor    string  `csv:"Sponsor"`
+	ServerName string  `csv:"Server Name"`
+	Timestamp  string  `csv:"Timestamp"`
+	Distance   float64 `csv:"Distance"`
+	Ping       float64 `csv:"Ping"`
+	Download   float64 `csv:"Download"`
+	Upload     float64 `csv:"Upload"`
+	Share      string  `csv:"Share"`
+	IPAddress  string  `csv:"IP Address"`
+}
+
+// legacyTimestamp is the UTC timestamp format of speedtest-cli
+const legacyTimestamp = "2006-01-02T15:04:05.000000Z"
+
+// Legacy returns the result in the layout of speedtest-cli as measured by a client with the public IP `client`. The
+// distance to servers is unknown and always 0, and results are never shared
+func (r Result) Legacy(client string) LegacyResult {
+	return LegacyResult{
+		ServerID:   r.ID,
+		Sponsor:    r.ISP,
+		ServerName: r.Name,
+		Timestamp:  r.Timestamp.UTC().Format(legacyTimestamp),
+		Ping:       r.Ping,
+		Download:   r.Download * 1000000,
+		Upload:     r.Upload * 1000000,
+		IPAddress:  client,
+	}
+}
+
+// MarshalLegacyCSV returns the CSV encoding of results in the layout of speedtest-cli separated by `delimiter`,
+// optionally with the header line
+func MarshalLegacyCSV(results []LegacyResult, delimiter rune, header bool) ([]byte, error) {
+	return marshalCSV(&results, delimiter, header)
+}
@@ -0,0 +1,157 @@
+package report
+
+import (
+	"slices"
+	"sort"
+	"time"
+)
+
+// the number of hours of the day listed as the worst hours of a period
+const worstHours = 3
+
+// Period represents the statistics of the history over a period, e.g. the last week, for sharing with the ISP
+type Period struct {
+	From  time.Time `json:"from"`
+	To    time.Time `json:"to"`
+	Tests int       `json:"tests"`
+	// Download, Upload, Ping and Jitter are the distributions of all results in the period
+	Download Distribution `json:"download"`
+	Upload   Distribution `json:"upload"`
+	Ping     Distribution `json:"ping"`
+	Jitter   Distribution `json:"jitter"`
+	// Servers are the statistics of every server tested against, by the number of tests
+	Servers []Summary `json:"servers"`
+	// Days are the averages of every day of the period, days without results are left out
+	Days []Interval `json:"days"`
+	// WorstHours are the hours of the day with the lowest average download, in local time
+	WorstHours []Interval `json:"worst_hours"`
+}
+
+// Distribution is the average and percentiles of a measurement
+type Distribution struct {
+	Avg    float64 `json:"avg"`
+	Min    float64 `json:"min"`
+	P5     float64 `json:"p5"`
+	Median float64 `json:"median"`
+	P95    float64 `json:"p95"`
+	Max    float64 `json:"max"`
+}
+
+// Interval is the averages of the results in a day, or in an hour of the day
+type Interval struct {
+	Start    time.Time `json:"start"`
+	Tests    int       `json:"tests"`
+	Download float64   `json:"download"`
+	Upload   float64   `json:"upload"`
+	Ping     float64   `json:"ping"`
+}
+
+// SummarizePeriod returns the statistics of the records measured from `from` until `to`, days and hours are in the
+// location of `to`
+func SummarizePeriod(records []Record, from, to time.Time) Period {
+	p := Period{From: from, To: to}
+	var results []Result
+	for _, r := range records {
+		if !r.Timestamp.Before(from) && !r.Timestamp.After(to) {
+			results = append(results, r.Result)
+		}
+	}
+	p.Tests = len(results)
+	if len(results) == 0 {
+		return p
+	}
+
+	var download, upload, ping, jitter []float64
+	servers := make(map[string][]Result)
+	days := make(map[time.Time][]Result)
+	hours := make(map[int][]Result)
+	for _, r := range results {
+		// downloads and uploads which weren't measured, e.g. by scheduled runs with --ping-only, are left out
+		if r.Download > 0 {
+			download = append(download, r.Download)
+		}
+		if r.Upload > 0 {
+			upload = append(upload, r.Upload)
+		}
+		ping = append(ping, r.Ping)
+		jitter = append(jitter, r.Jitter)
+		servers[r.ID] = append(servers[r.ID], r)
+		t := r.Timestamp.In(to.Location())
+		day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+		days[day] = append(days[day], r)
+		hours[t.Hour()] = append(hours[t.Hour()], r)
+	}
+	p.Download, p.Upload = distribute(download), distribute(upload)
+	p.Ping, p.Jitter = distribute(ping), distribute(jitter)
+
+	for _, rs := range servers {
+		p.Servers = append(p.Servers, Summarize(rs))
+	}
+	sort.Slice(p.Servers, func(i, j int) bool {
+		if p.Servers[i].Tests != p.Servers[j].Tests {
+			return p.Servers[i].Tests > p.Servers[j].Tests
+		}
+		return p.Servers[i].ID < p.Servers[j].ID
+	})
+
+	for day, rs := range days {
+		p.Days = append(p.Days, average(day, rs))
+	}
+	sort.Slice(p.Days, func(i, j int) bool { return p.Days[i].Start.Before(p.Days[j].Start) })
+
+	for hour, rs := range hours {
+		p.WorstHours = append(p.WorstHours, average(time.Date(0, 1, 1, hour, 0, 0, 0, to.Location()), rs))
+	}
+	// hours without downloads can't be ranked by their download
+	p.WorstHours = slices.DeleteFunc(p.WorstHours, func(i Interval) bool { return i.Download == 0 })
+	sort.Slice(p.WorstHours, func(i, j int) bool { return p.WorstHours[i].Download < p.WorstHours[j].Download })
+	p.WorstHours = p.WorstHours[:min(len(p.WorstHours), worstHours)]
+	return p
+}
+
+// distribute returns the distribution of values, which is all zero without values
+func distribute(values []float64) Distribution {
+	if len(values) == 0 {
+		return Distribution{}
+	}
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	var sum float64
+	for _, v := range sorted {
+		sum += v
+	}
+	return Distribution{
+		Avg:    round(sum / float64(len(sorted))),
+		Min:    round(sorted[0]),
+		P5:     round(Percentile(sorted, 5)),
+		Median: round(Percentile(sorted, 50)),
+		P95:    round(Percentile(sorted, 95)),
+		Max:    round(sorted[len(sorted)-1]),
+	}
+}
+
+// average returns the averages of the results in the interval starting at `start`, downloads and uploads which
+// weren't measured are left out
+func average(start time.Time, results []Result) Interval {
+	i := Interval{Start: start, Tests: len(results)}
+	var downloads, uploads float64
+	for _, r := range results {
+		if r.Download > 0 {
+			i.Download += r.Download
+			downloads++
+		}
+		if r.Upload > 0 {
+			i.Upload += r.Upload
+			uploads++
+		}
+		i.Ping += r.Ping
+	}
+	if downloads > 0 {
+		i.Download = round(i.Download / downloads)
+	}
+	if uploads > 0 {
+		i.Upload = round(i.Upload / uploads)
+	}
+	i.Ping = round(i.Ping / float64(len(results)))
+	return i
+}
@@ -0,0 +1,20 @@
+package report
+
+import (
+	"time"
+)
+
+// Probe represents the availability of a server checked by the probe mode
+type Probe struct {
+	ID        string    `json:"id" csv:"ID"`
+	Name      string    `json:"name" csv:"Name"`
+	IP        string    `json:"ip" csv:"IP"`
+	Province  string    `json:"province" csv:"Province"`
+	City      string    `json:"city" csv:"City"`
+	ISP       string    `json:"isp" csv:"ISP"`
+	Timestamp time.Time `json:"timestamp" csv:"Timestamp"`
+	Up        bool      `json:"up" csv:"Up"`
+	Ping      float64   `json:"ping" csv:"Ping"`
+	// Availability is the percentage of probes the server was up in, since the probe mode started
+	Availability float64 `json:"availability" csv:"Availability"`
+}
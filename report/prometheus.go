@@ -0,0 +1,103 @@
+package report
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// promMetric is a gauge of the Prometheus text format, in base units as recommended by Prometheus. `value` returns
+// false for results which didn't measure it
+type promMetric struct {
+	name  string
+	help  string
+	value func(r *Result) (float64, bool)
+}
+
+// the gauges written for every result
+var promMetrics = []promMetric{
+	{"download_bits_per_second", "Download speed of the last test in bits per second.", func(r *Result) (float64, bool) {
+		return math.Round(r.Download * 1e6), r.Download > 0
+	}},
+	{"upload_bits_per_second", "Upload speed of the last test in bits per second.", func(r *Result) (float64, bool) {
+		return math.Round(r.Upload * 1e6), r.Upload > 0
+	}},
+	{"ping_seconds", "Ping of the last test in seconds.", func(r *Result) (float64, bool) {
+		return msToSeconds(r.Ping), true
+	}},
+	{"jitter_seconds", "Jitter of the last test in seconds.", func(r *Result) (float64, bool) {
+		return msToSeconds(r.Jitter), true
+	}},
+	{"packet_loss_ratio", "Packet loss of the last test from 0 to 1.", func(r *Result) (float64, bool) {
+		if r.Loss == nil {
+			return 0, false
+		}
+		return math.Round(*r.Loss*100) / 1e4, true
+	}},
+	{"received_bytes", "Bytes received by the last test.", func(r *Result) (float64, bool) {
+		return float64(r.BytesReceived), true
+	}},
+	{"sent_bytes", "Bytes sent by the last test.", func(r *Result) (float64, bool) {
+		return float64(r.BytesSent), true
+	}},
+	{"confidence_score", "Confidence of the last test from 0 to 100.", func(r *Result) (float64, bool) {
+		if r.Confidence == nil {
+			return 0, false
+		}
+		return float64(r.Confidence.Score), true
+	}},
+	{"timestamp_seconds", "Unix time of the last test.", func(r *Result) (float64, bool) {
+		return float64(r.Timestamp.UnixMilli()) / 1000, true
+	}},
+}
+
+// the prefix of the names of all metrics
+const promPrefix = "taierspeed_"
+
+// MarshalPrometheus returns the results of a run at `now` in the Prometheus text format, e.g. for the textfile
+// collector of node_exporter. Every result is labeled by its server, and the time of the run is written even if no
+// server was tested, so that failing runs can be alerted on
+func MarshalPrometheus(results []Result, now time.Time) []byte {
+	var b bytes.Buffer
+	for _, m := range promMetrics {
+		header := false
+		for i := range results {
+			v, ok := m.value(&results[i])
+			if !ok {
+				continue
+			}
+			if !header {
+				fmt.Fprintf(&b, "# HELP %s%s %s\n# TYPE %s%s gauge\n", promPrefix, m.name, m.help, promPrefix, m.name)
+				header = true
+			}
+			fmt.Fprintf(&b, "%s%s%s %s\n", promPrefix, m.name, promLabels(&results[i]), promValue(v))
+		}
+	}
+
+	fmt.Fprintf(&b, "# HELP %slast_run_timestamp_seconds Unix time of the last run.\n", promPrefix)
+	fmt.Fprintf(&b, "# TYPE %slast_run_timestamp_seconds gauge\n", promPrefix)
+	fmt.Fprintf(&b, "%slast_run_timestamp_seconds %s\n", promPrefix, promValue(float64(now.UnixMilli())/1000))
+	fmt.Fprintf(&b, "# HELP %slast_run_results Number of servers tested successfully by the last run.\n", promPrefix)
+	fmt.Fprintf(&b, "# TYPE %slast_run_results gauge\n", promPrefix)
+	fmt.Fprintf(&b, "%slast_run_results %d\n", promPrefix, len(results))
+	return b.Bytes()
+}
+
+// promLabels returns the labels of the server of a result
+func promLabels(r *Result) string {
+	escape := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace
+	return fmt.Sprintf(`{id="%s",name="%s",isp="%s",province="%s"}`, escape(r.ID), escape(r.Name), escape(r.ISP), escape(r.Province))
+}
+
+// msToSeconds converts milliseconds rounded to 2 decimal places to seconds, without the error of the division
+func msToSeconds(ms float64) float64 {
+	return math.Round(ms*100) / 1e5
+}
+
+// promValue formats a value in the shortest decimal representation
+func promValue(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
@@ -0,0 +1,85 @@
+package report
+
+import (
+	"testing"
+	"time"
+)
+
+func TestMarshalPrometheus(t *testing.T) {
+	now := time.Date(2024, 5, 1, 12, 0, 30, 500e6, time.UTC)
+	loss := 1.25
+	tests := []struct {
+		name    string
+		results []Result
+		want    string
+	}{
+		{
+			name: "no results",
+			want: `# HELP taierspeed_last_run_timestamp_seconds Unix time of the last run.
+# TYPE taierspeed_last_run_timestamp_seconds gauge
+taierspeed_last_run_timestamp_seconds 1714564830.5
+# HELP taierspeed_last_run_results Number of servers tested successfully by the last run.
+# TYPE taierspeed_last_run_results gauge
+taierspeed_last_run_results 0
+`,
+		},
+		{
+			name: "results",
+			results: []Result{
+				{
+					ID: "1", Name: "上海电信", ISP: "ct", Province: "上海", Timestamp: now.Add(-time.Minute),
+					Ping: 12.34, Jitter: 0.5, Loss: &loss, Download: 987.654321, Upload: 50,
+					BytesReceived: 1 << 30, BytesSent: 1 << 20, Confidence: &Confidence{Score: 90},
+				},
+				// the ping only test leaves out the speeds, and labels are escaped
+				{ID: "2", Name: `Mock "local"`, Timestamp: now.Add(-time.Minute), Ping: 1, Jitter: 0.01},
+			},
+			want: `# HELP taierspeed_download_bits_per_second Download speed of the last test in bits per second.
+# TYPE taierspeed_download_bits_per_second gauge
+taierspeed_download_bits_per_second{id="1",name="上海电信",isp="ct",province="上海"} 987654321
+# HELP taierspeed_upload_bits_per_second Upload speed of the last test in bits per second.
+# TYPE taierspeed_upload_bits_per_second gauge
+taierspeed_upload_bits_per_second{id="1",name="上海电信",isp="ct",province="上海"} 50000000
+# HELP taierspeed_ping_seconds Ping of the last test in seconds.
+# TYPE taierspeed_ping_seconds gauge
+taierspeed_ping_seconds{id="1",name="上海电信",isp="ct",province="上海"} 0.01234
+taierspeed_ping_seconds{id="2",name="Mock \"local\"",isp="",province=""} 0.001
+# HELP taierspeed_jitter_seconds Jitter of the last test in seconds.
+# TYPE taierspeed_jitter_seconds gauge
+taierspeed_jitter_seconds{id="1",name="上海电信",isp="ct",province="上海"} 0.0005
+taierspeed_jitter_seconds{id="2",name="Mock \"local\"",isp="",province=""} 0.00001
+# HELP taierspeed_packet_loss_ratio Packet loss of the last test from 0 to 1.
+# TYPE taierspeed_packet_loss_ratio gauge
+taierspeed_packet_loss_ratio{id="1",name="上海电信",isp="ct",province="上海"} 0.0125
+# HELP taierspeed_received_bytes Bytes received by the last test.
+# TYPE taierspeed_received_bytes gauge
+taierspeed_received_bytes{id="1",name="上海电信",isp="ct",province="上海"} 1073741824
+taierspeed_received_bytes{id="2",name="Mock \"local\"",isp="",province=""} 0
+# HELP taierspeed_sent_bytes Bytes sent by the last test.
+# TYPE taierspeed_sent_bytes gauge
+taierspeed_sent_bytes{id="1",name="上海电信",isp="ct",province="上海"} 1048576
+taierspeed_sent_bytes{id="2",name="Mock \"local\"",isp="",province=""} 0
+# HELP taierspeed_confidence_score Confidence of the last test from 0 to 100.
+# TYPE taierspeed_confidence_score gauge
+taierspeed_confidence_score{id="1",name="上海电信",isp="ct",province="上海"} 90
+# HELP taierspeed_timestamp_seconds Unix time of the last test.
+# TYPE taierspeed_timestamp_seconds gauge
+taierspeed_timestamp_seconds{id="1",name="上海电信",isp="ct",province="上海"} 1714564770.5
+taierspeed_timestamp_seconds{id="2",name="Mock \"local\"",isp="",province=""} 1714564770.5
+# HELP taierspeed_last_run_timestamp_seconds Unix time of the last run.
+# TYPE taierspeed_last_run_timestamp_seconds gauge
+taierspeed_last_run_timestamp_seconds 1714564830.5
+# HELP taierspeed_last_run_results Number of servers tested successfully by the last run.
+# TYPE taierspeed_last_run_results gauge
+taierspeed_last_run_results 2
+`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := string(MarshalPrometheus(tt.results, now)); got != tt.want {
+				t.Errorf("expected\n%s\ngot\n%s", tt.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package report
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"math"
+
+	"github.com/gocarina/gocsv"
+)
+
+// Comparison is the relative difference of a result to another one in percent, positive values are better for every
+// field, i.e. lower latency and higher speed
+type Comparison struct {
+	Ping     float64 `json:"ping"`
+	Jitter   float64 `json:"jitter"`
+	Download float64 `json:"download"`
+	Upload   float64 `json:"upload"`
+}
+
+// Round rounds the measurements to 2 decimal places, as shown in all outputs
+func (r *Result) Round() {
+	r.DNS = round(r.DNS)
+	r.Ping = round(r.Ping)
+	r.Jitter = round(r.Jitter)
+	if r.Loss != nil {
+		loss := round(*r.Loss)
+		r.Loss = &loss
+	}
+	r.Download = round(r.Download)
+	r.Upload = round(r.Upload)
+}
+
+// Merge returns the average of this result and `others`, e.g. of several runs against the same server. Server fields
+// are taken from this result, the timestamp from the latest one and transferred bytes are summed up. Packet loss is
+// averaged over the results measuring it
+func (r Result) Merge(others ...Result) Result {
+	merged := r
+	var loss float64
+	var losses int
+	if r.Loss != nil {
+		loss, losses = *r.Loss, 1
+	}
+	for _, o := range others {
+		if o.Loss != nil {
+			loss += *o.Loss
+			losses++
+		}
+		merged.Ping += o.Ping
+		merged.Jitter += o.Jitter
+		merged.Download += o.Download
+		merged.Upload += o.Upload
+		merged.BytesSent += o.BytesSent
+		merged.BytesReceived += o.BytesReceived
+		if o.Timestamp.After(merged.Timestamp) {
+			merged.Timestamp = o.Timestamp
+		}
+	}
+
+	n := float64(len(others) + 1)
+	merged.Ping /= n
+	merged.Jitter /= n
+	merged.Download /= n
+	merged.Upload /= n
+	merged.CPU = nil
+	merged.Confidence = nil
+	merged.MPTCP = nil
+	merged.Streams = nil
+	merged.Anomaly = nil
+	merged.DNS = 0
+	if losses > 0 {
+		loss /= float64(losses)
+		merged.Loss = &loss
+	}
+	merged.Round()
+
+	return merged
+}
+
+// Compare returns the relative difference of this result to `base`, in percent of `base` for every field. Lower
+// latencies count as positive
+func (r Result) Compare(base Result) Comparison {
+	return Comparison{
+		Ping:     round(decrease(r.Ping, base.Ping)),
+		Jitter:   round(decrease(r.Jitter, base.Jitter)),
+		Download: round(relative(r.Download, base.Download)),
+		Upload:   round(relative(r.Upload, base.Upload)),
+	}
+}
+
+// Marshal returns the JSON encoding of the report
+func (r *JSONReport) Marshal() ([]byte, error) {
+	return json.Marshal(r)
+}
+
+// MarshalCSV returns the CSV encoding of results separated by `delimiter`, optionally with the header line
+func MarshalCSV(results []Result, delimiter rune, header bool) ([]byte, error) {
+	return marshalCSV(&results, delimiter, header)
+}
+
+// MarshalProbesCSV returns the CSV encoding of probes separated by `delimiter`, optionally with the header line
+func MarshalProbesCSV(probes []Probe, delimiter rune, header bool) ([]byte, error) {
+	return marshalCSV(&probes, delimiter, header)
+}
+
+// marshalCSV returns the CSV encoding of a pointer to a slice
+func marshalCSV(in interface{}, delimiter rune, header bool) ([]byte, error) {
+	var buf bytes.Buffer
+	w := csv.NewWriter(&buf)
+	w.Comma = delimiter
+
+	var err error
+	if header {
+		err = gocsv.MarshalCSV(in, gocsv.NewSafeCSVWriter(w))
+	} else {
+		err = gocsv.MarshalCSVWithoutHeaders(in, gocsv.NewSafeCSVWriter(w))
+	}
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// relative returns how much `a` is larger than `b` in percent
+func relative(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return (a - b) / b * 100
+}
+
+// decrease returns how much `a` is lower than `b` in percent of `b`
+func decrease(a, b float64) float64 {
+	if b == 0 {
+		return 0
+	}
+	return (b - a) / b * 100
+}
+
+// round rounds a value to 2 decimal places
+func round(v float64) float64 {
+	return math.Round(v*100) / 100
+}
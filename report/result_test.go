@@ -0,0 +1,83 @@
+package report
+
+import (
+	"testing"
+	"time"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+)
+
+func TestMerge(t *testing.T) {
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	loss := func(v float64) *float64 { return &v }
+	tests := []struct {
+		name   string
+		first  Result
+		others []Result
+		want   Result
+	}{
+		{
+			name:  "single",
+			first: Result{ID: "1", Ping: 1.234, Download: 100.006, Loss: loss(0.125)},
+			want:  Result{ID: "1", Ping: 1.23, Download: 100.01, Loss: loss(0.13)},
+		},
+		{
+			name: "averaged",
+			first: Result{
+				ID: "1", Name: "first", Timestamp: start, Ping: 10, Jitter: 1, Download: 100, Upload: 10,
+				BytesReceived: 1000, BytesSent: 100, DNS: 5,
+				CPU:        &CPU{Download: &defs.CPUUsage{Process: 95}},
+				Confidence: &Confidence{Score: 50},
+			},
+			others: []Result{
+				{ID: "2", Name: "second", Timestamp: start.Add(2 * time.Minute), Ping: 20, Jitter: 2, Download: 200, Upload: 20, BytesReceived: 2000, BytesSent: 200},
+				{ID: "3", Name: "third", Timestamp: start.Add(time.Minute), Ping: 15, Jitter: 1.5, Download: 150, Upload: 15, BytesReceived: 3000, BytesSent: 300},
+			},
+			want: Result{
+				ID: "1", Name: "first", Timestamp: start.Add(2 * time.Minute), Ping: 15, Jitter: 1.5, Download: 150, Upload: 15,
+				BytesReceived: 6000, BytesSent: 600,
+			},
+		},
+		{
+			name:   "loss of some",
+			first:  Result{Ping: 10},
+			others: []Result{{Ping: 10, Loss: loss(1)}, {Ping: 10, Loss: loss(2)}},
+			want:   Result{Ping: 10, Loss: loss(1.5)},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.first.Merge(tt.others...)
+			if (got.Loss == nil) != (tt.want.Loss == nil) || (got.Loss != nil && *got.Loss != *tt.want.Loss) {
+				t.Errorf("expected loss %v, got %v", tt.want.Loss, got.Loss)
+			}
+			got.Loss, tt.want.Loss = nil, nil
+			if got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestCompare(t *testing.T) {
+	base := Result{Ping: 20, Jitter: 2, Download: 100, Upload: 50}
+	tests := []struct {
+		name   string
+		result Result
+		base   Result
+		want   Comparison
+	}{
+		{"same", base, base, Comparison{}},
+		{"better", Result{Ping: 10, Jitter: 1, Download: 150, Upload: 100}, base, Comparison{Ping: 50, Jitter: 50, Download: 50, Upload: 100}},
+		{"worse", Result{Ping: 30, Jitter: 3, Download: 75, Upload: 40}, base, Comparison{Ping: -50, Jitter: -50, Download: -25, Upload: -20}},
+		{"rounded", Result{Ping: 20, Jitter: 2, Download: 100.0001, Upload: 50}, Result{Ping: 3, Jitter: 2, Download: 3, Upload: 50}, Comparison{Ping: -566.67, Download: 3233.34}},
+		{"no base", base, Result{}, Comparison{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.result.Compare(tt.base); got != tt.want {
+				t.Errorf("expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
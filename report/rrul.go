@@ -0,0 +1,42 @@
+package report
+
+import (
+	"time"
+)
+
+// RRUL represents the result of a Realtime Response Under Load test, with download and upload flows running
+// simultaneously while the latency is probed. Rates are in Mbps and latencies in ms
+type RRUL struct {
+	ID        string    `json:"id" csv:"ID"`
+	Name      string    `json:"name" csv:"Name"`
+	IP        string    `json:"ip" csv:"IP"`
+	Timestamp time.Time `json:"timestamp" csv:"Timestamp"`
+	// Flows is the number of flows in each direction
+	Flows    int     `json:"flows" csv:"Flows"`
+	Download float64 `json:"download" csv:"Download"`
+	Upload   float64 `json:"upload" csv:"Upload"`
+	Total    float64 `json:"total" csv:"Total"`
+	// IdlePing is the median latency before the load, the other latencies are measured under load
+	IdlePing float64 `json:"idle_ping" csv:"IdlePing"`
+	Ping     float64 `json:"ping" csv:"Ping"`
+	P95      float64 `json:"p95" csv:"P95"`
+	Max      float64 `json:"max" csv:"Max"`
+	Jitter   float64 `json:"jitter" csv:"Jitter"`
+	Loss     float64 `json:"loss" csv:"Loss"`
+	// Increase is the latency induced by the load, and Grade its bufferbloat grade from A+ to F
+	Increase float64 `json:"increase" csv:"Increase"`
+	Grade    string  `json:"grade" csv:"Grade"`
+}
+
+// Round rounds the rates and the latency increase to 2 decimal places, as shown in all outputs
+func (r *RRUL) Round() {
+	r.Download = round(r.Download)
+	r.Upload = round(r.Upload)
+	r.Total = round(r.Total)
+	r.Increase = round(r.Increase)
+}
+
+// MarshalRRULCSV returns the CSV encoding of RRUL results separated by `delimiter`, optionally with the header line
+func MarshalRRULCSV(results []RRUL, delimiter rune, header bool) ([]byte, error) {
+	return marshalCSV(&results, delimiter, header)
+}
@@ -0,0 +1,173 @@
+package report
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+)
+
+// the algorithms of signatures
+const (
+	SignHMAC    = "hmac-sha256"
+	SignEd25519 = "ed25519"
+)
+
+var (
+	// ErrUnsigned is returned when verifying a report without signature
+	ErrUnsigned = errors.New("report is not signed")
+	// ErrBadSignature is returned when the signature of a report doesn't match its content or the key
+	ErrBadSignature = errors.New("signature mismatch")
+)
+
+// Signature is the signature of a JSON report, over the report without the signature in canonical form: the keys of
+// all objects sorted and no whitespace. KeyID identifies the public key of ed25519 signatures
+type Signature struct {
+	Algorithm string `json:"algorithm"`
+	KeyID     string `json:"key_id,omitempty"`
+	Value     string `json:"value"`
+}
+
+// SigningKey is a secret for HMAC-SHA256 signatures, or an ed25519 key. Reports can only be signed with the private
+// key of ed25519 keys, and verified with either key
+type SigningKey struct {
+	secret  []byte
+	private ed25519.PrivateKey
+	public  ed25519.PublicKey
+}
+
+// ParseSigningKey parses an ed25519 private or public key in PEM, as generated by
+// `openssl genpkey -algorithm ed25519`, any other content is used as the secret of HMAC-SHA256
+func ParseSigningKey(b []byte) (*SigningKey, error) {
+	block, _ := pem.Decode(b)
+	if block == nil {
+		secret := bytes.TrimSpace(b)
+		if len(secret) == 0 {
+			return nil, errors.New("empty key")
+		}
+		return &SigningKey{secret: secret}, nil
+	}
+
+	switch block.Type {
+	case "PRIVATE KEY":
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		private, ok := key.(ed25519.PrivateKey)
+		if !ok {
+			return nil, errors.New("not an ed25519 key")
+		}
+		return &SigningKey{private: private, public: private.Public().(ed25519.PublicKey)}, nil
+	case "PUBLIC KEY":
+		key, err := x509.ParsePKIXPublicKey(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		public, ok := key.(ed25519.PublicKey)
+		if !ok {
+			return nil, errors.New("not an ed25519 key")
+		}
+		return &SigningKey{public: public}, nil
+	default:
+		return nil, errors.New("unsupported PEM block " + block.Type)
+	}
+}
+
+// keyID returns the ID of ed25519 keys, the first 8 bytes of the SHA-256 of the public key
+func (k *SigningKey) keyID() string {
+	if k.public == nil {
+		return ""
+	}
+	sum := sha256.Sum256(k.public)
+	return hex.EncodeToString(sum[:8])
+}
+
+// Sign signs the report, replacing its signature if any
+func (r *JSONReport) Sign(key *SigningKey) error {
+	r.Signature = nil
+	b, err := r.Marshal()
+	if err != nil {
+		return err
+	}
+	body, err := canonical(b)
+	if err != nil {
+		return err
+	}
+
+	sig := &Signature{KeyID: key.keyID()}
+	switch {
+	case key.secret != nil:
+		mac := hmac.New(sha256.New, key.secret)
+		mac.Write(body)
+		sig.Algorithm, sig.Value = SignHMAC, base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	case key.private != nil:
+		sig.Algorithm, sig.Value = SignEd25519, base64.StdEncoding.EncodeToString(ed25519.Sign(key.private, body))
+	default:
+		return errors.New("signing needs the private key")
+	}
+	r.Signature = sig
+	return nil
+}
+
+// VerifyReport checks the signature of the JSON report `b` with `key`, and returns the signature if it matches
+func VerifyReport(b []byte, key *SigningKey) (*Signature, error) {
+	var doc map[string]json.RawMessage
+	if err := json.Unmarshal(b, &doc); err != nil {
+		return nil, err
+	}
+	raw, ok := doc["signature"]
+	if !ok {
+		return nil, ErrUnsigned
+	}
+	var sig Signature
+	if err := json.Unmarshal(raw, &sig); err != nil {
+		return nil, err
+	}
+	delete(doc, "signature")
+	unsigned, err := json.Marshal(doc)
+	if err != nil {
+		return nil, err
+	}
+	body, err := canonical(unsigned)
+	if err != nil {
+		return nil, err
+	}
+	value, err := base64.StdEncoding.DecodeString(sig.Value)
+	if err != nil {
+		return nil, ErrBadSignature
+	}
+
+	switch {
+	case sig.Algorithm == SignHMAC && key.secret != nil:
+		mac := hmac.New(sha256.New, key.secret)
+		mac.Write(body)
+		ok = hmac.Equal(value, mac.Sum(nil))
+	case sig.Algorithm == SignEd25519 && key.public != nil:
+		ok = ed25519.Verify(key.public, body, value)
+	default:
+		return nil, errors.New("key doesn't match the algorithm " + sig.Algorithm)
+	}
+	if !ok {
+		return nil, ErrBadSignature
+	}
+	return &sig, nil
+}
+
+// canonical returns the canonical form of a JSON document, with the keys of all objects sorted and no whitespace.
+// Numbers are kept as written, so they don't change by a round trip through floats
+func canonical(b []byte) ([]byte, error) {
+	dec := json.NewDecoder(bytes.NewReader(b))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
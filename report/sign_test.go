@@ -0,0 +1,137 @@
+package report
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+)
+
+// testKeys returns the private and public key in PEM of a new ed25519 key
+func testKeys(t *testing.T) (private, public []byte) {
+	t.Helper()
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	der, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		t.Fatalf("failed to marshal private key: %s", err)
+	}
+	private = pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: der})
+	if der, err = x509.MarshalPKIXPublicKey(pub); err != nil {
+		t.Fatalf("failed to marshal public key: %s", err)
+	}
+	return private, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: der})
+}
+
+// signedReport returns a report signed with `key` in JSON
+func signedReport(t *testing.T, key []byte) []byte {
+	t.Helper()
+	signing, err := ParseSigningKey(key)
+	if err != nil {
+		t.Fatalf("failed to parse key: %s", err)
+	}
+	loss := 0.5
+	rep := JSONReport{Results: []Result{{
+		ID:        "1",
+		Name:      "上海电信",
+		Timestamp: time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC),
+		Ping:      12.34,
+		Loss:      &loss,
+		Download:  987.65,
+		Upload:    0.1,
+	}}}
+	if err := rep.Sign(signing); err != nil {
+		t.Fatalf("failed to sign: %s", err)
+	}
+	b, err := rep.Marshal()
+	if err != nil {
+		t.Fatalf("failed to marshal: %s", err)
+	}
+	return b
+}
+
+func TestVerifyReport(t *testing.T) {
+	private, public := testKeys(t)
+	_, otherPublic := testKeys(t)
+
+	tests := []struct {
+		name   string
+		sign   []byte
+		verify []byte
+		edit   func(b []byte) []byte
+		want   error
+	}{
+		{name: "hmac", sign: []byte("secret\n"), verify: []byte("secret")},
+		{name: "ed25519", sign: private, verify: public},
+		{name: "ed25519 with private key", sign: private, verify: private},
+		{name: "reformatted", sign: private, verify: public, edit: func(b []byte) []byte {
+			var buf bytes.Buffer
+			json.Indent(&buf, b, "", "  ")
+			return buf.Bytes()
+		}},
+		{name: "tampered hmac", sign: []byte("secret"), verify: []byte("secret"), edit: func(b []byte) []byte {
+			return bytes.Replace(b, []byte("987.65"), []byte("997.65"), 1)
+		}, want: ErrBadSignature},
+		{name: "tampered ed25519", sign: private, verify: public, edit: func(b []byte) []byte {
+			return bytes.Replace(b, []byte(`"ping":12.34`), []byte(`"ping":2.34`), 1)
+		}, want: ErrBadSignature},
+		{name: "other secret", sign: []byte("secret"), verify: []byte("guess"), want: ErrBadSignature},
+		{name: "other key", sign: private, verify: otherPublic, want: ErrBadSignature},
+		{name: "unsigned", sign: []byte("secret"), verify: []byte("secret"), edit: func(b []byte) []byte {
+			var doc map[string]json.RawMessage
+			json.Unmarshal(b, &doc)
+			delete(doc, "signature")
+			b, _ = json.Marshal(doc)
+			return b
+		}, want: ErrUnsigned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b := signedReport(t, tt.sign)
+			if tt.edit != nil {
+				b = tt.edit(b)
+			}
+			key, err := ParseSigningKey(tt.verify)
+			if err != nil {
+				t.Fatalf("failed to parse key: %s", err)
+			}
+			sig, err := VerifyReport(b, key)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("expected %v, got %v", tt.want, err)
+			}
+			if err == nil && sig.Algorithm == SignEd25519 && sig.KeyID != key.keyID() {
+				t.Errorf("expected key ID %s, got %s", key.keyID(), sig.KeyID)
+			}
+		})
+	}
+}
+
+func TestSignWithPublicKey(t *testing.T) {
+	_, public := testKeys(t)
+	key, err := ParseSigningKey(public)
+	if err != nil {
+		t.Fatalf("failed to parse key: %s", err)
+	}
+	if err := (&JSONReport{}).Sign(key); err == nil {
+		t.Error("expected signing with a public key to fail")
+	}
+}
+
+func TestVerifyReportAlgorithmMismatch(t *testing.T) {
+	private, _ := testKeys(t)
+	key, err := ParseSigningKey([]byte("secret"))
+	if err != nil {
+		t.Fatalf("failed to parse key: %s", err)
+	}
+	// an ed25519 signature can't be checked with a secret, which is no signature mismatch
+	if _, err := VerifyReport(signedReport(t, private), key); err == nil || errors.Is(err, ErrBadSignature) {
+		t.Errorf("expected an algorithm mismatch, got %v", err)
+	}
+}
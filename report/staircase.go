@@ -0,0 +1,30 @@
+package report
+
+// Step represents a step of the staircase load test, the latency under a load offered at a share of the measured
+// capacity. Rates are in Mbps and latencies in ms
+type Step struct {
+	ID        string `json:"id" csv:"ID"`
+	Name      string `json:"name" csv:"Name"`
+	Direction string `json:"direction" csv:"Direction"`
+	// Load is the offered load in percent of the capacity, the idle latency is measured at zero load
+	Load    float64 `json:"load" csv:"Load"`
+	Offered float64 `json:"offered" csv:"Offered"`
+	Rate    float64 `json:"rate" csv:"Rate"`
+	Median  float64 `json:"median" csv:"Median"`
+	P95     float64 `json:"p95" csv:"P95"`
+	Loss    float64 `json:"loss" csv:"Loss"`
+	// Increase is the increase of the median latency over the idle latency
+	Increase float64 `json:"increase" csv:"Increase"`
+}
+
+// Round rounds the rates and the latency increase to 2 decimal places, as shown in all outputs
+func (s *Step) Round() {
+	s.Offered = round(s.Offered)
+	s.Rate = round(s.Rate)
+	s.Increase = round(s.Increase)
+}
+
+// MarshalStepsCSV returns the CSV encoding of steps separated by `delimiter`, optionally with the header line
+func MarshalStepsCSV(steps []Step, delimiter rune, header bool) ([]byte, error) {
+	return marshalCSV(&steps, delimiter, header)
+}
@@ -0,0 +1,88 @@
+package report
+
+import (
+	"math"
+	"time"
+)
+
+// Summary represents the statistics of several results of a server
+type Summary struct {
+	ID       string    `json:"id" csv:"ID"`
+	Name     string    `json:"name" csv:"Name"`
+	From     time.Time `json:"from" csv:"From"`
+	To       time.Time `json:"to" csv:"To"`
+	Tests    int       `json:"tests" csv:"Tests"`
+	Failures int       `json:"failures" csv:"Failures"`
+	Ping     float64   `json:"ping" csv:"Ping"`
+	Jitter   float64   `json:"jitter" csv:"Jitter"`
+	Download Range     `json:"download" csv:"Download"`
+	Upload   Range     `json:"upload" csv:"Upload"`
+}
+
+// Range is the average, minimum and maximum of a measurement
+type Range struct {
+	Avg float64 `json:"avg" csv:"Avg"`
+	Min float64 `json:"min" csv:"Min"`
+	Max float64 `json:"max" csv:"Max"`
+	// n is the number of values added
+	n int
+}
+
+// Summarize returns the statistics of results, server fields are taken from the first result. Downloads and uploads
+// which weren't measured, e.g. with --no-upload, are left out
+func Summarize(results []Result) Summary {
+	var s Summary
+	if len(results) == 0 {
+		return s
+	}
+
+	s.ID, s.Name = results[0].ID, results[0].Name
+	s.From, s.To = results[0].Timestamp, results[0].Timestamp
+	s.Tests = len(results)
+	s.Download.Min, s.Upload.Min = math.Inf(1), math.Inf(1)
+	for _, r := range results {
+		if r.Timestamp.Before(s.From) {
+			s.From = r.Timestamp
+		}
+		if r.Timestamp.After(s.To) {
+			s.To = r.Timestamp
+		}
+		s.Ping += r.Ping
+		s.Jitter += r.Jitter
+		if r.Download > 0 {
+			s.Download.add(r.Download)
+		}
+		if r.Upload > 0 {
+			s.Upload.add(r.Upload)
+		}
+	}
+
+	n := float64(len(results))
+	s.Ping = round(s.Ping / n)
+	s.Jitter = round(s.Jitter / n)
+	s.Download.finish()
+	s.Upload.finish()
+	return s
+}
+
+// add accounts a value, summing up the average
+func (r *Range) add(v float64) {
+	r.n++
+	r.Avg += v
+	r.Min = math.Min(r.Min, v)
+	r.Max = math.Max(r.Max, v)
+}
+
+// finish turns the sum of the values added into their average, a range without values is all zero
+func (r *Range) finish() {
+	if r.n == 0 {
+		*r = Range{}
+		return
+	}
+	r.Avg = round(r.Avg / float64(r.n))
+}
+
+// MarshalSummariesCSV returns the CSV encoding of summaries separated by `delimiter`, optionally with the header line
+func MarshalSummariesCSV(summaries []Summary, delimiter rune, header bool) ([]byte, error) {
+	return marshalCSV(&summaries, delimiter, header)
+}
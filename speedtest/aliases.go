@@ -0,0 +1,157 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+)
+
+// the file of the server aliases assigned with the alias command in defs.DataDir, a JSON object of aliases to server
+// IDs or HOST:PORT of servers hosted with `serve`
+const aliasesFile = "aliases.json"
+
+// aliasesPath returns the path of the assigned server aliases
+func aliasesPath() (string, error) {
+	dir, err := defs.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, aliasesFile), nil
+}
+
+// readAliases returns the assigned server aliases, which are empty if none is assigned yet
+func readAliases() (map[string]string, error) {
+	path, err := aliasesPath()
+	if err != nil {
+		return nil, err
+	}
+	aliases := make(map[string]string)
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return aliases, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(b, &aliases); err != nil {
+		return nil, err
+	}
+	return aliases, nil
+}
+
+// writeAliases replaces the assigned server aliases
+func writeAliases(aliases map[string]string) error {
+	path, err := aliasesPath()
+	if err != nil {
+		return err
+	}
+	b, err := json.MarshalIndent(aliases, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, append(b, '\n'))
+}
+
+// loadAliases adds the assigned server aliases to defs.ServerAliases, overriding the aliases of the metadata bundle
+func loadAliases() {
+	aliases, err := readAliases()
+	if err != nil {
+		log.Warnf(i18n.T("Failed to read the server aliases: %s"), err)
+		return
+	}
+	for alias, id := range aliases {
+		defs.ServerAliases[alias] = id
+	}
+}
+
+// serverAlias returns the server ID of an alias given by --server, other values are returned as is
+func serverAlias(s string) string {
+	if id, ok := defs.ServerAliases[s]; ok {
+		return id
+	}
+	return s
+}
+
+// serverAliases returns the server IDs of the aliases in `ids` like serverAlias
+func serverAliases(ids []string) []string {
+	ret := make([]string, len(ids))
+	for i, s := range ids {
+		ret[i] = serverAlias(s)
+	}
+	return ret
+}
+
+// AliasSet assigns the alias given as the first argument to the server ID or HOST:PORT given as the second one
+func AliasSet(c *cli.Context) error {
+	if err := setLang(c); err != nil {
+		return err
+	}
+	alias, id := c.Args().Get(0), c.Args().Get(1)
+	if c.NArg() != 2 || alias == "" || id == "" {
+		log.Errorf(i18n.T("An alias and a server ID are needed: %q is given"), c.Args().Slice())
+		return errors.New("invalid alias setting")
+	}
+	aliases, err := readAliases()
+	if err != nil {
+		log.Errorf(i18n.T("Failed to read the server aliases: %s"), err)
+		return err
+	}
+	aliases[alias] = id
+	if err := writeAliases(aliases); err != nil {
+		log.Errorf(i18n.T("Failed to write the server aliases: %s"), err)
+		return err
+	}
+	return nil
+}
+
+// AliasRemove removes the aliases given as arguments
+func AliasRemove(c *cli.Context) error {
+	if err := setLang(c); err != nil {
+		return err
+	}
+	aliases, err := readAliases()
+	if err != nil {
+		log.Errorf(i18n.T("Failed to read the server aliases: %s"), err)
+		return err
+	}
+	for _, alias := range c.Args().Slice() {
+		if _, ok := aliases[alias]; !ok {
+			log.Warnf(i18n.T("Alias %s is not assigned"), alias)
+		}
+		delete(aliases, alias)
+	}
+	if err := writeAliases(aliases); err != nil {
+		log.Errorf(i18n.T("Failed to write the server aliases: %s"), err)
+		return err
+	}
+	return nil
+}
+
+// AliasList prints the assigned server aliases
+func AliasList(c *cli.Context) error {
+	if err := setLang(c); err != nil {
+		return err
+	}
+	aliases, err := readAliases()
+	if err != nil {
+		log.Errorf(i18n.T("Failed to read the server aliases: %s"), err)
+		return err
+	}
+	names := make([]string, 0, len(aliases))
+	for alias := range aliases {
+		names = append(names, alias)
+	}
+	sort.Strings(names)
+	for _, alias := range names {
+		fmt.Printf("%s\t%s\n", alias, aliases[alias])
+	}
+	return nil
+}
@@ -0,0 +1,91 @@
+package speedtest
+
+import (
+	"fmt"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+// the names of the measurements checked for anomalies
+var metricNames = map[string]string{
+	report.MetricPing:     "Ping",
+	report.MetricDownload: "Download",
+	report.MetricUpload:   "Upload",
+}
+
+// verifyAnomaly checks the result `rep` of `server` against the baseline of the server in the history, and tests again
+// if it's worse by more than ui.verifyAnomalies percent, against ui.verifyServer if given. Returns nil if the result
+// isn't anomalous or there are too few results of the server in the history
+func verifyAnomaly(c *cli.Context, server defs.Server, rep report.Result, history []report.Record, opts *Options, ui *uiOptions, progress *cliProgress) *report.Anomaly {
+	base, ok := report.BaselineOf(history, rep.ID)
+	if !ok {
+		log.Debugf("Not checking for anomalies with %d results of %s in the history", base.Tests, rep.ID)
+		return nil
+	}
+	deviation, metrics := rep.Deviate(base, ui.verifyAnomalies)
+	if len(metrics) == 0 {
+		return nil
+	}
+	anomaly := &report.Anomaly{Threshold: ui.verifyAnomalies, Baseline: base, Deviation: deviation, Metrics: metrics}
+	names := make([]string, len(metrics))
+	for i, m := range metrics {
+		names[i] = i18n.T(metricNames[m])
+	}
+	log.Warnf(i18n.T("%s worse than the median of the last %d results by more than %s%%, testing again"),
+		strings.Join(names, ", "), base.Tests, i18n.Number(ui.verifyAnomalies, 0))
+
+	if ui.verifyServer != "" {
+		other, err := lookupServer(c.Context, directServerType(c), opts, ui.verifyServer)
+		if err != nil {
+			log.Errorf(i18n.T("Can't test again against %s: %s"), ui.verifyServer, err)
+			return anomaly
+		}
+		server = other
+		if !ui.silent || ui.simple {
+			printServer(server)
+		}
+	}
+	retest, err := runServer(c.Context, server, opts)
+	progress.stop()
+	if err != nil {
+		log.Errorf(i18n.T("Can't test again against %s: %s"), server.ID, err)
+		return anomaly
+	}
+	anomaly.Retest = &retest
+
+	// another server is compared against its own baseline, as servers differ in speed
+	if retest.ID != rep.ID {
+		var ok bool
+		if base, ok = report.BaselineOf(history, retest.ID); !ok {
+			log.Debugf("Not judging the re-test with %d results of %s in the history", base.Tests, retest.ID)
+			return anomaly
+		}
+	}
+	_, deviated := retest.Deviate(base, ui.verifyAnomalies)
+	reproduced := false
+	for _, m := range deviated {
+		reproduced = reproduced || contains(metrics, m)
+	}
+	anomaly.Reproduced = &reproduced
+	return anomaly
+}
+
+// formatAnomaly describes whether the re-test of an anomalous result reproduced the deviation
+func formatAnomaly(a *report.Anomaly) string {
+	switch {
+	case a.Retest == nil:
+		return i18n.T("not verified")
+	case a.Reproduced == nil:
+		return fmt.Sprintf(i18n.T("not judged, too few results of %s in the history"), i18n.Name(a.Retest.Name))
+	case *a.Reproduced:
+		return fmt.Sprintf(i18n.T("reproduced against %s"), i18n.Name(a.Retest.Name))
+	default:
+		return fmt.Sprintf(i18n.T("not reproduced against %s, likely a one-off"), i18n.Name(a.Retest.Name))
+	}
+}
@@ -0,0 +1,49 @@
+package speedtest
+
+import (
+	"github.com/fatih/color"
+)
+
+// thresholds of colored results, better results are green, fair ones yellow and worse ones red
+const (
+	goodLatency  = 50
+	fairLatency  = 150
+	goodDownload = 100
+	fairDownload = 10
+	goodUpload   = 20
+	fairUpload   = 5
+	goodLoss     = 1
+	fairLoss     = 5
+)
+
+var (
+	colorGood = color.New(color.FgGreen).SprintFunc()
+	colorFair = color.New(color.FgYellow).SprintFunc()
+	colorPoor = color.New(color.FgRed).SprintFunc()
+	// colorDim is for metadata next to the results
+	colorDim = color.New(color.Faint).SprintFunc()
+)
+
+// gradeHigh colors `s` by `val` where higher values are better
+func gradeHigh(s string, val, good, fair float64) string {
+	switch {
+	case val >= good:
+		return colorGood(s)
+	case val >= fair:
+		return colorFair(s)
+	default:
+		return colorPoor(s)
+	}
+}
+
+// gradeLow colors `s` by `val` where lower values are better
+func gradeLow(s string, val, good, fair float64) string {
+	switch {
+	case val < good:
+		return colorGood(s)
+	case val < fair:
+		return colorFair(s)
+	default:
+		return colorPoor(s)
+	}
+}
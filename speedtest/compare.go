@@ -0,0 +1,121 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+// compareMode tests the two servers given by --server of the compare command in --rounds interleaved rounds, swapping
+// which server goes first every round so that changes of the network over time affect both alike. The means of both
+// servers are compared with Welch's t-test, telling whether one is better beyond the variation between rounds
+func compareMode(c *cli.Context, opts *Options, ui *uiOptions) error {
+	rounds := c.Int(defs.OptionRounds)
+	if rounds < 2 {
+		log.Errorf(i18n.T("Rounds must be at least 2: %d is given"), rounds)
+		return errors.New("invalid rounds setting")
+	}
+
+	servers, err := resolveServers(c, opts)
+	if err != nil {
+		return err
+	}
+	if len(servers) != 2 {
+		log.Errorf(i18n.T("Comparing needs exactly 2 servers: %d are given"), len(servers))
+		return errors.New("invalid server setting")
+	}
+	up, _ := checkServers(c.Context, servers, opts, 2)
+	for idx, server := range servers {
+		if !up[idx] {
+			log.Errorf(i18n.T("Selected server %s (%s) is not responding at the moment, try again later"), server.Name, server.ID)
+			return ErrServerDown
+		}
+	}
+
+	human := !c.Bool(defs.OptionCSV) && !c.Bool(defs.OptionJSON)
+	labels := []string{"A", "B"}
+	progress := newProgress(ui)
+	progress.attach(opts)
+	if human {
+		for idx, server := range servers {
+			fmt.Printf(i18n.T("Server %s:\t%s (id = %s)\n"), labels[idx], i18n.Name(server.Name), server.ID)
+		}
+	}
+
+	results := make([][]report.Result, 2)
+	failures := make([]int, 2)
+	for n := 0; n < rounds; n++ {
+		for i := 0; i < 2; i++ {
+			// A goes first in even rounds and B in odd ones
+			idx := (n + i) % 2
+			server := servers[idx]
+			rep, err := runServer(c.Context, server, opts)
+			progress.stop()
+			if c.Context.Err() != nil {
+				return nil
+			}
+			if err != nil {
+				failures[idx]++
+				log.Warnf(i18n.T("Test against %s (%s) failed: %s"), server.Name, server.ID, err)
+				continue
+			}
+			results[idx] = append(results[idx], rep)
+			if human {
+				fmt.Printf(i18n.T("Round %d/%d %s:\t%s\n"), n+1, rounds, labels[idx], progress.brief(rep))
+			}
+		}
+	}
+
+	out := report.CompareServers(results[0], results[1], failures[0], failures[1])
+	switch {
+	case c.Bool(defs.OptionCSV):
+		delimiter := []rune(c.String(defs.OptionCSVDelimiter))[0]
+		if b, err := report.MarshalSummariesCSV([]report.Summary{out.A, out.B}, delimiter, true); err != nil {
+			log.Errorf(i18n.T("Error generating CSV report: %s"), err)
+		} else {
+			os.Stdout.Write(b)
+		}
+	case c.Bool(defs.OptionJSON):
+		if b, err := json.Marshal(out); err != nil {
+			log.Errorf(i18n.T("Error generating JSON report: %s"), err)
+		} else {
+			os.Stdout.Write(append(b, '\n'))
+		}
+	default:
+		fmt.Println()
+		printDifference(i18n.T("Ping:\t\tA %s, B %s, %s (p = %s)\n"), i18n.Number(out.A.Ping, 2)+" ms", i18n.Number(out.B.Ping, 2)+" ms", out.Ping)
+		printDifference(i18n.T("Jitter:\t\tA %s, B %s, %s (p = %s)\n"), i18n.Number(out.A.Jitter, 2)+" ms", i18n.Number(out.B.Jitter, 2)+" ms", out.Jitter)
+		if !opts.NoDownload {
+			printDifference(i18n.T("Download:\tA %s, B %s, %s (p = %s)\n"), progress.formatRate(out.A.Download.Avg), progress.formatRate(out.B.Download.Avg), out.Download)
+		}
+		if !opts.NoUpload {
+			printDifference(i18n.T("Upload:\t\tA %s, B %s, %s (p = %s)\n"), progress.formatRate(out.A.Upload.Avg), progress.formatRate(out.B.Upload.Avg), out.Upload)
+		}
+	}
+
+	if len(results[0]) == 0 || len(results[1]) == 0 {
+		return errors.New("no successful round to compare")
+	}
+	return nil
+}
+
+// printDifference prints the means of a measurement of both servers in `format` and which one is better, if
+// significantly
+func printDifference(format, a, b string, d report.Difference) {
+	verdict := i18n.T("no significant difference")
+	switch {
+	case d.Significant && d.Delta > 0:
+		verdict = fmt.Sprintf(i18n.T("B is better by %s%%"), i18n.Number(d.Delta, 2))
+	case d.Significant && d.Delta < 0:
+		verdict = fmt.Sprintf(i18n.T("B is worse by %s%%"), i18n.Number(-d.Delta, 2))
+	}
+	fmt.Printf(format, a, b, verdict, i18n.Number(d.P, 4))
+}
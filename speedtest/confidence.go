@@ -0,0 +1,163 @@
+package speedtest
+
+import (
+	"math"
+	"strings"
+	"time"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+const (
+	// the window the throughput is averaged over for rating its stability, the first window is skipped as the
+	// connections are still ramping up
+	stabilityWindow = time.Second
+	// transfers shorter than steadyDuration are penalized in proportion, as they might not reach a steady rate
+	steadyDuration = 10 * time.Second
+)
+
+// the maximum penalties of the reasons lowering the confidence, the coefficient of variation of the throughput is
+// multiplied by unstablePenalty and each failed request costs retryPenalty
+const (
+	unstablePenalty     = 50
+	maxUnstablePenalty  = 30
+	shortPenalty        = 20
+	retryPenalty        = 5
+	maxRetryPenalty     = 25
+	cpuPenalty          = 20
+	crossTrafficPenalty = 20
+	linkPenalty         = 10
+	tunnelPenalty       = 10
+)
+
+// penalties below noticeablePenalty don't give a reason, e.g. for the usual fluctuation of the throughput
+const noticeablePenalty = 5
+
+// transferSamples records the throughput of a transfer phase every stabilityWindow
+type transferSamples struct {
+	counter *defs.BytesCounter
+	elapsed time.Duration
+	bytes   uint64
+	rates   []float64
+}
+
+// hook returns the progress callback of a transfer phase recording the samples, chained with `next` if not nil
+func (t *transferSamples) hook(next func(*defs.BytesCounter)) func(*defs.BytesCounter) {
+	return func(counter *defs.BytesCounter) {
+		t.counter = counter
+		elapsed, bytes := counter.Elapsed(), counter.Total()
+		if dt := elapsed - t.elapsed; dt >= stabilityWindow {
+			if t.elapsed > 0 {
+				t.rates = append(t.rates, float64(bytes-t.bytes)*8/1000000/dt.Seconds())
+			}
+			t.elapsed, t.bytes = elapsed, bytes
+		}
+		if next != nil {
+			next(counter)
+		}
+	}
+}
+
+// variation returns the coefficient of variation of the throughput, -1 if there are too few samples
+func (t *transferSamples) variation() float64 {
+	if len(t.rates) < 3 {
+		return -1
+	}
+	var sum float64
+	for _, r := range t.rates {
+		sum += r
+	}
+	mean := sum / float64(len(t.rates))
+	if mean == 0 {
+		return -1
+	}
+	var sq float64
+	for _, r := range t.rates {
+		sq += (r - mean) * (r - mean)
+	}
+	return math.Sqrt(sq/float64(len(t.rates))) / mean
+}
+
+// streams returns the per-connection throughput of the transfer, nil if it's unknown
+func (t *transferSamples) streams() *defs.StreamRates {
+	if t == nil || t.counter == nil {
+		return nil
+	}
+	return t.counter.StreamRates()
+}
+
+// failures returns the number of failed transfer requests
+func (t *transferSamples) failures() int {
+	if t.counter == nil || t.counter.Stats() == nil {
+		return 0
+	}
+	return t.counter.Stats().Failures
+}
+
+// confidence rates a result by the stability of the transfers, their duration, failed requests and the interference
+// detected during the test
+func confidence(rep report.Result, opts *Options, transfers ...*transferSamples) *report.Confidence {
+	res := &report.Confidence{}
+	score := 100.0
+	add := func(reason string, penalty float64) {
+		if penalty >= noticeablePenalty {
+			score -= penalty
+			res.Reasons = append(res.Reasons, reason)
+		}
+	}
+
+	var variation float64
+	failures := 0
+	for _, t := range transfers {
+		variation = max(variation, t.variation())
+		failures += t.failures()
+	}
+	add(report.ReasonUnstable, min(variation*unstablePenalty, maxUnstablePenalty))
+	if len(transfers) > 0 && opts.Duration < steadyDuration {
+		add(report.ReasonShort, float64(steadyDuration-opts.Duration)/float64(steadyDuration)*shortPenalty)
+	}
+	add(report.ReasonRetries, float64(min(failures*retryPenalty, maxRetryPenalty)))
+	if rep.CPU != nil && ((rep.CPU.Download != nil && rep.CPU.Download.Bound()) || (rep.CPU.Upload != nil && rep.CPU.Upload.Bound())) {
+		add(report.ReasonCPU, cpuPenalty)
+	}
+	if t := rep.CrossTraffic; t != nil && max(t.Download, t.Upload) >= opts.TrafficThreshold {
+		add(report.ReasonCrossTraffic, crossTrafficPenalty)
+	}
+	if l := rep.Link; l != nil {
+		if l.Speed > 0 && max(rep.Download, rep.Upload) >= float64(l.Speed)*linkBoundRatio {
+			add(report.ReasonLink, linkPenalty)
+		}
+		if l.Tunnel {
+			add(report.ReasonTunnel, tunnelPenalty)
+		}
+	}
+
+	res.Score = int(math.Round(max(score, 0)))
+	return res
+}
+
+// confidenceReasons are the descriptions of the reasons lowering the confidence
+var confidenceReasons = map[string]string{
+	report.ReasonUnstable:     "unstable throughput",
+	report.ReasonShort:        "short test",
+	report.ReasonRetries:      "failed requests",
+	report.ReasonCPU:          "CPU-bound",
+	report.ReasonCrossTraffic: "other traffic",
+	report.ReasonLink:         "limited by the link",
+	report.ReasonTunnel:       "tunnel",
+}
+
+// formatConfidence returns the score with the descriptions of the reasons lowering it
+func formatConfidence(c *report.Confidence) string {
+	s := i18n.Number(float64(c.Score), 0) + "/100"
+	if len(c.Reasons) == 0 {
+		return s
+	}
+	reasons := make([]string, len(c.Reasons))
+	for i, r := range c.Reasons {
+		reasons[i] = i18n.T(confidenceReasons[r])
+	}
+	return s + " (" + strings.Join(reasons, ", ") + ")"
+}
@@ -0,0 +1,168 @@
+package speedtest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/gocarina/gocsv"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+)
+
+// Filters selects the servers returned by Discover, empty fields match all servers
+type Filters struct {
+	// IDs of servers
+	IDs []string
+	// Provinces by code in GB/T 2260-2007 (bj, sh, gd... etc), or `lo` for the province of Client
+	Provinces []string
+	// ISPs by short name (ct, cu, cm, cernet, catv, drpeng) or ASN, or `lo` for the ISP of Client
+	ISPs []string
+	// Groups by PROVINCE@ISP as with --group, in addition to the combinations of Provinces and ISPs
+	Groups []string
+	// Types of servers
+	Types []defs.ServerType
+
+	// Client is the IP info used to resolve `lo`
+	Client *defs.IPInfoResponse
+	// Options for the API and the HTTP transport, and Network to only return reachable servers.
+	// DefaultOptions is used when nil
+	Options *Options
+}
+
+// Discover returns the servers known to the core API which match `f`, without running any test
+func Discover(ctx context.Context, f Filters) ([]defs.Server, error) {
+	opts := f.Options
+	if opts == nil {
+		o := DefaultOptions()
+		opts = &o
+	}
+
+	provinces := loadProvinces()
+	provinceMap := make(map[uint8]defs.ProvinceInfo)
+	for _, p := range provinces {
+		provinceMap[p.ID] = p
+	}
+
+	// every combination of province and ISP is a group
+	var groups []string
+	if len(f.Provinces) > 0 || len(f.ISPs) > 0 {
+		provs, isps := f.Provinces, f.ISPs
+		if len(provs) == 0 {
+			provs = []string{""}
+		}
+		if len(isps) == 0 {
+			isps = []string{""}
+		}
+		for _, p := range provs {
+			for _, i := range isps {
+				group, ok := parseGroup(fmt.Sprintf("%s@%s", p, i), f.Client, provinces)
+				if !ok {
+					return nil, fmt.Errorf("unknown province or ISP: %s@%s", p, i)
+				}
+				groups = append(groups, group)
+			}
+		}
+	}
+	for _, g := range f.Groups {
+		group, ok := parseGroup(g, f.Client, provinces)
+		if !ok {
+			return nil, fmt.Errorf("unknown province or ISP: %s", g)
+		}
+		groups = append(groups, group)
+	}
+
+	res, err := getServerList(ctx, opts.client(), opts.APIBase, opts.APIVersion, &f.IDs, &groups)
+	if err != nil {
+		return nil, err
+	}
+
+	seen := make(map[string]bool)
+	var servers []defs.Server
+	for _, g := range res {
+		for _, s := range filterNetwork(g.Node, opts.Network) {
+			if seen[s.ID] || (len(f.Types) > 0 && !containsType(f.Types, s.Type)) {
+				continue
+			}
+			seen[s.ID] = true
+			if s.Province == "" {
+				s.Province = provinceMap[s.Prov].Short
+			}
+			servers = append(servers, s)
+		}
+	}
+	return servers, nil
+}
+
+// loadProvinces returns the list of provinces, from the metadata bundle if loaded
+func loadProvinces() []defs.ProvinceInfo {
+	if len(provinceList) > 0 {
+		return provinceList
+	}
+	var provinces []defs.ProvinceInfo
+	gocsv.UnmarshalBytes(ProvinceListByte, &provinces)
+	return provinces
+}
+
+// parseGroup parses a group of servers in the form of PROVINCE@ISP into the group ID of the core API, `lo` refers to
+// the province or ISP of `ispInfo`
+func parseGroup(s string, ispInfo *defs.IPInfoResponse, provinces []defs.ProvinceInfo) (string, bool) {
+	sg := strings.Split(s, "@")
+	sgp, sgi := "", ""
+	switch len(sg) {
+	case 1:
+		sgp = sg[0]
+	case 2:
+		sgp, sgi = sg[0], sg[1]
+	default:
+		return "", false
+	}
+
+	if sgp == "lo" || sgi == "lo" {
+		if ispInfo == nil || (sgp == "lo" && ispInfo.Province == "") || (sgi == "lo" && ispInfo.ISP == "") {
+			return "", false
+		}
+	}
+
+	var province uint8 = 0
+	if sgp != "" {
+		if sgp == "lo" {
+			province = MatchProvince(ispInfo.Province, &provinces)
+		} else {
+			for _, p := range provinces {
+				if p.Code == sgp {
+					province = p.ID
+					break
+				}
+			}
+		}
+		if province == 0 {
+			return "", false
+		}
+	}
+
+	var isp uint8 = 0
+	if sgi != "" {
+		for _, i := range defs.ISPMap {
+			if (sgi != "lo" && (sgi == strconv.Itoa(int(i.ASN)) || sgi == i.Short)) || (sgi == "lo" && (i.Name == ispInfo.ISP || strings.Contains(ispInfo.ISP, i.Name) || strings.Contains(i.Name, ispInfo.ISP))) {
+				isp = i.ID
+			}
+		}
+		if isp == 0 {
+			return "", false
+		}
+	}
+
+	return fmt.Sprintf("%d@%d", province, isp), true
+}
+
+// containsType checks if a server type is in a type array
+func containsType(arr []defs.ServerType, val defs.ServerType) bool {
+	for _, v := range arr {
+		if v == val {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,108 @@
+package speedtest
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/url"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+)
+
+// dnsCache is the addresses of the hostnames of a server, resolved before testing
+type dnsCache map[string][]net.IP
+
+// prewarmDNS resolves the hostnames of the server tested against for all address families before the ping phase, and
+// pins the traffic of the test to the resolved addresses, so that DNS latency doesn't show in the first samples.
+// Returns the time of the resolution in milliseconds, zero if the server is given by IP addresses or reached through
+// a proxy
+func prewarmDNS(ctx context.Context, server *defs.Server, opts *Options) float64 {
+	client := *server.HTTPClient()
+	if client.Transport == nil {
+		client.Transport = http.DefaultTransport
+	}
+	transport, ok := client.Transport.(*http.Transport)
+
+	var hosts []string
+	if server.Type == defs.IPerf3 {
+		if net.ParseIP(server.Host) == nil {
+			hosts = append(hosts, server.Host)
+		}
+	} else {
+		for _, s := range []string{server.PingURL(), server.DownloadURL(), server.UploadURL()} {
+			u, err := url.Parse(s)
+			if err != nil || net.ParseIP(u.Hostname()) != nil || contains(hosts, u.Hostname()) {
+				continue
+			}
+			// the proxy resolves the hostnames of the requests it forwards
+			if ok && transport.Proxy != nil {
+				if proxy, _ := transport.Proxy(&http.Request{URL: u}); proxy != nil {
+					continue
+				}
+			}
+			hosts = append(hosts, u.Hostname())
+		}
+	}
+	if len(hosts) == 0 {
+		return 0
+	}
+
+	cache := make(dnsCache)
+	start := time.Now()
+	for _, host := range hosts {
+		addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+		if err != nil {
+			log.Debugf("Failed to resolve %s: %s", host, err)
+			continue
+		}
+		for _, addr := range addrs {
+			if (addr.IP.To4() != nil && opts.Network != "ip6") || (addr.IP.To4() == nil && opts.Network != "ip4") {
+				cache[host] = append(cache[host], addr.IP)
+			}
+		}
+	}
+	elapsed := time.Since(start)
+	log.Debugf("Resolved %v in %s: %v", hosts, elapsed, cache)
+	if len(cache) == 0 {
+		return 0
+	}
+
+	if server.Type == defs.IPerf3 {
+		// iperf3 has neither Host headers nor TLS, the host is simply replaced
+		if ips := cache[server.Host]; len(ips) > 0 {
+			server.Host = ips[0].String()
+		}
+	} else if ok {
+		pinned := transport.Clone()
+		dial := pinned.DialContext
+		if dial == nil {
+			dial = (&net.Dialer{}).DialContext
+		}
+		pinned.DialContext = cache.dial(dial)
+		client.Transport = pinned
+		server.Client = &client
+	}
+	return float64(elapsed.Microseconds()) / 1000
+}
+
+// dial returns a dial function connecting to the cached addresses of a hostname, trying them in turn. Other addresses
+// are dialed as is
+func (d dnsCache) dial(dial func(context.Context, string, string) (net.Conn, error)) func(context.Context, string, string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		ips := d[host]
+		if err != nil || len(ips) == 0 {
+			return dial(ctx, network, address)
+		}
+		var conn net.Conn
+		for _, ip := range ips {
+			if conn, err = dial(ctx, network, net.JoinHostPort(ip.String(), port)); err == nil {
+				return conn, nil
+			}
+		}
+		return nil, err
+	}
+}
@@ -0,0 +1,485 @@
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+var (
+	// ErrNoServer is returned when no server is available for testing
+	ErrNoServer = errors.New("no server available")
+	// ErrServerDown is returned when the server to test against is not responding
+	ErrServerDown = errors.New("server is not responding")
+	// ErrToken is returned when a GlobalSpeed server refuses to hand out a test token
+	ErrToken = errors.New("get token failed")
+)
+
+// Options configures a speed test run
+type Options struct {
+	// Server to test against, the fastest server nearby is selected when nil
+	Server *defs.Server
+
+	// Transport for all HTTP traffic of the test, http.DefaultTransport is used when nil
+	Transport http.RoundTripper
+	// Dialer for the iperf3 and UDP connections of the test, bound like the dialer of Transport. A zero net.Dialer is
+	// used when nil
+	Dialer *net.Dialer
+	// MPTCP tracks the connections of Transport dialed with Multipath TCP, reported in the results if not nil
+	MPTCP *defs.MPTCP
+	// Timeout of HTTP requests, no timeout when zero
+	Timeout time.Duration
+
+	// APIBase and APIVersion of the core API used for server discovery
+	APIBase    string
+	APIVersion string
+
+	// Network is one of "ip", "ip4" or "ip6"
+	Network string
+	// Source is the source IP address used for ICMP ping
+	Source string
+	// Interface is the network interface the test traffic is bound to, the link of the results is looked up by the
+	// route to the server when empty
+	Interface string
+	// NoICMP uses HTTP ping instead of ICMP ping
+	NoICMP bool
+	// PingCount is the number of pings for measuring ping and jitter
+	PingCount int
+	// LossProbes is the number of UDP probes for measuring packet loss, only supported by peers running with --listen.
+	// Packet loss is not measured when zero
+	LossProbes int
+
+	// DownloadPath, UploadPath and PingPath override the paths of the server tested against if not empty
+	DownloadPath string
+	UploadPath   string
+	PingPath     string
+	// PingURL is an absolute URL which replaces the ping URL of the server tested against and forces HTTP ping if not empty
+	PingURL string
+
+	// NoDownload and NoUpload skip the download and upload tests
+	NoDownload bool
+	NoUpload   bool
+	// Concurrent is the number of concurrent HTTP requests in the download and upload tests
+	Concurrent int
+	// Duration of each of the download and upload tests
+	Duration time.Duration
+	// UploadSize is the size of upload payload in KiB
+	UploadSize int
+	// NoPreAllocate generates upload data on the fly instead of pre allocating it
+	NoPreAllocate bool
+	// BufferSize is the size of the buffer of each download stream in bytes, defs.DefaultCopyBufferSize is used when
+	// zero
+	BufferSize int
+	// StallTimeout aborts and retries a download or upload request which transferred nothing for this long, so that a
+	// stalled stream doesn't lower the concurrency for the rest of the phase. Requests never stall out when zero
+	StallTimeout time.Duration
+
+	// TrafficThreshold is the traffic of the network interfaces in Mbps in either direction, above which other
+	// traffic is considered to distort the result of a test. Traffic isn't checked before testing when zero
+	TrafficThreshold float64
+	// TrafficWait is how long to wait for other traffic to drop below TrafficThreshold before testing anyway
+	TrafficWait time.Duration
+
+	// UpCheckTimeout is the timeout of checking a server is up before pinging or testing it, and SkipUpCheck assumes
+	// every server is up without checking
+	UpCheckTimeout time.Duration
+	SkipUpCheck    bool
+
+	// SelectionConcurrency is the number of servers pinged concurrently when selecting the fastest server, and
+	// SelectionTimeout the overall deadline of the selection
+	SelectionConcurrency int
+	SelectionTimeout     time.Duration
+
+	// OnPhaseStart is called when a test phase starts
+	OnPhaseStart func(Phase)
+	// OnPing is called with the RTT of every ping in the ping phase
+	OnPing func(PingSample)
+	// OnSample is called with the throughput every defs.SampleInterval in the download and upload phases
+	OnSample func(ThroughputSample)
+	// OnPhaseDone is called with the result of a test phase
+	OnPhaseDone func(PhaseComplete)
+
+	// Events receives all events of the test run if not nil
+	Events *Bus
+}
+
+// DefaultOptions returns the options used by the CLI by default
+func DefaultOptions() Options {
+	return Options{
+		APIBase:              "https://speed.qwq.vc/api",
+		APIVersion:           "v1",
+		Network:              "ip",
+		PingCount:            pingCount,
+		Concurrent:           3,
+		Duration:             15 * time.Second,
+		UploadSize:           1024,
+		StallTimeout:         5 * time.Second,
+		UpCheckTimeout:       upCheckTimeout,
+		SelectionConcurrency: 10,
+		SelectionTimeout:     10 * time.Second,
+	}
+}
+
+// withDefaults fills the zero fields of the options which have a default in DefaultOptions
+func (o *Options) withDefaults() {
+	d := DefaultOptions()
+	if o.APIBase == "" {
+		o.APIBase = d.APIBase
+	}
+	if o.APIVersion == "" {
+		o.APIVersion = d.APIVersion
+	}
+	if o.Network == "" {
+		o.Network = d.Network
+	}
+	if o.PingCount <= 0 {
+		o.PingCount = d.PingCount
+	}
+	if o.Concurrent <= 0 {
+		o.Concurrent = d.Concurrent
+	}
+	if o.Duration <= 0 {
+		o.Duration = d.Duration
+	}
+	if o.UploadSize <= 0 {
+		o.UploadSize = d.UploadSize
+	}
+	if o.UpCheckTimeout <= 0 {
+		o.UpCheckTimeout = d.UpCheckTimeout
+	}
+	if o.SelectionConcurrency <= 0 {
+		o.SelectionConcurrency = d.SelectionConcurrency
+	}
+	if o.SelectionTimeout <= 0 {
+		o.SelectionTimeout = d.SelectionTimeout
+	}
+}
+
+// RunTest runs a speed test with `opts`, against `opts.Server` or the fastest server nearby. The whole run including
+// server discovery is canceled when `ctx` is done. Zero fields of `opts` are taken from DefaultOptions
+func RunTest(ctx context.Context, opts Options) (report.Result, error) {
+	opts.withDefaults()
+	var server defs.Server
+
+	if opts.Server != nil {
+		server = *opts.Server
+		if up, _ := checkServers(ctx, []defs.Server{server}, &opts, 1); !up[0] {
+			if err := ctx.Err(); err != nil {
+				return report.Result{}, err
+			}
+			return report.Result{}, ErrServerDown
+		}
+	} else {
+		ispInfo, _ := defs.GetIPInfo(ctx, opts.client())
+		servers, err := defaultServers(ctx, &opts, ispInfo)
+		if err != nil {
+			return report.Result{}, err
+		}
+
+		var ok bool
+		if server, ok = selectServer(ctx, "", servers, &opts); !ok {
+			if err := ctx.Err(); err != nil {
+				return report.Result{}, err
+			}
+			return report.Result{}, ErrNoServer
+		}
+	}
+	return runServer(ctx, server, &opts)
+}
+
+// defaultServers returns the candidate servers used when no server is specified, from the GlobalSpeed list for
+// clients in China, or else from the core API
+func defaultServers(ctx context.Context, opts *Options, ispInfo *defs.IPInfoResponse) ([]defs.Server, error) {
+	if opts.Network != "ip6" && ispInfo != nil && ispInfo.IP != "" && ispInfo.Country == "中国" {
+		return getGlobalServerList(ctx, opts.client(), ispInfo.IP, 0)
+	}
+
+	groups, err := getServerList(ctx, opts.client(), opts.APIBase, opts.APIVersion, nil, &[]string{"31@1"})
+	if err != nil {
+		return nil, err
+	}
+	var servers []defs.Server
+	for _, g := range groups {
+		servers = append(servers, filterNetwork(g.Node, opts.Network)...)
+	}
+	return servers, nil
+}
+
+// filterNetwork drops the servers not reachable with `network` and sets their host
+func filterNetwork(nodes []defs.Server, network string) []defs.Server {
+	var servers []defs.Server
+	for _, n := range nodes {
+		if n.IP != "" && network != "ip6" {
+			if n.Host == "" {
+				n.Host = n.IP
+			}
+		} else if n.IPv6 != "" && network != "ip4" {
+			if n.Host == "" {
+				n.Host = n.IPv6
+			}
+		} else {
+			continue
+		}
+		servers = append(servers, n)
+	}
+	return servers
+}
+
+// client returns the HTTP client for all traffic of the test
+func (o *Options) client() *http.Client {
+	return &http.Client{Transport: o.Transport, Timeout: o.Timeout}
+}
+
+// prepare applies the options to a server before pinging it, including the candidates for selection
+func (o *Options) prepare(server *defs.Server) {
+	// skip ICMP if option given
+	server.NoICMP = o.NoICMP
+	server.BufferSize = o.BufferSize
+	server.StallTimeout = o.StallTimeout
+	server.Dialer = o.Dialer
+	if server.Client == nil {
+		server.Client = o.client()
+	}
+}
+
+// target applies the options to the server selected for testing, along with the overrides which only apply to it and
+// would make all candidates look alike during selection
+func (o *Options) target(server *defs.Server) {
+	o.prepare(server)
+	if o.DownloadPath != "" {
+		server.DownloadURI = o.DownloadPath
+	}
+	if o.UploadPath != "" {
+		server.UploadURI = o.UploadPath
+	}
+	if o.PingPath != "" {
+		server.PingURI = o.PingPath
+	}
+	if o.PingURL != "" {
+		server.PingURI = o.PingURL
+		server.NoICMP = true
+	}
+}
+
+// publish sends an event to the event bus if set
+func (o *Options) publish(e Event) {
+	if o.Events != nil {
+		o.Events.Publish(e)
+	}
+}
+
+// phaseStart calls the OnPhaseStart callback if set
+func (o *Options) phaseStart(phase Phase) {
+	if o.OnPhaseStart != nil {
+		o.OnPhaseStart(phase)
+	}
+	o.publish(PhaseStarted{Phase: phase})
+}
+
+// phaseDone calls the OnPhaseDone callback if set
+func (o *Options) phaseDone(res PhaseComplete) {
+	if o.OnPhaseDone != nil {
+		o.OnPhaseDone(res)
+	}
+	o.publish(res)
+}
+
+// pingHook returns the callback for every ping of the ping phase
+func (o *Options) pingHook() func(float64) {
+	if o.OnPing == nil && o.Events == nil {
+		return nil
+	}
+	seq := 0
+	return func(rtt float64) {
+		seq++
+		sample := PingSample{Seq: seq, RTT: rtt}
+		if o.OnPing != nil {
+			o.OnPing(sample)
+		}
+		o.publish(sample)
+	}
+}
+
+// sampleHook returns the progress callback of a transfer phase
+func (o *Options) sampleHook(phase Phase) func(*defs.BytesCounter) {
+	if o.OnSample == nil && o.Events == nil {
+		return nil
+	}
+	return func(counter *defs.BytesCounter) {
+		elapsed := counter.Elapsed()
+		sample := ThroughputSample{Phase: phase, Rate: counter.AvgMbps(), Bytes: counter.Total(), Elapsed: elapsed, Remaining: max(o.Duration-elapsed, 0)}
+		if o.OnSample != nil {
+			o.OnSample(sample)
+		}
+		o.publish(sample)
+	}
+}
+
+// runServer runs the ping, download and upload tests against a server which is known to be up, and publishes the
+// ServerSelected and RunComplete events
+func runServer(ctx context.Context, server defs.Server, opts *Options) (report.Result, error) {
+	opts.publish(ServerSelected{Server: server})
+	rep, err := testServer(ctx, server, opts)
+	opts.publish(RunComplete{Server: server, Result: rep, Err: err})
+	return rep, err
+}
+
+// testServer runs the ping, download and upload tests against a server
+func testServer(ctx context.Context, server defs.Server, opts *Options) (report.Result, error) {
+	if opts.MPTCP != nil {
+		opts.MPTCP.Reset()
+	}
+
+	// check for other traffic before any traffic of the test
+	var traffic *defs.TrafficRate
+	if opts.TrafficThreshold > 0 {
+		traffic = crossTraffic(ctx, opts)
+		if err := ctx.Err(); err != nil {
+			return report.Result{}, err
+		}
+	}
+
+	opts.target(&server)
+	// resolve the server before any sample is taken
+	dns := prewarmDNS(ctx, &server, opts)
+	if err := ctx.Err(); err != nil {
+		return report.Result{}, err
+	}
+
+	// get ping and jitter value
+	opts.phaseStart(PhasePing)
+	start := time.Now()
+
+	p, jitter, err := server.ICMPPingAndJitter(ctx, opts.PingCount, opts.Source, opts.Network, opts.pingHook())
+	if err != nil {
+		log.Errorf(i18n.T("Failed to get ping and jitter: %s"), err)
+		return report.Result{}, err
+	}
+	opts.phaseDone(PhaseComplete{Phase: PhasePing, Ping: p, Jitter: jitter, Duration: time.Since(start)})
+
+	// get packet loss value
+	var loss *float64
+	if opts.LossProbes > 0 {
+		opts.phaseStart(PhaseLoss)
+		start := time.Now()
+		l, err := measureLoss(ctx, server, opts.Network, opts.LossProbes)
+		if err != nil {
+			if err := ctx.Err(); err != nil {
+				return report.Result{}, err
+			}
+			log.Warnf(i18n.T("Failed to get packet loss: %s"), err)
+			l = -1
+		} else {
+			loss = &l
+		}
+		opts.phaseDone(PhaseComplete{Phase: PhaseLoss, Loss: l, Duration: time.Since(start)})
+	}
+
+	token := ""
+	if server.Type == defs.GlobalSpeed && !(opts.NoDownload && opts.NoUpload) {
+		token = enQueue(ctx, server)
+		if len(token) <= 0 || token == "-" {
+			if err := ctx.Err(); err != nil {
+				return report.Result{}, err
+			}
+			return report.Result{}, ErrToken
+		}
+		// release the token even if the test is canceled
+		defer deQueue(context.WithoutCancel(ctx), server, token)
+	}
+
+	// get download value
+	var downloadValue float64
+	var bytesRead uint64
+	var cpuDownload, cpuUpload *defs.CPUUsage
+	var transfers []*transferSamples
+	var downloadSamples, uploadSamples *transferSamples
+	if opts.NoDownload {
+		log.Info(i18n.T("Download test is disabled"))
+	} else {
+		opts.phaseStart(PhaseDownload)
+		start := time.Now()
+		cpuStart := sampleCPU()
+		samples := &transferSamples{}
+		transfers = append(transfers, samples)
+		downloadSamples = samples
+		download, br, err := server.Download(ctx, opts.Concurrent, opts.Duration, token, samples.hook(opts.sampleHook(PhaseDownload)))
+		if err != nil {
+			log.Errorf(i18n.T("Failed to get download speed: %s"), err)
+			return report.Result{}, err
+		}
+		opts.phaseDone(PhaseComplete{Phase: PhaseDownload, Rate: download, Bytes: br, Duration: time.Since(start)})
+		downloadValue = download
+		bytesRead = br
+		cpuDownload = cpuUsageSince("download", cpuStart)
+	}
+
+	// get upload value
+	var uploadValue float64
+	var bytesWritten uint64
+	if opts.NoUpload {
+		log.Info(i18n.T("Upload test is disabled"))
+	} else {
+		opts.phaseStart(PhaseUpload)
+		start := time.Now()
+		cpuStart := sampleCPU()
+		samples := &transferSamples{}
+		transfers = append(transfers, samples)
+		uploadSamples = samples
+		upload, bw, err := server.Upload(ctx, opts.NoPreAllocate, opts.Concurrent, opts.UploadSize, opts.Duration, token, samples.hook(opts.sampleHook(PhaseUpload)))
+		if err != nil {
+			log.Errorf(i18n.T("Failed to get upload speed: %s"), err)
+			return report.Result{}, err
+		}
+		opts.phaseDone(PhaseComplete{Phase: PhaseUpload, Rate: upload, Bytes: bw, Duration: time.Since(start)})
+		uploadValue = upload
+		bytesWritten = bw
+		cpuUpload = cpuUsageSince("upload", cpuStart)
+	}
+	var rep report.Result
+	rep.Timestamp = time.Now()
+
+	rep.DNS = dns
+	rep.Ping = p
+	rep.Jitter = jitter
+	rep.Loss = loss
+	rep.CrossTraffic = traffic
+	rep.Download = downloadValue
+	rep.Upload = uploadValue
+	rep.Round()
+	rep.BytesReceived = bytesRead
+	rep.BytesSent = bytesWritten
+	if cpuDownload != nil || cpuUpload != nil {
+		rep.CPU = &report.CPU{Download: cpuDownload, Upload: cpuUpload}
+	}
+	if down, up := downloadSamples.streams(), uploadSamples.streams(); down != nil || up != nil {
+		rep.Streams = &report.Streams{Download: down, Upload: up}
+	}
+
+	rep.ID = server.ID
+	switch opts.Network {
+	case "ip6":
+		rep.IP = server.IPv6
+	default:
+		rep.IP = server.IP
+	}
+	rep.Name = server.Name
+	rep.Province = server.Province
+	rep.City = server.City
+	rep.ISP = defs.ISPMap[server.ISP].Name
+	rep.Link = linkOf(server, opts.Interface, rep)
+	rep.Confidence = confidence(rep, opts, transfers...)
+	if opts.MPTCP != nil {
+		rep.MPTCP = opts.MPTCP.Usage()
+	}
+
+	return rep, nil
+}
@@ -0,0 +1,137 @@
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/mockserver"
+)
+
+// startMock starts a mock server of `config` which is closed at the end of the test
+func startMock(t *testing.T, config mockserver.Config) *mockserver.Server {
+	t.Helper()
+	if config.RateLimit == 0 {
+		// keep the transfers light, the tests are about the flow rather than the throughput
+		config.RateLimit = 10 << 20
+	}
+	m, err := mockserver.Start(config)
+	if err != nil {
+		t.Fatalf("failed to start mock server: %s", err)
+	}
+	t.Cleanup(func() { m.Close() })
+	return m
+}
+
+// testOptions returns the options for a short test against `server`
+func testOptions(server defs.Server) Options {
+	opts := DefaultOptions()
+	opts.Server = &server
+	opts.NoICMP = true
+	opts.Network = "ip4"
+	opts.PingCount = 3
+	opts.Concurrent = 2
+	opts.Duration = time.Second
+	opts.UploadSize = 64
+	return opts
+}
+
+func TestRunTest(t *testing.T) {
+	for _, typ := range []defs.ServerType{defs.GlobalSpeed, defs.Perception, defs.WirelessSpeed} {
+		t.Run(typ.String(), func(t *testing.T) {
+			m := startMock(t, mockserver.Config{Type: typ})
+
+			rep, err := RunTest(context.Background(), testOptions(m.Server()))
+			if err != nil {
+				t.Fatalf("RunTest failed: %s", err)
+			}
+			if rep.Download <= 0 || rep.Upload <= 0 {
+				t.Errorf("expected positive rates, got %.2f down and %.2f up", rep.Download, rep.Upload)
+			}
+			if rep.BytesReceived == 0 || rep.BytesSent == 0 {
+				t.Errorf("expected transferred bytes, got %d received and %d sent", rep.BytesReceived, rep.BytesSent)
+			}
+			if rep.Streams == nil || rep.Streams.Download == nil || rep.Streams.Upload == nil {
+				t.Errorf("expected per-stream rates of both directions, got %+v", rep.Streams)
+			} else if r := rep.Streams.Download; r.Min > r.Median || r.Median > r.Max {
+				t.Errorf("expected ordered per-stream download rates, got %+v", r)
+			}
+
+			stats := m.Stats()
+			if stats.Pings == 0 || stats.Downloads == 0 || stats.Uploads == 0 {
+				t.Errorf("expected pings, downloads and uploads, got %+v", stats)
+			}
+			// the token of GlobalSpeed servers is released at the end of the test
+			if stats.Tokens != 0 {
+				t.Errorf("expected all tokens released, %d are left", stats.Tokens)
+			}
+		})
+	}
+}
+
+func TestRunTestServerDown(t *testing.T) {
+	m := startMock(t, mockserver.Config{Type: defs.GlobalSpeed})
+	server := m.Server()
+	m.Close()
+
+	if _, err := RunTest(context.Background(), testOptions(server)); !errors.Is(err, ErrServerDown) {
+		t.Errorf("expected ErrServerDown, got %v", err)
+	}
+}
+
+func TestRunTestNoToken(t *testing.T) {
+	m := startMock(t, mockserver.Config{Type: defs.GlobalSpeed, NoToken: true})
+
+	if _, err := RunTest(context.Background(), testOptions(m.Server())); !errors.Is(err, ErrToken) {
+		t.Errorf("expected ErrToken, got %v", err)
+	}
+	if stats := m.Stats(); stats.Downloads != 0 || stats.Uploads != 0 {
+		t.Errorf("expected no transfers without token, got %+v", stats)
+	}
+}
+
+func TestRunTestStalledStream(t *testing.T) {
+	m := startMock(t, mockserver.Config{Type: defs.GlobalSpeed, StallEvery: 2})
+	opts := testOptions(m.Server())
+	opts.NoUpload = true
+	opts.StallTimeout = 300 * time.Millisecond
+
+	rep, err := RunTest(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("RunTest failed: %s", err)
+	}
+	if rep.Download <= 0 {
+		t.Errorf("expected a positive download rate, got %.2f", rep.Download)
+	}
+	// the stalled request is aborted and requested again, while the others last the whole phase
+	if stats := m.Stats(); stats.Downloads <= int64(opts.Concurrent) {
+		t.Errorf("expected the stalled download restarted, got %d downloads by %d workers", stats.Downloads, opts.Concurrent)
+	}
+}
+
+func TestRunTestCanceled(t *testing.T) {
+	m := startMock(t, mockserver.Config{Type: defs.GlobalSpeed})
+	opts := testOptions(m.Server())
+	opts.Duration = 30 * time.Second
+
+	ctx, cancel := context.WithCancel(context.Background())
+	opts.OnPhaseStart = func(phase Phase) {
+		if phase == PhaseDownload {
+			time.AfterFunc(500*time.Millisecond, cancel)
+		}
+	}
+
+	start := time.Now()
+	_, err := RunTest(ctx, opts)
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("expected the test to stop soon after canceling, took %s", elapsed)
+	}
+	if stats := m.Stats(); stats.Tokens != 0 {
+		t.Errorf("expected the token released after canceling, %d are left", stats.Tokens)
+	}
+}
@@ -0,0 +1,156 @@
+package speedtest
+
+import (
+	"sync"
+	"time"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+// Phase is a phase of a speed test
+type Phase string
+
+const (
+	PhasePing     Phase = "ping"
+	PhaseDownload Phase = "download"
+	PhaseUpload   Phase = "upload"
+	PhaseLoss     Phase = "loss"
+)
+
+// Event is published on the Bus during a test run, it's one of ServerSelected, PhaseStarted, PingSample,
+// ThroughputSample, PhaseComplete or RunComplete
+type Event interface {
+	event()
+}
+
+// ServerSelected is published when the server to test against is selected
+type ServerSelected struct {
+	Server defs.Server
+}
+
+// PhaseStarted is published when a test phase starts
+type PhaseStarted struct {
+	Phase Phase
+}
+
+// PingSample is a single ping in the ping phase
+type PingSample struct {
+	Seq int
+	RTT float64
+}
+
+// ThroughputSample is the throughput of a transfer phase so far, Rate is in Mbps. Remaining is the time left until
+// the duration of the phase is reached
+type ThroughputSample struct {
+	Phase     Phase
+	Rate      float64
+	Bytes     uint64
+	Elapsed   time.Duration
+	Remaining time.Duration
+}
+
+// PhaseComplete is the result of a test phase, Ping and Jitter are set for the ping phase, Rate and Bytes for the
+// transfer phases and Loss in percent for the loss phase, which is negative if packet loss couldn't be measured
+type PhaseComplete struct {
+	Phase    Phase
+	Ping     float64
+	Jitter   float64
+	Rate     float64
+	Bytes    uint64
+	Loss     float64
+	Duration time.Duration
+}
+
+// RunComplete is published when the test against a server is finished, Err is set if the test failed
+type RunComplete struct {
+	Server defs.Server
+	Result report.Result
+	Err    error
+}
+
+func (ServerSelected) event()   {}
+func (PhaseStarted) event()     {}
+func (PingSample) event()       {}
+func (ThroughputSample) event() {}
+func (PhaseComplete) event()    {}
+func (RunComplete) event()      {}
+
+// EventType returns the kind of an event, as used by the JSON encodings of events
+func EventType(e Event) string {
+	switch e.(type) {
+	case ServerSelected:
+		return "serverSelected"
+	case PhaseStarted:
+		return "phaseStarted"
+	case PingSample:
+		return "ping"
+	case ThroughputSample:
+		return "sample"
+	case PhaseComplete:
+		return "phaseComplete"
+	case RunComplete:
+		return "runComplete"
+	}
+	return ""
+}
+
+// Bus delivers the events of test runs to its subscribers
+type Bus struct {
+	lock     sync.RWMutex
+	channels []chan Event
+	handlers []func(Event)
+}
+
+// NewBus returns an event bus without subscribers
+func NewBus() *Bus {
+	return &Bus{}
+}
+
+// Subscribe returns a channel receiving the events published from now on. Events are dropped when the buffer of the
+// channel is full, so a slow consumer never stalls the measurement
+func (b *Bus) Subscribe(buffer int) <-chan Event {
+	ch := make(chan Event, buffer)
+
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.channels = append(b.channels, ch)
+	return ch
+}
+
+// Unsubscribe stops delivering events to a channel returned by Subscribe, and closes it
+func (b *Bus) Unsubscribe(ch <-chan Event) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	for i, c := range b.channels {
+		if c == ch {
+			b.channels = append(b.channels[:i], b.channels[i+1:]...)
+			close(c)
+			return
+		}
+	}
+}
+
+// Handle registers `fn` to be called synchronously with every event published from now on, for consumers which must
+// not miss any event. `fn` should return quickly as it blocks the measurement
+func (b *Bus) Handle(fn func(Event)) {
+	b.lock.Lock()
+	defer b.lock.Unlock()
+	b.handlers = append(b.handlers, fn)
+}
+
+// Publish delivers an event to all subscribers
+func (b *Bus) Publish(e Event) {
+	b.lock.RLock()
+	defer b.lock.RUnlock()
+
+	for _, fn := range b.handlers {
+		fn(e)
+	}
+	for _, ch := range b.channels {
+		select {
+		case ch <- e:
+		default:
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+)
+
+// the file of the favorite servers in defs.DataDir, a JSON array of server IDs or HOST:PORT of servers hosted with
+// `serve`
+const favoritesFile = "favorites.json"
+
+// favoritesPath returns the path of the favorite servers
+func favoritesPath() (string, error) {
+	dir, err := defs.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, favoritesFile), nil
+}
+
+// readFavorites returns the favorite servers, which are empty if none is added yet
+func readFavorites() ([]string, error) {
+	path, err := favoritesPath()
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var favorites []string
+	if err := json.Unmarshal(b, &favorites); err != nil {
+		return nil, err
+	}
+	return favorites, nil
+}
+
+// writeFavorites replaces the favorite servers
+func writeFavorites(favorites []string) error {
+	path, err := favoritesPath()
+	if err != nil {
+		return err
+	}
+	if favorites == nil {
+		favorites = []string{}
+	}
+	b, err := json.MarshalIndent(favorites, "", "  ")
+	if err != nil {
+		return err
+	}
+	return writeFile(path, append(b, '\n'))
+}
+
+// favoriteServer returns the fastest of the favorite servers, for --favorite
+func favoriteServer(c *cli.Context, opts *Options) (defs.Server, error) {
+	favorites, err := readFavorites()
+	if err != nil {
+		log.Errorf(i18n.T("Failed to read the favorite servers: %s"), err)
+		return defs.Server{}, err
+	}
+	if len(favorites) == 0 {
+		log.Error(i18n.T("No favorite server is added, add one with the favorite command"))
+		return defs.Server{}, ErrNoServer
+	}
+
+	var servers []defs.Server
+	var ids []string
+	for _, s := range serverAliases(favorites) {
+		if server, ok := directServer(s, directServerType(c)); ok {
+			servers = append(servers, server)
+		} else {
+			ids = append(ids, s)
+		}
+	}
+	if len(ids) > 0 {
+		log.Info(i18n.T("Retrieving server list"))
+		discovered, err := Discover(c.Context, Filters{IDs: ids, Options: opts})
+		if err != nil {
+			log.Errorf(i18n.T("Error when fetching server list: %s"), err)
+			return defs.Server{}, err
+		}
+		servers = append(servers, discovered...)
+	}
+	if excludes := serverAliases(c.StringSlice(defs.OptionExclude)); len(excludes) > 0 {
+		servers = preprocessServers(servers, excludes)
+	}
+
+	server, ok := selectServer(c.Context, "", servers, opts)
+	if !ok {
+		return defs.Server{}, ErrNoServer
+	}
+	return server, nil
+}
+
+// FavoriteAdd adds the server IDs or HOST:PORT given as arguments to the favorite servers
+func FavoriteAdd(c *cli.Context) error {
+	if err := setLang(c); err != nil {
+		return err
+	}
+	if c.NArg() == 0 {
+		log.Error(i18n.T("No server is given"))
+		return errors.New("invalid favorite setting")
+	}
+	favorites, err := readFavorites()
+	if err != nil {
+		log.Errorf(i18n.T("Failed to read the favorite servers: %s"), err)
+		return err
+	}
+	for _, s := range c.Args().Slice() {
+		if !contains(favorites, s) {
+			favorites = append(favorites, s)
+		}
+	}
+	if err := writeFavorites(favorites); err != nil {
+		log.Errorf(i18n.T("Failed to write the favorite servers: %s"), err)
+		return err
+	}
+	return nil
+}
+
+// FavoriteRemove removes the servers given as arguments from the favorite servers
+func FavoriteRemove(c *cli.Context) error {
+	if err := setLang(c); err != nil {
+		return err
+	}
+	favorites, err := readFavorites()
+	if err != nil {
+		log.Errorf(i18n.T("Failed to read the favorite servers: %s"), err)
+		return err
+	}
+	for _, s := range c.Args().Slice() {
+		if !contains(favorites, s) {
+			log.Warnf(i18n.T("Server %s is not a favorite"), s)
+		}
+	}
+	var kept []string
+	for _, s := range favorites {
+		if !contains(c.Args().Slice(), s) {
+			kept = append(kept, s)
+		}
+	}
+	if err := writeFavorites(kept); err != nil {
+		log.Errorf(i18n.T("Failed to write the favorite servers: %s"), err)
+		return err
+	}
+	return nil
+}
+
+// FavoriteList prints the favorite servers
+func FavoriteList(c *cli.Context) error {
+	if err := setLang(c); err != nil {
+		return err
+	}
+	favorites, err := readFavorites()
+	if err != nil {
+		log.Errorf(i18n.T("Failed to read the favorite servers: %s"), err)
+		return err
+	}
+	for _, s := range favorites {
+		fmt.Println(s)
+	}
+	return nil
+}
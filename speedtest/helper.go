@@ -1,7 +1,7 @@
 package speedtest
 
 import (
-	"bytes"
+	"context"
 	"crypto/md5"
 	"encoding/hex"
 	"encoding/json"
@@ -10,20 +10,22 @@ import (
 	"io"
 	"math"
 	"math/rand"
+	"net"
 	"net/http"
 	"net/url"
 	"os"
+	"path/filepath"
 	"runtime"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
-	"github.com/briandowns/spinner"
-	"github.com/gocarina/gocsv"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 
 	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
 	"github.com/ztelliot/taierspeed-cli/report"
 )
 
@@ -31,6 +33,15 @@ const (
 	// the default ping count for measuring ping and jitter
 	pingCount      = 5
 	GlobalSpeedAPI = "https://dlc.cnspeedtest.com:8043"
+
+	// the number of concurrent server availability checks, and the default timeout of each check
+	upCheckWorkers = 16
+	upCheckTimeout = 2 * time.Second
+
+	// the share of the link speed above which a result is considered limited by the link, and the link speed in Mbps
+	// up to which a link is considered slower than any current plan, e.g. a gigabit port negotiated down by a bad cable
+	linkBoundRatio = 0.95
+	slowLinkSpeed  = 100
 )
 
 func getRandom(tok, pre string, l int) string {
@@ -118,12 +129,12 @@ func coreApiDebug(resp *http.Response) {
 	}
 }
 
-func getServerList(c *cli.Context, servers *[]string, groups *[]string) ([]defs.ServerResponse, error) {
-	coreApi, err := url.Parse(c.String(defs.OptionAPIBase))
+func getServerList(ctx context.Context, client *http.Client, apiBase, apiVersion string, servers *[]string, groups *[]string) ([]defs.ServerResponse, error) {
+	coreApi, err := url.Parse(apiBase)
 	if err != nil {
 		return nil, err
 	}
-	u := coreApi.JoinPath(c.String(defs.OptionAPIVersion)).JoinPath("node")
+	u := coreApi.JoinPath(apiVersion).JoinPath("node")
 	v := url.Values{}
 	if servers != nil && len(*servers) > 0 {
 		v.Add("server", strings.Join(*servers, ","))
@@ -133,14 +144,14 @@ func getServerList(c *cli.Context, servers *[]string, groups *[]string) ([]defs.
 	}
 	u.RawQuery = v.Encode()
 
-	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("User-Agent", defs.ApiUA)
 
 	start := time.Now()
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -170,20 +181,20 @@ func getServerList(c *cli.Context, servers *[]string, groups *[]string) ([]defs.
 	return res.Data, nil
 }
 
-func getVersion(c *cli.Context) (*defs.Version, error) {
-	coreApi, err := url.Parse(c.String(defs.OptionAPIBase))
+func getVersion(ctx context.Context, client *http.Client, apiBase, apiVersion string) (*defs.Version, error) {
+	coreApi, err := url.Parse(apiBase)
 	if err != nil {
 		return nil, err
 	}
-	u := coreApi.JoinPath(c.String(defs.OptionAPIVersion)).JoinPath(fmt.Sprintf("version/latest/%s_%s", runtime.GOOS, runtime.GOARCH))
+	u := coreApi.JoinPath(apiVersion).JoinPath(fmt.Sprintf("version/latest/%s_%s", runtime.GOOS, runtime.GOARCH))
 
-	req, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("User-Agent", defs.ApiUA)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -212,17 +223,17 @@ func getVersion(c *cli.Context) (*defs.Version, error) {
 	return &res.Data, nil
 }
 
-func getGlobalServerList(ip string, ipv6 int) ([]defs.Server, error) {
+func getGlobalServerList(ctx context.Context, client *http.Client, ip string, ipv6 int) ([]defs.Server, error) {
 	var serversT []defs.ServerGlobal
 
 	uri := fmt.Sprintf("%s/dataServer/mobilematch_list.php?ip=%s&network=4&ipv6=%d", GlobalSpeedAPI, ip, ipv6)
-	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
 	if err != nil {
 		return nil, err
 	}
 	req.Header.Set("User-Agent", defs.AndroidUA)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return nil, err
 	}
@@ -251,25 +262,24 @@ func getGlobalServerList(ip string, ipv6 int) ([]defs.Server, error) {
 	return servers, nil
 }
 
-func enQueue(s defs.Server) string {
-	time.Local, _ = time.LoadLocation("Asia/Chongqing")
-	ts := strconv.Itoa(int(time.Now().Local().Unix()))
+func enQueue(ctx context.Context, s defs.Server) string {
+	ts := strconv.Itoa(int(time.Now().Unix()))
 	imei := getRandom("0123456789ABCDEF", "TS", 16)
 
 	md5Ctx := md5.New()
 	md5Ctx.Write([]byte(fmt.Sprintf("model=Android&imei=%s&stime=%s", imei, ts)))
 	token := hex.EncodeToString(md5Ctx.Sum(nil))
 
-	url := fmt.Sprintf("http://%s:%d/speed/dovalid?key=&flag=true&bandwidth=200&model=Android&imei=%s&time=%s&token=%s", s.Host, s.Port, imei, ts, token)
+	url := fmt.Sprintf("http://%s/speed/dovalid?key=&flag=true&bandwidth=200&model=Android&imei=%s&time=%s&token=%s", net.JoinHostPort(s.Host, strconv.Itoa(int(s.Port))), imei, ts, token)
 
-	req, err := http.NewRequest(http.MethodGet, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		log.Debugf("Failed when creating HTTP request: %s", err)
 		return ""
 	}
 	req.Header.Set("User-Agent", defs.AndroidUA)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.HTTPClient().Do(req)
 	if err != nil {
 		log.Debugf("Failed when making HTTP request: %s", err)
 		return ""
@@ -294,10 +304,10 @@ func enQueue(s defs.Server) string {
 	return string(b)[2:]
 }
 
-func deQueue(s defs.Server, key string) bool {
-	url := fmt.Sprintf("http://%s:%d/speed/dovalid?key=%s", s.Host, s.Port, key)
+func deQueue(ctx context.Context, s defs.Server, key string) bool {
+	url := fmt.Sprintf("http://%s/speed/dovalid?key=%s", net.JoinHostPort(s.Host, strconv.Itoa(int(s.Port))), key)
 
-	req, err := http.NewRequest(http.MethodPost, url, nil)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, nil)
 	if err != nil {
 		log.Debugf("Failed when creating HTTP request: %s", err)
 		return false
@@ -306,7 +316,7 @@ func deQueue(s defs.Server, key string) bool {
 	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
 	req.Header.Set("User-Agent", defs.AndroidUA)
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := s.HTTPClient().Do(req)
 	if err != nil {
 		log.Debugf("Failed when making HTTP request: %s", err)
 		return false
@@ -336,227 +346,413 @@ func MatchProvince(prov string, provinces *[]defs.ProvinceInfo) uint8 {
 }
 
 // doSpeedTest is where the actual speed test happens
-func doSpeedTest(c *cli.Context, servers []defs.Server, network string, silent, noICMP bool, ispInfo *defs.IPInfoResponse) error {
-	if !silent || c.Bool(defs.OptionSimple) {
+func doSpeedTest(c *cli.Context, servers []defs.Server, opts *Options, ui *uiOptions, ispInfo *defs.IPInfoResponse) (err error) {
+	silent, simple := ui.silent, ui.simple
+	var repsOut []report.Result
+
+	// the textfile is also written when the run fails, so that it shows in the time and results of the last run
+	if path := c.String(defs.OptionPromTextfile); path != "" {
+		defer func() {
+			if werr := writeFile(path, report.MarshalPrometheus(repsOut, time.Now())); werr != nil {
+				log.Errorf(i18n.T("Failed to write the Prometheus textfile: %s"), werr)
+				if err == nil {
+					err = werr
+				}
+			}
+		}()
+	}
+
+	progress := newProgress(ui)
+	progress.attach(opts)
+
+	if !silent || simple {
 		if serverCount := len(servers); serverCount > 1 {
-			fmt.Printf("Testing against %d servers: [ %s ]\n", serverCount, strings.Join(func() []string {
+			fmt.Fprintf(os.Stderr, i18n.T("Testing against %d servers: [ %s ]\n"), serverCount, strings.Join(func() []string {
 				var ret []string
 				for _, s := range servers {
-					ret = append(ret, s.Name)
+					ret = append(ret, i18n.Name(s.Name))
 				}
 				return ret
 			}(), ", "))
 		} else if serverCount == 0 {
-			fmt.Println("No server available")
+			fmt.Fprintln(os.Stderr, i18n.T("No server available"))
 			return nil
 		}
 		if ispInfo != nil {
+			var place string
 			if ispInfo.City == "" {
 				if ispInfo.Province == "" {
-					fmt.Printf("ISP:\t\t%s%s\n", ispInfo.Country, ispInfo.ISP)
+					place = i18n.Place(ispInfo.Country, ispInfo.ISP)
 				} else {
-					fmt.Printf("ISP:\t\t%s%s\n", ispInfo.Province, ispInfo.ISP)
+					place = i18n.Place(ispInfo.Province, ispInfo.ISP)
 				}
 			} else {
-				fmt.Printf("ISP:\t\t%s%s\n", ispInfo.City, ispInfo.ISP)
+				place = i18n.Place(ispInfo.City, ispInfo.ISP)
 			}
+			if ispInfo.IP != "" {
+				place = fmt.Sprintf("%s (%s)", ispInfo.IP, place)
+			}
+			fmt.Printf(i18n.T("Client:\t\t%s\n"), place)
 		}
 		if len(servers) > 1 {
-			fmt.Printf("\n")
+			fmt.Println()
 		}
 	}
+	hosting := ispInfo != nil && defs.IsHostingISP(ispInfo.ISP)
+	if hosting {
+		log.Warnf(i18n.T("The public IP %s belongs to %s, a hosting provider, the results likely measure the path of a VPN or proxy"), ispInfo.IP, ispInfo.ISP)
+	}
 
-	var repsOut []report.Result
+	up, _ := checkServers(c.Context, servers, opts, upCheckWorkers)
 
-	// fetch current user's IP info
-	for _, currentServer := range servers {
-		if !silent || c.Bool(defs.OptionSimple) {
-			name, ip := currentServer.Name, currentServer.IP
-			if currentServer.Type == defs.Perception {
-				name = fmt.Sprintf("%s - %s", currentServer.Name, defs.ISPMap[currentServer.ISP].Name)
-			}
-			if network == "ip6" {
-				ip = currentServer.IPv6
-			}
-			fmt.Printf("Server:\t\t%s [%s] (id = %s)\n", name, ip, currentServer.ID)
+	// the baseline of anomalies is taken from the results recorded before this run
+	var history []report.Record
+	if ui.verifyAnomalies > 0 {
+		var err error
+		if history, err = loadHistory(); err != nil {
+			log.Debugf("Failed to read the history: %s", err)
 		}
+	}
 
-		if currentServer.IsUp() {
-			// get ping and jitter value
-			var pb *spinner.Spinner
-			if !silent {
-				pb = spinner.New(spinner.CharSets[11], 100*time.Millisecond)
-				pb.Prefix = "Pinging...  "
-				pb.Start()
-			}
-
-			// skip ICMP if option given
-			currentServer.NoICMP = noICMP
+	for idx, currentServer := range servers {
+		if !silent || simple {
+			printServer(currentServer)
+		}
 
-			p, jitter, err := currentServer.ICMPPingAndJitter(pingCount, c.String(defs.OptionSource), network)
-			if err != nil {
-				log.Errorf("Failed to get ping and jitter: %s", err)
+		if up[idx] {
+			rep, err := runServer(c.Context, currentServer, opts)
+			progress.stop()
+			if errors.Is(err, ErrToken) {
+				log.Error(i18n.T("Get token failed"))
+				return nil
+			} else if err != nil {
 				return err
 			}
-
-			if pb != nil {
-				pb.FinalMSG = fmt.Sprintf("Latency:\t%.2f ms (%.2f ms jitter)\n", p, jitter)
-				pb.Stop()
-			} else if c.Bool(defs.OptionSimple) {
-				fmt.Printf("Latency:\t%.2f ms (%.2f ms jitter)\n", p, jitter)
-			}
-
-			token := ""
-			if currentServer.Type == defs.GlobalSpeed && !(c.Bool(defs.OptionNoDownload) && c.Bool(defs.OptionNoUpload)) {
-				token = enQueue(currentServer)
-				if len(token) <= 0 || token == "-" {
-					log.Errorf("Get token failed")
-					return nil
-				}
+			if ui.verifyAnomalies > 0 {
+				rep.Anomaly = verifyAnomaly(c, currentServer, rep, history, opts, ui, progress)
 			}
-
-			// get download value
-			var downloadValue float64
-			var bytesRead uint64
-			if c.Bool(defs.OptionNoDownload) {
-				log.Info("Download test is disabled")
-			} else {
-				download, br, err := currentServer.Download(silent, c.Bool(defs.OptionBytes), c.Bool(defs.OptionMebiBytes), c.Int(defs.OptionConcurrent), time.Duration(c.Int(defs.OptionDuration))*time.Second, token)
-				if err != nil {
-					log.Errorf("Failed to get download speed: %s", err)
-					return err
-				}
-				if c.Bool(defs.OptionSimple) {
-					if c.Bool(defs.OptionBytes) {
-						useMebi := c.Bool(defs.OptionMebiBytes)
-						fmt.Printf("Download:\t%s (data used: %s)\n", humanizeMbps(download, useMebi), humanizeBytes(br, useMebi))
-					} else {
-						fmt.Printf("Download:\t%.2f Mbps (data used: %.2f MB)\n", download, float64(br)/1000000)
-					}
+			if !silent || simple {
+				if rep.DNS > 0 {
+					fmt.Printf(i18n.T("DNS:\t\t%s ms\n"), i18n.Number(rep.DNS, 2))
 				}
-				downloadValue = download
-				bytesRead = br
-			}
-
-			// get upload value
-			var uploadValue float64
-			var bytesWritten uint64
-			if c.Bool(defs.OptionNoUpload) {
-				log.Info("Upload test is disabled")
-			} else {
-				upload, bw, err := currentServer.Upload(c.Bool(defs.OptionNoPreAllocate), silent, c.Bool(defs.OptionBytes), c.Bool(defs.OptionMebiBytes), c.Int(defs.OptionConcurrent), c.Int(defs.OptionUploadSize), time.Duration(c.Int(defs.OptionDuration))*time.Second, token)
-				if err != nil {
-					log.Errorf("Failed to get upload speed: %s", err)
-					return err
+				fmt.Printf(i18n.T("Confidence:\t%s\n"), formatConfidence(rep.Confidence))
+				if rep.MPTCP != nil {
+					fmt.Printf(i18n.T("MPTCP:\t\t%s\n"), formatMPTCP(rep.MPTCP))
 				}
-				if c.Bool(defs.OptionSimple) {
-					if c.Bool(defs.OptionBytes) {
-						useMebi := c.Bool(defs.OptionMebiBytes)
-						fmt.Printf("Upload:\t\t%s (data used: %s)\n", humanizeMbps(upload, useMebi), humanizeBytes(bw, useMebi))
-					} else {
-						fmt.Printf("Upload:\t\t%.2f Mbps (data used: %.2f MB)\n", upload, float64(bw)/1000000)
-					}
+				if rep.Anomaly != nil {
+					fmt.Printf(i18n.T("Anomaly:\t%s\n"), formatAnomaly(rep.Anomaly))
 				}
-				uploadValue = upload
-				bytesWritten = bw
-			}
-
-			if currentServer.Type == defs.GlobalSpeed && !(c.Bool(defs.OptionNoDownload) && c.Bool(defs.OptionNoUpload)) {
-				deQueue(currentServer, token)
-			}
-
-			// check for --csv or --json. the program prioritize the --csv before the --json. this is the same behavior as speedtest-cli
-			if c.Bool(defs.OptionCSV) || c.Bool(defs.OptionJSON) {
-				var rep report.Result
-				rep.Timestamp = time.Now()
-
-				rep.Ping = math.Round(p*100) / 100
-				rep.Jitter = math.Round(jitter*100) / 100
-				rep.Download = math.Round(downloadValue*100) / 100
-				rep.Upload = math.Round(uploadValue*100) / 100
-				rep.BytesReceived = bytesRead
-				rep.BytesSent = bytesWritten
-
-				rep.ID = currentServer.ID
-				switch network {
-				case "ip6":
-					rep.IP = currentServer.IPv6
-				default:
-					rep.IP = currentServer.IP
-				}
-				rep.Name = currentServer.Name
-				rep.Province = currentServer.Province
-				rep.City = currentServer.City
-				rep.ISP = defs.ISPMap[currentServer.ISP].Name
-
-				repsOut = append(repsOut, rep)
 			}
+			repsOut = append(repsOut, rep)
 		} else {
-			log.Infof("Selected server %s (%s) is not responding at the moment, try again later", currentServer.Name, currentServer.ID)
+			log.Infof(i18n.T("Selected server %s (%s) is not responding at the moment, try again later"), currentServer.Name, currentServer.ID)
 		}
 
 		//add a new line after each test if testing multiple servers
-		if len(servers) > 1 && (!silent || c.Bool(defs.OptionSimple)) {
+		if len(servers) > 1 && (!silent || simple) {
 			log.Warn()
 		}
 	}
 
+	if len(repsOut) > 0 && !c.Bool(defs.OptionNoHistory) && !c.Bool(defs.OptionSelfTest) {
+		if err := recordHistory(repsOut, ispInfo); err != nil {
+			log.Debugf("Failed to record the history: %s", err)
+		}
+	}
+
+	// the JSON report is written with --json and shared with --share
+	var jsonRep report.JSONReport
+	if c.Bool(defs.OptionJSON) || ui.shareURL != "" {
+		if ispInfo != nil {
+			jsonRep.Client = *ispInfo
+		}
+		jsonRep.Results, jsonRep.Tunnel, jsonRep.Metadata = repsOut, hosting, ui.metadataVersion
+		for _, r := range repsOut {
+			jsonRep.Tunnel = jsonRep.Tunnel || (r.Link != nil && r.Link.Tunnel)
+		}
+		if ui.signKey != nil {
+			if err := jsonRep.Sign(ui.signKey); err != nil {
+				log.Errorf(i18n.T("Failed to sign the report: %s"), err)
+				return err
+			}
+		}
+	}
+
 	// check for --csv or --json. the program prioritize the --csv before the --json. this is the same behavior as speedtest-cli
-	if c.Bool(defs.OptionCSV) {
-		var buf bytes.Buffer
-		if err := gocsv.MarshalWithoutHeaders(&repsOut, &buf); err != nil {
-			log.Errorf("Error generating CSV report: %s", err)
+	if c.String(defs.OptionFormat) == defs.FormatLegacyCSV {
+		var client string
+		if ispInfo != nil {
+			client = ispInfo.IP
+		}
+		legacy := make([]report.LegacyResult, len(repsOut))
+		for i, rep := range repsOut {
+			legacy[i] = rep.Legacy(client)
+		}
+		if b, err := report.MarshalLegacyCSV(legacy, []rune(c.String(defs.OptionCSVDelimiter))[0], false); err != nil {
+			log.Errorf(i18n.T("Error generating CSV report: %s"), err)
 		} else {
-			os.Stdout.WriteString(buf.String())
+			os.Stdout.Write(b)
+		}
+	} else if c.Bool(defs.OptionCSV) {
+		if b, err := report.MarshalCSV(repsOut, []rune(c.String(defs.OptionCSVDelimiter))[0], false); err != nil {
+			log.Errorf(i18n.T("Error generating CSV report: %s"), err)
+		} else {
+			os.Stdout.Write(b)
 		}
 	} else if c.Bool(defs.OptionJSON) {
-		if b, err := json.Marshal(&report.JSONReport{Client: *ispInfo, Results: repsOut}); err != nil {
-			log.Errorf("Error generating JSON report: %s", err)
+		if b, err := jsonRep.Marshal(); err != nil {
+			log.Errorf(i18n.T("Error generating JSON report: %s"), err)
 		} else {
 			os.Stdout.Write(b[:])
 		}
+	} else if c.Bool(defs.OptionBrief) {
+		for _, rep := range repsOut {
+			fmt.Println(progress.brief(rep))
+		}
+	}
+
+	if ui.shareURL != "" && len(repsOut) > 0 {
+		// the link is written to stderr, so it doesn't mix with the results on stdout
+		if link, err := shareReport(c.Context, opts.client(), ui.shareURL, &jsonRep); err != nil {
+			log.Errorf(i18n.T("Failed to share the report: %s"), err)
+		} else if !silent || simple {
+			fmt.Fprintf(os.Stderr, i18n.T("Share:\t\t%s\n"), link)
+		} else {
+			fmt.Fprintln(os.Stderr, link)
+		}
 	}
 
 	return nil
 }
 
-func humanizeMbps(mbps float64, useMebi bool) string {
-	val := mbps / 8
-	var base float64 = 1000
-	if useMebi {
-		base = 1024
+// printServer prints the details of the server before testing against it, so the results are self-describing
+func printServer(server defs.Server) {
+	fmt.Printf(i18n.T("Server:\t\t%s (id = %s)\n"), i18n.Name(server.Name), colorDim(server.ID))
+	if place := i18n.Place(server.Province, server.City); place != "" {
+		fmt.Printf(i18n.T("Location:\t%s\n"), colorDim(place))
 	}
+	if isp := defs.ISPMap[server.ISP]; isp != nil && isp.Name != "" {
+		fmt.Printf(i18n.T("ISP:\t\t%s\n"), colorDim(i18n.Name(isp.Name)))
+	}
+	var addrs []string
+	for _, ip := range []string{server.IP, server.IPv6} {
+		if ip != "" {
+			addrs = append(addrs, ip)
+		}
+	}
+	if len(addrs) > 0 {
+		fmt.Printf(i18n.T("Address:\t%s\n"), colorDim(strings.Join(addrs, ", ")))
+	}
+	fmt.Printf(i18n.T("Type:\t\t%s\n"), colorDim(server.Type))
+}
 
-	if val < 1 {
-		if kb := val * base; kb < 1 {
-			return fmt.Sprintf("%.2f bytes/s", kb*base)
-		} else {
-			return fmt.Sprintf("%.2f KB/s", kb)
+// checkServers checks the availability of the servers to test against concurrently on a pool of `workers` goroutines,
+// servers not checked before `ctx` is done, or whose check is cut short by it, are reported as skipped
+func checkServers(ctx context.Context, servers []defs.Server, opts *Options, workers int) (up []bool, skipped []bool) {
+	return upCheck(ctx, servers, opts, workers, opts.target)
+}
+
+// checkCandidates checks the availability of servers like checkServers, without the overrides for the selected server
+// so that every server is checked at its own endpoints
+func checkCandidates(ctx context.Context, servers []defs.Server, opts *Options, workers int) (up []bool, skipped []bool) {
+	return upCheck(ctx, servers, opts, workers, opts.prepare)
+}
+
+// upCheck checks the availability of servers prepared by `prepare`, all servers are up without checking with
+// --skip-upcheck
+func upCheck(ctx context.Context, servers []defs.Server, opts *Options, workers int, prepare func(*defs.Server)) (up []bool, skipped []bool) {
+	up = make([]bool, len(servers))
+	if opts.SkipUpCheck {
+		for i := range up {
+			up[i] = true
 		}
-	} else if val > base {
-		return fmt.Sprintf("%.2f GB/s", val/base)
-	} else {
-		return fmt.Sprintf("%.2f MB/s", val)
+		return up, make([]bool, len(servers))
+	}
+	timeout := opts.UpCheckTimeout
+	if timeout <= 0 {
+		timeout = upCheckTimeout
+	}
+	// a check cut short when `ctx` is done doesn't tell whether the server is down
+	interrupted := make([]bool, len(servers))
+	skipped = parallel(ctx, len(servers), workers, func(i int) {
+		server := servers[i]
+		prepare(&server)
+		up[i] = server.IsUp(ctx, timeout)
+		interrupted[i] = !up[i] && ctx.Err() != nil
+	})
+	for i := range skipped {
+		skipped[i] = skipped[i] || interrupted[i]
+	}
+	return up, skipped
+}
+
+// parallel calls `fn` for every index in [0, n) on a pool of at most `workers` goroutines, and waits for all calls to
+// return. Once `ctx` is done the remaining indexes are skipped, and reported in the returned array
+func parallel(ctx context.Context, n, workers int, fn func(i int)) []bool {
+	workers = max(min(workers, n), 1)
+
+	skipped := make([]bool, n)
+	var wg sync.WaitGroup
+	jobs := make(chan int)
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for idx := range jobs {
+				if ctx.Err() != nil {
+					skipped[idx] = true
+					continue
+				}
+				fn(idx)
+			}
+		}()
+	}
+
+	for i := 0; i < n; i++ {
+		jobs <- i
 	}
+	close(jobs)
+	wg.Wait()
+
+	return skipped
 }
 
-// humanizeBytes returns the Bytes/KiloBytes/MegaBytes/GigaBytes (or Bytes/KibiBytes/MebiBytes/GibiBytes)
-func humanizeBytes(bytes uint64, useMebi bool) string {
-	val := float64(bytes) / 8
-	var base float64 = 1000
-	if useMebi {
-		base = 1024
+// sampleCPU takes a snapshot of the CPU time at the start of a test phase, nil if not supported
+func sampleCPU() *defs.CPUSample {
+	sample, err := defs.SampleCPU()
+	if err != nil {
+		log.Debugf("Failed to sample CPU usage: %s", err)
 	}
+	return sample
+}
 
-	if val < 1 {
-		if kb := val * base; kb < 1 {
-			return fmt.Sprintf("%.2f bytes", kb*base)
-		} else {
-			return fmt.Sprintf("%.2f KB", kb)
-		}
-	} else if val > base {
-		return fmt.Sprintf("%.2f GB", val/base)
+// cpuUsageSince returns the CPU utilization of a test phase started at `start`, and warns if the phase seems CPU-bound
+func cpuUsageSince(phase string, start *defs.CPUSample) *defs.CPUUsage {
+	if start == nil {
+		return nil
+	}
+	end, err := defs.SampleCPU()
+	if err != nil {
+		log.Debugf("Failed to sample CPU usage: %s", err)
+		return nil
+	}
+
+	usage := end.UsageSince(start)
+	log.Debugf("CPU usage during %s: %.1f%% process, %.1f%% of a core, %.1f%% system", phase, usage.Process, usage.Core, usage.System)
+	switch {
+	case usage.CoreBound() && math.Max(usage.Process, usage.System) < usage.Core:
+		log.Warnf(i18n.T("CPU usage was %.0f%% of a core during %s test, the result is likely limited by this device rather than the network"), usage.Core, i18n.T(phase))
+	case usage.Bound():
+		log.Warnf(i18n.T("CPU usage was %.0f%% during %s test, the result is likely limited by this device rather than the network"), math.Max(usage.Process, usage.System), i18n.T(phase))
+	}
+	return &usage
+}
+
+// linkOf returns the link the server is reached through, which is `iface` if the test is bound to it, and warns if the
+// link is a tunnel or seems to limit the result
+func linkOf(server defs.Server, iface string, rep report.Result) *defs.Link {
+	var link *defs.Link
+	if iface != "" {
+		link = defs.LinkOf(iface)
 	} else {
-		return fmt.Sprintf("%.2f MB", val)
+		var err error
+		if link, err = defs.LinkTo(server.Host); err != nil {
+			log.Debugf("Failed to get the link to the server: %s", err)
+			return nil
+		}
+	}
+
+	if link.Tunnel {
+		log.Warnf(i18n.T("The server is reached through the tunnel %s, the result measures the path of the VPN"), link.Interface)
+	}
+	if link.Speed == 0 {
+		return link
+	}
+	log.Debugf("Link speed of %s: %d Mbps", link.Interface, link.Speed)
+	if link.Speed <= slowLinkSpeed {
+		log.Warnf(i18n.T("The link of %s negotiated only %d Mbps, check the cable and the port if your plan is faster"), link.Interface, link.Speed)
 	}
+	if max(rep.Download, rep.Upload) >= float64(link.Speed)*linkBoundRatio {
+		log.Warnf(i18n.T("The result is close to the link speed of %s (%d Mbps), it's likely limited by the link rather than the network"), link.Interface, link.Speed)
+	}
+	return link
+}
+
+// formatMPTCP returns how many connections used MPTCP
+func formatMPTCP(usage *defs.MPTCPUsage) string {
+	if usage.Accepted == 0 {
+		return i18n.T("not used, the kernel or the server doesn't support it")
+	}
+	return fmt.Sprintf(i18n.T("%d of %d connections, %d using multiple paths"), usage.Accepted, usage.Conns, usage.Multipath)
+}
+
+// writeFile replaces the file at `path` with `b`. It's written to a temporary file first, so that it's never left half
+// written
+func writeFile(path string, b []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	// temporary files are only readable by the owner, but e.g. node_exporter reads the Prometheus textfile
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return err
+	}
+	if _, err := f.Write(b); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), path)
+}
+
+// parseSize parses a human readable size like `64MiB`, `512k` or `1G` into bytes
+func parseSize(s string) (int64, error) {
+	s = strings.TrimSpace(s)
+	idx := strings.IndexFunc(s, func(r rune) bool {
+		return (r < '0' || r > '9') && r != '.'
+	})
+	num, unit := s, ""
+	if idx >= 0 {
+		num, unit = s[:idx], strings.ToLower(strings.TrimSpace(s[idx:]))
+	}
+
+	val, err := strconv.ParseFloat(num, 64)
+	if err != nil {
+		return 0, err
+	}
+
+	var mul float64
+	switch unit {
+	case "", "b":
+		mul = 1
+	case "k", "kb":
+		mul = 1000
+	case "ki", "kib":
+		mul = 1 << 10
+	case "m", "mb":
+		mul = 1000 * 1000
+	case "mi", "mib":
+		mul = 1 << 20
+	case "g", "gb":
+		mul = 1000 * 1000 * 1000
+	case "gi", "gib":
+		mul = 1 << 30
+	default:
+		return 0, fmt.Errorf("unknown size unit: %s", unit)
+	}
+
+	return int64(val * mul), nil
+}
+
+// setLang sets the language of user-facing messages given by --lang, detecting it from the locale by default
+func setLang(c *cli.Context) error {
+	if lang := c.String(defs.OptionLang); !i18n.Set(lang) {
+		log.Errorf(i18n.T("Unknown language: %s is given"), lang)
+		return errors.New("invalid language setting")
+	}
+	return nil
 }
@@ -0,0 +1,159 @@
+package speedtest
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+// the file of the local result history in defs.DataDir, in JSON Lines
+const historyFile = "history.jsonl"
+
+// historyPath returns the path of the local result history
+func historyPath() (string, error) {
+	dir, err := defs.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, historyFile), nil
+}
+
+// loadHistory returns the records of the local result history, which is empty if not recorded yet
+func loadHistory() ([]report.Record, error) {
+	path, err := historyPath()
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return report.ReadHistory(f)
+}
+
+// saveHistory replaces the local result history with `records`, it's written by writeFile so it's never left half
+// written
+func saveHistory(records []report.Record) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	var buf bytes.Buffer
+	if err := report.WriteHistory(&buf, records); err != nil {
+		return err
+	}
+	return writeFile(path, buf.Bytes())
+}
+
+// recordHistory appends the results of a test run to the local result history
+func recordHistory(results []report.Result, ispInfo *defs.IPInfoResponse) error {
+	path, err := historyPath()
+	if err != nil {
+		return err
+	}
+	probe, _ := os.Hostname()
+	var client string
+	if ispInfo != nil {
+		client = ispInfo.IP
+	}
+	records := make([]report.Record, len(results))
+	for i, r := range results {
+		records[i] = report.Record{Result: r, Probe: probe, Client: client}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := report.WriteHistory(f, records); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// HistoryExport writes the local result history to stdout in the format given by --format, to be imported on another
+// machine with `history import`
+func HistoryExport(c *cli.Context) error {
+	if err := setLang(c); err != nil {
+		return err
+	}
+
+	records, err := loadHistory()
+	if err != nil {
+		log.Errorf(i18n.T("Failed to read the history: %s"), err)
+		return err
+	}
+
+	switch format := c.String(defs.OptionFormat); format {
+	case defs.FormatJSONL:
+		return report.WriteHistory(os.Stdout, records)
+	case defs.FormatCSV:
+		b, err := report.MarshalHistoryCSV(records, ',', true)
+		if err != nil {
+			log.Errorf(i18n.T("Error generating CSV report: %s"), err)
+			return err
+		}
+		_, err = os.Stdout.Write(b)
+		return err
+	default:
+		log.Errorf(i18n.T("Unknown output format: %s is given"), format)
+		return errors.New("invalid format setting")
+	}
+}
+
+// HistoryImport merges the histories exported to the files given as arguments into the local result history, records
+// already in the local history are skipped. "-" reads from stdin
+func HistoryImport(c *cli.Context) error {
+	if err := setLang(c); err != nil {
+		return err
+	}
+	if c.NArg() == 0 {
+		log.Error(i18n.T("No file to import is given"))
+		return errors.New("invalid import setting")
+	}
+
+	records, err := loadHistory()
+	if err != nil {
+		log.Errorf(i18n.T("Failed to read the history: %s"), err)
+		return err
+	}
+
+	for _, name := range c.Args().Slice() {
+		f := os.Stdin
+		if name != "-" {
+			if f, err = os.Open(name); err != nil {
+				log.Errorf(i18n.T("Failed to read %s: %s"), name, err)
+				return err
+			}
+		}
+		imported, err := report.ReadHistory(f)
+		f.Close()
+		if err != nil {
+			log.Errorf(i18n.T("Failed to read %s: %s"), name, err)
+			return err
+		}
+
+		var added int
+		records, added = report.MergeHistory(records, imported)
+		fmt.Printf(i18n.T("Imported %d of %d records from %s\n"), added, len(imported), name)
+	}
+
+	if err := saveHistory(records); err != nil {
+		log.Errorf(i18n.T("Failed to write the history: %s"), err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,138 @@
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+// interfacesMode tests the server given by --server or the fastest server nearby once through each interface given by
+// --interfaces, then prints a comparison of the interfaces
+func interfacesMode(c *cli.Context, opts *Options, ui *uiOptions) error {
+	// binding to an interface is only supported on linux and android, elsewhere every pass would take the default route
+	if runtime.GOOS != "linux" && runtime.GOOS != "android" {
+		log.Errorf(i18n.T("--%s is only available for linux"), defs.OptionInterfaces)
+		return errors.New("invalid interfaces setting")
+	}
+	ifaces := c.StringSlice(defs.OptionInterfaces)
+	for _, iface := range ifaces {
+		if _, err := net.InterfaceByName(iface); err != nil {
+			log.Errorf(i18n.T("Unknown interface: %s is given"), iface)
+			return errors.New("invalid interfaces setting")
+		}
+	}
+
+	servers, err := givenOrFastestServers(c, opts)
+	if err != nil {
+		return err
+	}
+	server := servers[0]
+
+	human := !c.Bool(defs.OptionCSV) && !c.Bool(defs.OptionJSON)
+	progress := newProgress(ui)
+	progress.attach(opts)
+	if human {
+		printServer(server)
+	}
+
+	var results []report.Result
+	var tested []string
+	for _, iface := range ifaces {
+		if human {
+			fmt.Printf(i18n.T("\nInterface:\t%s\n"), iface)
+		}
+		bound := bindInterface(opts, iface)
+		up, _ := checkServers(c.Context, []defs.Server{server}, bound, 1)
+		if !up[0] {
+			log.Warnf(i18n.T("Server %s (%s) is not reachable through %s, skipping"), server.Name, server.ID, iface)
+			continue
+		}
+		rep, err := runServer(c.Context, server, bound)
+		progress.stop()
+		if c.Context.Err() != nil {
+			return nil
+		}
+		if err != nil {
+			log.Warnf(i18n.T("Test through %s failed: %s"), iface, err)
+			continue
+		}
+		results = append(results, rep)
+		tested = append(tested, iface)
+	}
+
+	switch {
+	case c.Bool(defs.OptionCSV):
+		if b, err := report.MarshalCSV(results, []rune(c.String(defs.OptionCSVDelimiter))[0], false); err != nil {
+			log.Errorf(i18n.T("Error generating CSV report: %s"), err)
+		} else {
+			os.Stdout.Write(b)
+		}
+	case c.Bool(defs.OptionJSON):
+		// the interface of each result is given by its link
+		if b, err := (&report.JSONReport{Results: results, Metadata: ui.metadataVersion}).Marshal(); err != nil {
+			log.Errorf(i18n.T("Error generating JSON report: %s"), err)
+		} else {
+			os.Stdout.Write(append(b, '\n'))
+		}
+	default:
+		fmt.Println()
+		fmt.Println(i18n.T("Interface\tPing\t\tJitter\t\tDownload\tUpload"))
+		for i, rep := range results {
+			fmt.Printf("%s\t\t%s ms\t\t%s ms\t\t%s\t%s\n", tested[i], i18n.Number(rep.Ping, 2), i18n.Number(rep.Jitter, 2),
+				progress.formatRate(rep.Download), progress.formatRate(rep.Upload))
+		}
+	}
+
+	if len(results) == 0 {
+		return errors.New("no interface is tested")
+	}
+	return nil
+}
+
+// bindInterface returns a copy of the options with all test traffic bound to `iface`, HTTP traffic keeps the settings
+// of opts.Transport other than the dialer. ICMP ping can't be bound, so HTTP ping is used
+func bindInterface(opts *Options, iface string) *Options {
+	dialer := newInterfaceDialer(iface)
+	if opts.Dialer != nil {
+		dialer.SetMultipathTCP(opts.Dialer.MultipathTCP())
+	}
+	var dial func(context.Context, string, string) (net.Conn, error)
+	switch opts.Network {
+	case "ip4":
+		dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp4", address)
+		}
+	case "ip6":
+		dial = func(ctx context.Context, network, address string) (net.Conn, error) {
+			return dialer.DialContext(ctx, "tcp6", address)
+		}
+	default:
+		dial = dialer.DialContext
+	}
+	if opts.MPTCP != nil {
+		dial = opts.MPTCP.Dial(dial)
+	}
+
+	var transport *http.Transport
+	if t, ok := opts.Transport.(*http.Transport); ok {
+		transport = t.Clone()
+	} else {
+		transport = http.DefaultTransport.(*http.Transport).Clone()
+	}
+	transport.DialContext = dial
+
+	bound := *opts
+	bound.Transport, bound.Dialer, bound.Interface, bound.NoICMP = transport, dialer, iface, true
+	return &bound
+}
@@ -0,0 +1,52 @@
+package speedtest
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"strconv"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+)
+
+const (
+	// the port of the built-in server tried first when --lan is given without a port
+	lanServePort = 8080
+	// the concurrency of LAN tests unless --concurrent is given, a single stream rarely fills a local link
+	lanConcurrent = 8
+)
+
+// lanServer returns the server at `addr` on the local network, given as HOST or HOST:PORT. The iperf3 port selects an
+// iperf3 server and other ports the built-in server, without a port the built-in server is tried before iperf3
+func lanServer(ctx context.Context, addr string, opts *Options) (defs.Server, error) {
+	if _, p, err := net.SplitHostPort(addr); err == nil {
+		server, ok := directServer(addr, defs.GlobalSpeed)
+		if !ok {
+			return defs.Server{}, fmt.Errorf("invalid address %s", addr)
+		}
+		if p == strconv.Itoa(defs.IPerf3Port) {
+			server.Type = defs.IPerf3
+		}
+		return server, nil
+	}
+
+	host := addr
+	server, _ := directServer(net.JoinHostPort(host, strconv.Itoa(lanServePort)), defs.GlobalSpeed)
+	if server.Host == "" {
+		return defs.Server{}, fmt.Errorf("invalid address %s", addr)
+	}
+	opts.target(&server)
+	if server.IsUp(ctx, opts.SelectionTimeout) {
+		return server, nil
+	}
+	log.Debugf("No built-in server on %s, trying iperf3", server.ID)
+
+	server, _ = directServer(net.JoinHostPort(host, strconv.Itoa(defs.IPerf3Port)), defs.IPerf3)
+	opts.target(&server)
+	if server.IsUp(ctx, opts.SelectionTimeout) {
+		return server, nil
+	}
+	return defs.Server{}, fmt.Errorf("neither a built-in server on port %d nor an iperf3 server on port %d found at %s", lanServePort, defs.IPerf3Port, host)
+}
@@ -0,0 +1,173 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+// the width of the latency distribution drawn after every round of the ping monitor
+const smokeWidth = 30
+
+// pingStats are the RTTs of a server in the ping monitor by the hour of the day
+type pingStats struct {
+	rtts map[int][]float64
+	sent map[int]int
+	// peak is the highest RTT so far, the scale of the drawn distributions
+	peak float64
+}
+
+// pingMonitor pings the servers given by --server and --group, or the fastest server nearby, with --pings pings every
+// --interval seconds for --count rounds or until interrupted, without running throughput tests. The latency
+// distribution and loss of every round are exported, and the hourly stats of every server are printed when done
+func pingMonitor(c *cli.Context, opts *Options) error {
+	sched, err := periodicSchedule(c)
+	if err != nil {
+		return err
+	}
+	pings := c.Int(defs.OptionPings)
+	if pings <= 0 {
+		log.Errorf(i18n.T("Pings cannot be lower than 1: %d is given"), pings)
+		return errors.New("invalid pings setting")
+	}
+
+	servers, err := givenOrFastestServers(c, opts)
+	if err != nil {
+		return err
+	}
+	log.Infof(i18n.T("Pinging %d servers %d times every %s"), len(servers), pings, sched.interval)
+
+	delimiter := []rune(c.String(defs.OptionCSVDelimiter))[0]
+	human := !c.Bool(defs.OptionCSV) && !c.Bool(defs.OptionJSON)
+	stats := make([]pingStats, len(servers))
+	for i := range stats {
+		stats[i] = pingStats{rtts: make(map[int][]float64), sent: make(map[int]int)}
+	}
+	if human {
+		defer printHourly(servers, stats)
+	}
+
+	count := c.Int(defs.OptionCount)
+	for round := 0; count <= 0 || round < count; round++ {
+		if !sched.wait(c.Context) {
+			return nil
+		}
+
+		results := make([]report.Latency, len(servers))
+		rtts := make([][]float64, len(servers))
+		parallel(c.Context, len(servers), opts.SelectionConcurrency, func(i int) {
+			results[i], rtts[i] = pingRound(c, servers[i], opts, pings)
+		})
+		if c.Context.Err() != nil {
+			return nil
+		}
+
+		for i, l := range results {
+			st := &stats[i]
+			hour := l.Timestamp.Hour()
+			st.rtts[hour] = append(st.rtts[hour], rtts[i]...)
+			st.sent[hour] += l.Sent
+			st.peak = max(st.peak, l.Max)
+
+			switch {
+			case c.Bool(defs.OptionCSV):
+				if b, err := report.MarshalLatenciesCSV([]report.Latency{l}, delimiter, round == 0 && i == 0); err != nil {
+					log.Errorf(i18n.T("Error generating CSV report: %s"), err)
+				} else {
+					os.Stdout.Write(b)
+				}
+			case c.Bool(defs.OptionJSON):
+				// one line per server and round
+				if b, err := json.Marshal(l); err != nil {
+					log.Errorf(i18n.T("Error generating JSON report: %s"), err)
+				} else {
+					os.Stdout.Write(append(b, '\n'))
+				}
+			default:
+				median := gradeLow(i18n.Number(l.Median, 2)+" ms", l.Median, goodLatency, fairLatency)
+				loss := gradeLow(i18n.Number(l.Loss, 2)+"%", l.Loss, goodLoss, fairLoss)
+				fmt.Printf(i18n.T("%s\t%s: median %s (%s - %s ms), loss %s\t%s\n"), l.Timestamp.Format(time.DateTime), i18n.Name(l.Name),
+					median, i18n.Number(l.Min, 2), i18n.Number(l.Max, 2), loss, smoke(l, st.peak))
+			}
+		}
+	}
+	return nil
+}
+
+// pingRound pings the server `pings` times, pings without reply are counted as lost. The RTTs are returned as well
+func pingRound(c *cli.Context, server defs.Server, opts *Options, pings int) (report.Latency, []float64) {
+	l := report.Latency{ID: server.ID, Name: server.Name, IP: server.Host, Timestamp: time.Now()}
+
+	var rtts []float64
+	opts.target(&server)
+	if _, _, err := server.ICMPPingAndJitter(c.Context, pings, opts.Source, opts.Network, func(rtt float64) {
+		rtts = append(rtts, rtt)
+	}); err != nil {
+		log.Debugf("Can't ping server %s (%s): %s", server.Name, server.ID, err)
+	}
+	l.Distribute(rtts, pings)
+	if len(rtts) > pings {
+		rtts = rtts[:pings]
+	}
+	return l, rtts
+}
+
+// smoke draws the latency distribution of a round scaled to `peak`, the median is drawn solid, RTTs up to the 95th
+// percentile dense and the slowest ones sparse
+func smoke(l report.Latency, peak float64) string {
+	if l.Received == 0 || peak <= 0 {
+		return ""
+	}
+	cell := func(v float64) int {
+		return min(int(v/peak*smokeWidth), smokeWidth-1)
+	}
+	median := cell(l.Median)
+	var b strings.Builder
+	for i := 0; i <= cell(l.Max); i++ {
+		switch {
+		case i == median:
+			b.WriteString(gradeLow("█", l.Median, goodLatency, fairLatency))
+		case i < cell(l.Min):
+			b.WriteRune(' ')
+		case i <= cell(l.P95):
+			b.WriteString(colorDim("▒"))
+		default:
+			b.WriteString(colorDim("░"))
+		}
+	}
+	return b.String()
+}
+
+// printHourly prints the latency distribution and loss of every server by the hour of the day
+func printHourly(servers []defs.Server, stats []pingStats) {
+	for i, server := range servers {
+		st := stats[i]
+		if len(st.sent) == 0 {
+			continue
+		}
+		fmt.Printf(i18n.T("\nHourly stats of %s (id = %s):\n"), i18n.Name(server.Name), server.ID)
+
+		var hours []int
+		for hour := range st.sent {
+			hours = append(hours, hour)
+		}
+		sort.Ints(hours)
+		for _, hour := range hours {
+			var l report.Latency
+			l.Distribute(st.rtts[hour], st.sent[hour])
+			fmt.Printf(i18n.T("  %02d:00\tmedian %s ms, 95th percentile %s ms, loss %s%% of %d pings\n"), hour,
+				i18n.Number(l.Median, 2), i18n.Number(l.P95, 2), i18n.Number(l.Loss, 2), l.Sent)
+		}
+	}
+}
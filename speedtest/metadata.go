@@ -0,0 +1,168 @@
+package speedtest
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+)
+
+const (
+	// the metadata bundle in the project's repository at a git ref, which is the main branch for the latest bundle or
+	// the tag metadata-VERSION for a pinned one, and the file caching it in defs.DataDir
+	metadataURL    = "https://raw.githubusercontent.com/ztelliot/taierspeed-cli/%s/metadata.json"
+	metadataRef    = "main"
+	metadataTag    = "metadata-"
+	metadataFile   = "metadata.json"
+	metadataPinned = "metadata-%s.json"
+
+	// the age after which the cached latest bundle is refreshed, and the timeout of refreshing it
+	metadataMaxAge  = 24 * time.Hour
+	metadataTimeout = 5 * time.Second
+)
+
+// provinceList is the province list of the metadata bundle, the embedded province list is used when empty
+var provinceList []defs.ProvinceInfo
+
+// metadataSource is where the metadata bundle comes from
+type metadataSource struct {
+	// update refreshes the cached latest bundle when it's older than metadataMaxAge
+	update bool
+	// version pins the bundle to a released version, which is cached for good once fetched
+	version string
+	// checksum is the SHA-256 in hex the bundle must match if not empty
+	checksum string
+}
+
+// ref returns the git ref and cache file of the bundle
+func (s metadataSource) ref() (string, string) {
+	if s.version != "" {
+		return metadataTag + s.version, fmt.Sprintf(metadataPinned, s.version)
+	}
+	return metadataRef, metadataFile
+}
+
+// loadMetadata applies the cached metadata bundle and returns its version. A pinned bundle is fetched if it's not
+// cached yet, the latest bundle is refreshed if it's older than metadataMaxAge and updates are enabled. The built-in
+// mappings are kept if neither the cache nor the repository has a valid bundle, which is only an error if the bundle
+// is pinned by version or checksum
+func loadMetadata(ctx context.Context, client *http.Client, src metadataSource) (string, error) {
+	pinned := src.version != "" || src.checksum != ""
+	ref, file := src.ref()
+	dir, err := defs.DataDir()
+	if err != nil {
+		log.Debugf("Failed to locate the metadata cache: %s", err)
+		if pinned {
+			return "", err
+		}
+		return "", nil
+	}
+	path := filepath.Join(dir, file)
+
+	info, err := os.Stat(path)
+	if (src.version != "" && err != nil) || (src.version == "" && src.update && (err != nil || time.Since(info.ModTime()) > metadataMaxAge)) {
+		if err := fetchMetadata(ctx, client, ref, path, src.checksum); err != nil {
+			log.Debugf("Failed to update the metadata: %s", err)
+			// keep using the cache without retrying on every run
+			now := time.Now()
+			os.Chtimes(path, now, now)
+		}
+	}
+
+	m, err := readMetadata(path, src.checksum)
+	if err == nil && src.version != "" && m.Version != src.version {
+		err = fmt.Errorf("version %s is cached instead", m.Version)
+	}
+	if err != nil {
+		if pinned {
+			log.Errorf(i18n.T("Failed to load the pinned metadata: %s"), err)
+			return "", err
+		}
+		if !errors.Is(err, os.ErrNotExist) {
+			log.Debugf("Failed to read the metadata cache: %s", err)
+		}
+		return "", nil
+	}
+	applyMetadata(m)
+	log.Debugf("Using metadata version %s", m.Version)
+	return m.Version, nil
+}
+
+// readMetadata reads the bundle at `path`, which must match `checksum` if not empty
+func readMetadata(path, checksum string) (*defs.Metadata, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return parseMetadata(b, checksum)
+}
+
+// parseMetadata parses a bundle, which must match `checksum` if not empty
+func parseMetadata(b []byte, checksum string) (*defs.Metadata, error) {
+	if checksum != "" {
+		sum := sha256.Sum256(b)
+		if actual := hex.EncodeToString(sum[:]); !strings.EqualFold(actual, checksum) {
+			return nil, fmt.Errorf("checksum mismatch, the bundle is %s", actual)
+		}
+	}
+	var m defs.Metadata
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	if m.Version == "" {
+		return nil, errors.New("metadata without version")
+	}
+	return &m, nil
+}
+
+// applyMetadata replaces the built-in mappings with the ones of the bundle
+func applyMetadata(m *defs.Metadata) {
+	m.Apply()
+	if len(m.Provinces) > 0 {
+		provinceList = m.Provinces
+	}
+}
+
+// fetchMetadata downloads the metadata bundle at the git ref `ref` to `path`, the cache is only replaced by a valid
+// bundle matching `checksum` if not empty
+func fetchMetadata(ctx context.Context, client *http.Client, ref, path, checksum string) error {
+	ctx, cancel := context.WithTimeout(ctx, metadataTimeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fmt.Sprintf(metadataURL, ref), nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", defs.ApiUA)
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	b, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	m, err := parseMetadata(b, checksum)
+	if err != nil {
+		return err
+	}
+	log.Debugf("Updated metadata to version %s", m.Version)
+	return writeFile(path, b)
+}
@@ -0,0 +1,282 @@
+package speedtest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+	"golang.org/x/term"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+const (
+	// the number of rows of each chart
+	monitorChartHeight = 5
+	// the interval between two redraws of the screen
+	monitorRedraw = 500 * time.Millisecond
+)
+
+// monitor renders the charts of the results of the last --history hours in monitor mode
+type monitor struct {
+	progress *cliProgress
+	history  time.Duration
+	sched    *schedule
+
+	lock    sync.Mutex
+	results []report.Result
+	server  defs.Server
+	// phase and rate are the state of the running test, phase is empty between tests
+	phase   Phase
+	rate    float64
+	next    time.Time
+	lastErr error
+}
+
+// monitorMode tests the servers given by --server and --group round-robin every --interval seconds like watchMode,
+// rendering scrolling charts of the download, upload and ping of the last --history hours in the terminal
+func monitorMode(c *cli.Context, opts *Options, ui *uiOptions) error {
+	sched, err := periodicSchedule(c)
+	if err != nil {
+		return err
+	}
+	history := time.Duration(c.Float64(defs.OptionHistory) * float64(time.Hour))
+	if history < time.Minute {
+		log.Errorf(i18n.T("History must be at least 1 minute: %s hours is given"), c.String(defs.OptionHistory))
+		return errors.New("invalid history setting")
+	}
+	if !term.IsTerminal(int(os.Stdout.Fd())) {
+		log.Errorf(i18n.T("--%s requires a terminal"), defs.OptionMonitor)
+		return errors.New("invalid monitor setting")
+	}
+
+	servers, err := resolveServers(c, opts)
+	if err != nil {
+		return err
+	}
+
+	m := &monitor{progress: newProgress(ui), history: history, sched: sched}
+	if opts.Events == nil {
+		opts.Events = NewBus()
+	}
+	opts.Events.Handle(m.handle)
+
+	// logs would scroll the screen, failures are shown in the status line instead
+	log.SetOutput(io.Discard)
+	defer log.SetOutput(os.Stderr)
+	fmt.Print("\x1b[?25l")
+	defer fmt.Print("\x1b[?25h\n")
+
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		ticker := time.NewTicker(monitorRedraw)
+		defer ticker.Stop()
+		for {
+			m.draw()
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	count := c.Int(defs.OptionCount)
+	for n := 0; count <= 0 || n < count; n++ {
+		m.lock.Lock()
+		m.next = sched.next()
+		m.lock.Unlock()
+		if !sched.wait(c.Context) {
+			return nil
+		}
+
+		server := servers[n%len(servers)]
+		m.lock.Lock()
+		m.server = server
+		m.lock.Unlock()
+
+		var rep report.Result
+		up, _ := checkServers(c.Context, []defs.Server{server}, opts, 1)
+		if up[0] {
+			rep, err = runServer(c.Context, server, opts)
+		} else {
+			err = ErrServerDown
+		}
+		if c.Context.Err() != nil {
+			return nil
+		}
+		m.record(rep, err)
+	}
+	m.draw()
+	return nil
+}
+
+// handle tracks the phase and rate of the running test
+func (m *monitor) handle(e Event) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	switch e := e.(type) {
+	case PhaseStarted:
+		m.phase, m.rate = e.Phase, 0
+	case ThroughputSample:
+		m.rate = e.Rate
+	}
+}
+
+// record adds the result of a test and drops the results older than --history
+func (m *monitor) record(rep report.Result, err error) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	m.phase = ""
+	m.lastErr = err
+	if err == nil {
+		m.results = append(m.results, rep)
+	}
+	cutoff := time.Now().Add(-m.history)
+	for len(m.results) > 0 && m.results[0].Timestamp.Before(cutoff) {
+		m.results = m.results[1:]
+	}
+}
+
+// draw renders the screen, lines are overwritten in place to avoid flickering
+func (m *monitor) draw() {
+	width, height, err := term.GetSize(int(os.Stdout.Fd()))
+	if err != nil {
+		width, height = 80, 24
+	}
+
+	m.lock.Lock()
+	lines := m.render(max(width-2, 10), time.Now())
+	m.lock.Unlock()
+
+	if len(lines) > height {
+		lines = lines[:height]
+	}
+	var b strings.Builder
+	b.WriteString("\x1b[H")
+	for i, l := range lines {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString(l)
+		b.WriteString("\x1b[K")
+	}
+	b.WriteString("\x1b[J")
+	os.Stdout.WriteString(b.String())
+}
+
+// render returns the lines of the screen with charts of `cols` columns, each column is the average of the results in
+// its share of --history up to `now`
+func (m *monitor) render(cols int, now time.Time) []string {
+	span := m.history / time.Duration(cols)
+	start := now.Add(-time.Duration(cols) * span)
+	download, upload, ping := make([]float64, cols), make([]float64, cols), make([]float64, cols)
+	counts := make([]int, cols)
+	for _, r := range m.results {
+		col := int(r.Timestamp.Sub(start) / span)
+		if col < 0 || col >= cols {
+			continue
+		}
+		download[col] += r.Download
+		upload[col] += r.Upload
+		ping[col] += r.Ping
+		counts[col]++
+	}
+	for i, n := range counts {
+		if n == 0 {
+			download[i], upload[i], ping[i] = math.NaN(), math.NaN(), math.NaN()
+			continue
+		}
+		download[i] /= float64(n)
+		upload[i] /= float64(n)
+		ping[i] /= float64(n)
+	}
+
+	lines := []string{
+		fmt.Sprintf(i18n.T("%s monitor, testing every %s over the last %s"), defs.ProgName, shortDuration(m.sched.interval), shortDuration(m.history)),
+		"",
+	}
+	lines = append(lines, chart(i18n.T("Download"), download, m.progress.formatRate, func(s string, v float64) string {
+		return gradeHigh(s, v, goodDownload, fairDownload)
+	})...)
+	lines = append(lines, chart(i18n.T("Upload"), upload, m.progress.formatRate, func(s string, v float64) string {
+		return gradeHigh(s, v, goodUpload, fairUpload)
+	})...)
+	lines = append(lines, chart(i18n.T("Ping"), ping, func(v float64) string {
+		return i18n.Number(v, 2) + " ms"
+	}, func(s string, v float64) string {
+		return gradeLow(s, v, goodLatency, fairLatency)
+	})...)
+
+	oldest, newest := "-"+shortDuration(m.history), i18n.T("now")
+	lines = append(lines, colorDim(oldest+strings.Repeat(" ", max(cols-len(oldest)-len(newest), 1))+newest), "")
+
+	name := i18n.Name(m.server.Name)
+	switch {
+	case m.phase == PhaseDownload || m.phase == PhaseUpload:
+		lines = append(lines, fmt.Sprintf(i18n.T("Testing against %s: %s %s"), name, i18n.T(string(m.phase)), m.progress.formatRate(m.rate)))
+	case m.phase != "" || !time.Now().Before(m.next):
+		lines = append(lines, fmt.Sprintf(i18n.T("Testing against %s"), name))
+	case m.lastErr != nil:
+		lines = append(lines, fmt.Sprintf(i18n.T("Test against %s (%s) failed: %s"), name, m.server.ID, m.lastErr))
+		fallthrough
+	default:
+		lines = append(lines, fmt.Sprintf(i18n.T("Next test in %ds"), int(math.Ceil(max(time.Until(m.next), 0).Seconds()))))
+	}
+	return lines
+}
+
+// chart returns a bar chart of `values` under a title with the latest, average and highest value, columns without
+// results are NaN and left empty. Bars are colored by `grade`
+func chart(title string, values []float64, format func(float64) string, grade func(string, float64) string) []string {
+	var peak, sum, latest float64
+	var n int
+	for _, v := range values {
+		if math.IsNaN(v) {
+			continue
+		}
+		peak, sum, latest = max(peak, v), sum+v, v
+		n++
+	}
+
+	header := title
+	if n > 0 {
+		header = fmt.Sprintf(i18n.T("%s  now %s, average %s, max %s"), title, format(latest), format(sum/float64(n)), format(peak))
+	}
+	lines := []string{header}
+	for row := monitorChartHeight - 1; row >= 0; row-- {
+		var b strings.Builder
+		for _, v := range values {
+			// the height of the bar in eighths of a row
+			eighths := 0
+			if !math.IsNaN(v) && peak > 0 {
+				eighths = max(int(math.Round(v/peak*monitorChartHeight*8)), 1)
+			}
+			switch fill := eighths - row*8; {
+			case fill <= 0:
+				b.WriteRune(' ')
+			case fill >= 8:
+				b.WriteString(grade(string(sparkBars[7]), v))
+			default:
+				b.WriteString(grade(string(sparkBars[fill-1]), v))
+			}
+		}
+		lines = append(lines, b.String())
+	}
+	return lines
+}
+
+// shortDuration returns `d` without zero minutes and seconds, e.g. 24h instead of 24h0m0s
+func shortDuration(d time.Duration) string {
+	return strings.TrimSuffix(strings.TrimSuffix(d.String(), "0s"), "0m")
+}
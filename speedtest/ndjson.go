@@ -0,0 +1,74 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// progressLine is a line of the NDJSON progress output, durations are in seconds
+type progressLine struct {
+	Type      string   `json:"type"`
+	Timestamp string   `json:"timestamp"`
+	ID        string   `json:"id,omitempty"`
+	Name      string   `json:"name,omitempty"`
+	Phase     Phase    `json:"phase,omitempty"`
+	Seq       int      `json:"seq,omitempty"`
+	RTT       *float64 `json:"rtt,omitempty"`
+	Rate      *float64 `json:"rate,omitempty"`
+	Bytes     *uint64  `json:"bytes,omitempty"`
+	Elapsed   *float64 `json:"elapsed,omitempty"`
+	Remaining *float64 `json:"remaining,omitempty"`
+	Ping      *float64 `json:"ping,omitempty"`
+	Jitter    *float64 `json:"jitter,omitempty"`
+	Loss      *float64 `json:"loss,omitempty"`
+	Duration  *float64 `json:"duration,omitempty"`
+	Error     string   `json:"error,omitempty"`
+}
+
+// ndjsonProgress returns an event handler writing every event as a line of JSON to `w`
+func ndjsonProgress(w io.Writer) func(Event) {
+	var lock sync.Mutex
+	enc := json.NewEncoder(w)
+	return func(e Event) {
+		line := newProgressLine(e)
+
+		lock.Lock()
+		defer lock.Unlock()
+		enc.Encode(line)
+	}
+}
+
+// newProgressLine returns the progress line of an event
+func newProgressLine(e Event) progressLine {
+	line := progressLine{Type: EventType(e), Timestamp: time.Now().Format(time.RFC3339Nano)}
+	switch e := e.(type) {
+	case ServerSelected:
+		line.ID, line.Name = e.Server.ID, e.Server.Name
+	case PhaseStarted:
+		line.Phase = e.Phase
+	case PingSample:
+		line.Seq, line.RTT = e.Seq, &e.RTT
+	case ThroughputSample:
+		elapsed, remaining := e.Elapsed.Seconds(), e.Remaining.Seconds()
+		line.Phase, line.Rate, line.Bytes, line.Elapsed, line.Remaining = e.Phase, &e.Rate, &e.Bytes, &elapsed, &remaining
+	case PhaseComplete:
+		duration := e.Duration.Seconds()
+		line.Phase, line.Duration = e.Phase, &duration
+		switch e.Phase {
+		case PhasePing:
+			line.Ping, line.Jitter = &e.Ping, &e.Jitter
+		case PhaseDownload, PhaseUpload:
+			line.Rate, line.Bytes = &e.Rate, &e.Bytes
+		case PhaseLoss:
+			line.Loss = &e.Loss
+		}
+	case RunComplete:
+		line.ID, line.Name = e.Server.ID, e.Server.Name
+		if e.Err != nil {
+			line.Error = e.Err.Error()
+		}
+	}
+	return line
+}
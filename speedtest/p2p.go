@@ -0,0 +1,118 @@
+package speedtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+)
+
+const (
+	// the number of UDP probes for measuring packet loss to a peer, and the interval between them
+	lossProbes        = 100
+	lossProbeInterval = 20 * time.Millisecond
+	// how long to wait for the echo of the last probe
+	lossProbeTimeout = time.Second
+)
+
+// the prefix of UDP probes, other packets are not echoed
+var probeMagic = []byte("TSP1")
+
+// serveEcho echoes the UDP probes received on `conn` until it's closed
+func serveEcho(conn net.PacketConn) {
+	buf := make([]byte, 64)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if !errors.Is(err, net.ErrClosed) {
+				log.Debugf("Failed to read UDP probe: %s", err)
+			}
+			return
+		}
+		if n < len(probeMagic) || !bytes.Equal(buf[:len(probeMagic)], probeMagic) {
+			continue
+		}
+		conn.WriteTo(buf[:n], addr)
+	}
+}
+
+// measureLoss sends `count` UDP probes to a peer listening with --listen, and returns the percentage of probes
+// without an echo
+func measureLoss(ctx context.Context, server defs.Server, network string, count int) (float64, error) {
+	udp := "udp"
+	switch network {
+	case "ip4":
+		udp = "udp4"
+	case "ip6":
+		udp = "udp6"
+	}
+
+	var d net.Dialer
+	if server.Dialer != nil {
+		d = *server.Dialer
+		// the dialer is bound for TCP, bind the probes to the same source address
+		if addr, ok := d.LocalAddr.(*net.TCPAddr); ok {
+			d.LocalAddr = &net.UDPAddr{IP: addr.IP, Zone: addr.Zone}
+		}
+	}
+	conn, err := d.DialContext(ctx, udp, net.JoinHostPort(server.Host, strconv.Itoa(int(server.Port))))
+	if err != nil {
+		return 0, err
+	}
+	defer conn.Close()
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	defer stop()
+
+	// receive the echos while sending, duplicates are counted once
+	received := make(chan int, 1)
+	go func() {
+		seen := make(map[uint32]bool)
+		buf := make([]byte, 64)
+		for {
+			n, err := conn.Read(buf)
+			if err != nil {
+				break
+			}
+			if n == len(probeMagic)+4 && bytes.Equal(buf[:len(probeMagic)], probeMagic) {
+				seen[binary.BigEndian.Uint32(buf[len(probeMagic):])] = true
+			}
+		}
+		received <- len(seen)
+	}()
+
+	probe := make([]byte, len(probeMagic)+4)
+	copy(probe, probeMagic)
+	ticker := time.NewTicker(lossProbeInterval)
+	defer ticker.Stop()
+	for seq := 0; seq < count; seq++ {
+		binary.BigEndian.PutUint32(probe[len(probeMagic):], uint32(seq))
+		if _, err := conn.Write(probe); err != nil {
+			log.Debugf("Failed to send UDP probe: %s", err)
+		}
+		select {
+		case <-ctx.Done():
+			return 0, ctx.Err()
+		case <-ticker.C:
+		}
+	}
+
+	select {
+	case <-ctx.Done():
+		return 0, ctx.Err()
+	case <-time.After(lossProbeTimeout):
+	}
+	conn.Close()
+	n := <-received
+	if n == 0 {
+		return 0, errors.New("no UDP probes echoed, the peer might not be listening with --listen")
+	}
+
+	return float64(count-n) / float64(count) * 100, nil
+}
@@ -0,0 +1,233 @@
+package speedtest
+
+import (
+	"errors"
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+// the periods of the report command by --period
+var reportPeriods = map[string]time.Duration{
+	"week":  7 * 24 * time.Hour,
+	"month": 30 * 24 * time.Hour,
+}
+
+// the width of the bars of the daily chart in characters
+const reportBarWidth = 30
+
+// the bars of a horizontal bar chart from one to seven eighths of a character
+var eighthBars = []rune("▏▎▍▌▋▊▉")
+
+// reportSection is a section of a report with a table, rendered as text, Markdown or HTML. The second cell of every row
+// gets a bar of the length given by `bars` between 0 and 1, if not nil
+type reportSection struct {
+	title  string
+	header []string
+	rows   [][]string
+	bars   []float64
+}
+
+// Report prints the statistics of the local history over the last week or month given by --period, as text,
+// Markdown or HTML given by --format, to be shared with the ISP
+func Report(c *cli.Context) error {
+	if err := setLang(c); err != nil {
+		return err
+	}
+	name := c.String(defs.OptionPeriod)
+	period, ok := reportPeriods[name]
+	if !ok {
+		log.Errorf(i18n.T("Unknown period: %s is given"), name)
+		return errors.New("invalid period setting")
+	}
+	format := c.String(defs.OptionFormat)
+	if format != defs.FormatText && format != defs.FormatMarkdown && format != defs.FormatHTML {
+		log.Errorf(i18n.T("Unknown output format: %s is given"), format)
+		return errors.New("invalid format setting")
+	}
+
+	records, err := loadHistory()
+	if err != nil {
+		log.Errorf(i18n.T("Failed to read the history: %s"), err)
+		return err
+	}
+	now := time.Now()
+	p := report.SummarizePeriod(records, now.Add(-period), now)
+	if p.Tests == 0 {
+		log.Errorf(i18n.T("No results in the history of the last %s"), i18n.T(name))
+		return errors.New("empty history")
+	}
+
+	title := fmt.Sprintf(i18n.T("Speed test report of the last %s"), i18n.T(name))
+	subtitle := fmt.Sprintf(i18n.T("%s to %s, %d tests"), p.From.Format(time.DateTime), p.To.Format(time.DateTime), p.Tests)
+	sections := reportSections(p)
+	switch format {
+	case defs.FormatMarkdown:
+		os.Stdout.WriteString(markdownReport(title, subtitle, sections))
+	case defs.FormatHTML:
+		os.Stdout.WriteString(htmlReport(title, subtitle, sections))
+	default:
+		os.Stdout.WriteString(textReport(title, subtitle, sections))
+	}
+	return nil
+}
+
+// reportSections lays out the statistics of a period
+func reportSections(p report.Period) []reportSection {
+	mbps := func(v float64) string { return i18n.Number(v, 2) + " Mbps" }
+	ms := func(v float64) string { return i18n.Number(v, 2) + " ms" }
+
+	overview := reportSection{
+		title:  i18n.T("Overview"),
+		header: []string{"", i18n.T("Average"), i18n.T("Median"), i18n.T("5th percentile"), i18n.T("95th percentile"), i18n.T("Min"), i18n.T("Max")},
+	}
+	for _, m := range []struct {
+		name   string
+		d      report.Distribution
+		format func(float64) string
+	}{
+		{i18n.T("Download"), p.Download, mbps},
+		{i18n.T("Upload"), p.Upload, mbps},
+		{i18n.T("Ping"), p.Ping, ms},
+		{i18n.T("Jitter"), p.Jitter, ms},
+	} {
+		overview.rows = append(overview.rows, []string{m.name, m.format(m.d.Avg), m.format(m.d.Median), m.format(m.d.P5),
+			m.format(m.d.P95), m.format(m.d.Min), m.format(m.d.Max)})
+	}
+
+	daily := reportSection{
+		title:  i18n.T("Daily averages"),
+		header: []string{i18n.T("Date"), i18n.T("Download"), i18n.T("Upload"), i18n.T("Ping"), i18n.T("Tests")},
+	}
+	var peak float64
+	for _, d := range p.Days {
+		peak = max(peak, d.Download)
+	}
+	for _, d := range p.Days {
+		daily.rows = append(daily.rows, []string{d.Start.Format(time.DateOnly), mbps(d.Download), mbps(d.Upload), ms(d.Ping), fmt.Sprint(d.Tests)})
+		bar := 0.0
+		if peak > 0 {
+			bar = d.Download / peak
+		}
+		daily.bars = append(daily.bars, bar)
+	}
+
+	worst := reportSection{
+		title:  i18n.T("Worst hours of the day"),
+		header: []string{i18n.T("Hour"), i18n.T("Download"), i18n.T("Upload"), i18n.T("Ping"), i18n.T("Tests")},
+	}
+	for _, h := range p.WorstHours {
+		hours := fmt.Sprintf("%02d:00-%02d:00", h.Start.Hour(), (h.Start.Hour()+1)%24)
+		worst.rows = append(worst.rows, []string{hours, mbps(h.Download), mbps(h.Upload), ms(h.Ping), fmt.Sprint(h.Tests)})
+	}
+
+	servers := reportSection{
+		title:  i18n.T("Servers"),
+		header: []string{i18n.T("Server"), i18n.T("Download"), i18n.T("Upload"), i18n.T("Ping"), i18n.T("Tests")},
+	}
+	for _, s := range p.Servers {
+		servers.rows = append(servers.rows, []string{fmt.Sprintf("%s (%s)", i18n.Name(s.Name), s.ID), mbps(s.Download.Avg),
+			mbps(s.Upload.Avg), ms(s.Ping), fmt.Sprint(s.Tests)})
+	}
+
+	return []reportSection{overview, daily, worst, servers}
+}
+
+// textReport renders a report as tab separated text
+func textReport(title, subtitle string, sections []reportSection) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%s\n%s\n", title, subtitle)
+	for _, s := range sections {
+		fmt.Fprintf(&b, "\n%s\n%s\n", s.title, strings.Join(s.header, "\t"))
+		for i, row := range s.rows {
+			fmt.Fprintf(&b, "%s\n", strings.Join(barRow(s, i, row), "\t"))
+		}
+	}
+	return b.String()
+}
+
+// markdownReport renders a report as Markdown tables
+func markdownReport(title, subtitle string, sections []reportSection) string {
+	escape := strings.NewReplacer("|", `\|`).Replace
+	line := func(cells []string) string {
+		escaped := make([]string, len(cells))
+		for i, c := range cells {
+			escaped[i] = escape(c)
+		}
+		return "| " + strings.Join(escaped, " | ") + " |\n"
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "# %s\n\n%s\n", title, subtitle)
+	for _, s := range sections {
+		fmt.Fprintf(&b, "\n## %s\n\n", s.title)
+		b.WriteString(line(s.header))
+		b.WriteString(strings.Repeat("| --- ", len(s.header)) + "|\n")
+		for i, row := range s.rows {
+			b.WriteString(line(barRow(s, i, row)))
+		}
+	}
+	return b.String()
+}
+
+// htmlReport renders a report as a standalone HTML page, bars are drawn with CSS
+func htmlReport(title, subtitle string, sections []reportSection) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "<!DOCTYPE html>\n<html>\n<head>\n<meta charset=\"utf-8\">\n<title>%s</title>\n", html.EscapeString(title))
+	b.WriteString("<style>body{font-family:sans-serif;margin:2em}table{border-collapse:collapse;margin-bottom:2em}" +
+		"th,td{border:1px solid #ccc;padding:.3em .8em;text-align:left}.bar{background:#4a90d9;height:.8em;display:inline-block;margin-right:.5em}</style>\n")
+	fmt.Fprintf(&b, "</head>\n<body>\n<h1>%s</h1>\n<p>%s</p>\n", html.EscapeString(title), html.EscapeString(subtitle))
+	for _, s := range sections {
+		fmt.Fprintf(&b, "<h2>%s</h2>\n<table>\n<tr>", html.EscapeString(s.title))
+		for _, h := range s.header {
+			fmt.Fprintf(&b, "<th>%s</th>", html.EscapeString(h))
+		}
+		b.WriteString("</tr>\n")
+		for i, row := range s.rows {
+			b.WriteString("<tr>")
+			for j, cell := range row {
+				bar := ""
+				if j == 1 && s.bars != nil {
+					bar = fmt.Sprintf("<span class=\"bar\" style=\"width:%.0fpx\"></span>", s.bars[i]*float64(reportBarWidth*8))
+				}
+				fmt.Fprintf(&b, "<td>%s%s</td>", bar, html.EscapeString(cell))
+			}
+			b.WriteString("</tr>\n")
+		}
+		b.WriteString("</table>\n")
+	}
+	b.WriteString("</body>\n</html>\n")
+	return b.String()
+}
+
+// barRow returns the cells of a row with the bar of the row drawn in front of the value of the second cell
+func barRow(s reportSection, i int, row []string) []string {
+	if s.bars == nil || len(row) < 2 {
+		return row
+	}
+	cells := append([]string(nil), row...)
+	cells[1] = hbar(s.bars[i], reportBarWidth) + " " + cells[1]
+	return cells
+}
+
+// hbar returns a horizontal bar of `width` characters filled to `v` between 0 and 1, in eighths of a character
+func hbar(v float64, width int) string {
+	eighths := int(math.Round(max(min(v, 1), 0) * float64(width*8)))
+	var b strings.Builder
+	b.WriteString(strings.Repeat(string(sparkBars[7]), eighths/8))
+	if rem := eighths % 8; rem > 0 {
+		b.WriteRune(eighthBars[rem-1])
+	}
+	b.WriteString(strings.Repeat(" ", width-(eighths+7)/8))
+	return b.String()
+}
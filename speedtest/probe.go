@@ -0,0 +1,192 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+// the ping count of every probe
+const probePingCount = 3
+
+// probeRound is the JSON output of a probe round
+type probeRound struct {
+	Timestamp time.Time      `json:"timestamp"`
+	Servers   []report.Probe `json:"servers"`
+}
+
+// probe checks the servers are up and pings them on `sched` for `count` rounds or until interrupted, without
+// running throughput tests. The availability of every server is exported after each round
+func probe(c *cli.Context, servers []defs.Server, opts *Options, sched *schedule, count int) error {
+	log.Infof(i18n.T("Probing %d servers every %s"), len(servers), sched.interval)
+
+	delimiter := []rune(c.String(defs.OptionCSVDelimiter))[0]
+	probes := make([]int, len(servers))
+	ups := make([]int, len(servers))
+
+	for round := 0; count <= 0 || round < count; round++ {
+		if !sched.wait(c.Context) {
+			return nil
+		}
+
+		results := probeRound{Timestamp: time.Now(), Servers: probeServers(c.Context, servers, opts)}
+		if c.Context.Err() != nil {
+			return nil
+		}
+		for i := range results.Servers {
+			probes[i]++
+			if results.Servers[i].Up {
+				ups[i]++
+			}
+			results.Servers[i].Availability = float64(ups[i]) / float64(probes[i]) * 100
+		}
+
+		switch {
+		case c.Bool(defs.OptionCSV):
+			if b, err := report.MarshalProbesCSV(results.Servers, delimiter, round == 0); err != nil {
+				log.Errorf(i18n.T("Error generating CSV report: %s"), err)
+			} else {
+				os.Stdout.Write(b)
+			}
+		case c.Bool(defs.OptionJSON):
+			// one line per round
+			if b, err := json.Marshal(results); err != nil {
+				log.Errorf(i18n.T("Error generating JSON report: %s"), err)
+			} else {
+				os.Stdout.Write(append(b, '\n'))
+			}
+		default:
+			fmt.Printf("%s\n", results.Timestamp.Format(time.DateTime))
+			for _, p := range results.Servers {
+				status := i18n.T("down")
+				if p.Up {
+					status = fmt.Sprintf(i18n.T("up, %s ms"), i18n.Number(p.Ping, 2))
+				}
+				fmt.Printf(i18n.T("  %s: %s (%s) %s, %s%% available\n"), p.ID, i18n.Name(p.Name), i18n.Place(p.Province, p.ISP), status, i18n.Number(p.Availability, 2))
+			}
+		}
+	}
+	return nil
+}
+
+// probeServers checks all servers once, servers pinged with failures are reported as down
+func probeServers(ctx context.Context, servers []defs.Server, opts *Options) []report.Probe {
+	results := make([]report.Probe, len(servers))
+	up, _ := checkCandidates(ctx, servers, opts, opts.SelectionConcurrency)
+	parallel(ctx, len(servers), opts.SelectionConcurrency, func(i int) {
+		server := servers[i]
+		res := &results[i]
+		res.ID, res.Name, res.Province, res.City, res.ISP = server.ID, server.Name, server.Province, server.City, defs.ISPMap[server.ISP].Name
+		res.IP = server.IP
+		if opts.Network == "ip6" {
+			res.IP = server.IPv6
+		}
+		res.Timestamp = time.Now()
+		if !up[i] {
+			return
+		}
+
+		opts.prepare(&server)
+		p, _, err := server.ICMPPingAndJitter(ctx, probePingCount, opts.Source, opts.Network, nil)
+		if err != nil {
+			log.Debugf("Can't ping server %s (%s): %s", server.Name, server.ID, err)
+			return
+		}
+		res.Up, res.Ping = true, p
+	})
+	return results
+}
+
+// probeMode runs the probe mode against the servers given by --server and --group, or all servers if none is given
+func probeMode(c *cli.Context, opts *Options) error {
+	sched, err := periodicSchedule(c)
+	if err != nil {
+		return err
+	}
+
+	servers, err := resolveServers(c, opts)
+	if err != nil {
+		return err
+	}
+	return probe(c, servers, opts, sched, c.Int(defs.OptionCount))
+}
+
+// resolveServers returns all servers given by --server and --group without selecting the fastest ones, or all servers
+// if none is given
+func resolveServers(c *cli.Context, opts *Options) ([]defs.Server, error) {
+	var servers []defs.Server
+	var ids []string
+	for _, s := range c.StringSlice(defs.OptionServer) {
+		s = serverAlias(s)
+		if server, ok := directServer(s, directServerType(c)); ok {
+			servers = append(servers, server)
+		} else {
+			ids = append(ids, s)
+		}
+	}
+
+	groups := c.StringSlice(defs.OptionServerGroup)
+	if len(ids) > 0 || len(groups) > 0 || len(servers) == 0 {
+		// the client location is only needed for `lo` groups
+		var ispInfo *defs.IPInfoResponse
+		for _, g := range groups {
+			if strings.Contains(g, "lo") {
+				ispInfo, _ = defs.GetIPInfo(c.Context, opts.client())
+				break
+			}
+		}
+
+		log.Info(i18n.T("Retrieving server list"))
+		discovered, err := Discover(c.Context, Filters{IDs: ids, Groups: groups, Client: ispInfo, Options: opts})
+		if err != nil {
+			log.Errorf(i18n.T("Error when fetching server list: %s"), err)
+			return nil, err
+		}
+		servers = append(servers, discovered...)
+	}
+	if excludes := serverAliases(c.StringSlice(defs.OptionExclude)); len(excludes) > 0 {
+		servers = preprocessServers(servers, excludes)
+	}
+	if len(servers) == 0 {
+		return nil, ErrNoServer
+	}
+	return servers, nil
+}
+
+// givenOrFastestServers returns the servers given by --server and --group like resolveServers, or the fastest server
+// nearby if none is given, or the fastest favorite server with --favorite
+func givenOrFastestServers(c *cli.Context, opts *Options) ([]defs.Server, error) {
+	if c.IsSet(defs.OptionServer) || c.IsSet(defs.OptionServerGroup) {
+		return resolveServers(c, opts)
+	}
+	if c.Bool(defs.OptionFavorite) {
+		server, err := favoriteServer(c, opts)
+		if err != nil {
+			return nil, err
+		}
+		return []defs.Server{server}, nil
+	}
+
+	ispInfo, _ := defs.GetIPInfo(c.Context, opts.client())
+	log.Info(i18n.T("Retrieving server list"))
+	candidates, err := defaultServers(c.Context, opts, ispInfo)
+	if err != nil {
+		log.Errorf(i18n.T("Error when fetching server list: %s"), err)
+		return nil, err
+	}
+	server, ok := selectServer(c.Context, "", candidates, opts)
+	if !ok {
+		return nil, ErrNoServer
+	}
+	return []defs.Server{server}, nil
+}
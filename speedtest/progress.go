@@ -0,0 +1,240 @@
+package speedtest
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/briandowns/spinner"
+
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+// uiOptions configures the progress output of the CLI
+type uiOptions struct {
+	silent   bool
+	simple   bool
+	useBytes bool
+	useMebi  bool
+	// unit is the unit of rates given by --unit, they are scaled to their magnitude if nil
+	unit *rateUnit
+	// signKey signs the JSON report if not nil
+	signKey *report.SigningKey
+	// shareURL is the endpoint the JSON report is shared to, not shared if empty
+	shareURL string
+	// metadataVersion is the version of the metadata bundle classifying the servers, empty for the built-in mappings
+	metadataVersion string
+	// verifyAnomalies is the deviation in percent from the baseline above which a result is tested again, not
+	// checked if 0
+	verifyAnomalies float64
+	// verifyServer is the server tested again instead of the same one, by ID, HOST:PORT or alias
+	verifyServer string
+}
+
+// cliProgress renders the progress of a test with spinners, or with plain lines in simple mode
+type cliProgress struct {
+	ui *uiOptions
+
+	lock sync.Mutex
+	pb   *spinner.Spinner
+	last ThroughputSample
+	// rates are the current rates between the last samples, for the sparkline
+	rates []float64
+}
+
+// the number of samples in the sparkline of a transfer phase
+const sparklineSamples = 30
+
+// the bars of sparklines from low to high
+var sparkBars = []rune("▁▂▃▄▅▆▇█")
+
+// newProgress returns the progress renderer for `ui`
+func newProgress(ui *uiOptions) *cliProgress {
+	return &cliProgress{ui: ui}
+}
+
+// attach subscribes to the event bus of `opts`, creating the bus if needed
+func (p *cliProgress) attach(opts *Options) {
+	if opts.Events == nil {
+		opts.Events = NewBus()
+	}
+	opts.Events.Handle(p.handle)
+}
+
+// handle renders an event
+func (p *cliProgress) handle(e Event) {
+	switch e := e.(type) {
+	case PhaseStarted:
+		p.onPhaseStart(e.Phase)
+	case ThroughputSample:
+		p.onSample(e)
+	case PhaseComplete:
+		p.onPhaseDone(e)
+	}
+}
+
+func (p *cliProgress) onPhaseStart(phase Phase) {
+	if p.ui.silent {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.last = ThroughputSample{Phase: phase}
+	p.rates = nil
+	// spinners are written to stderr, so only the results are written to stdout
+	p.pb = spinner.New(spinner.CharSets[11], 100*time.Millisecond, spinner.WithWriterFile(os.Stderr))
+	switch phase {
+	case PhasePing:
+		p.pb.Prefix = i18n.T("Pinging...") + "  "
+	case PhaseDownload:
+		p.pb.Prefix = i18n.T("Downloading...") + "  "
+	case PhaseUpload:
+		p.pb.Prefix = i18n.T("Uploading...") + "  "
+	case PhaseLoss:
+		p.pb.Prefix = i18n.T("Probing...") + "  "
+	}
+	if phase == PhaseDownload || phase == PhaseUpload {
+		p.pb.PostUpdate = func(s *spinner.Spinner) {
+			p.lock.Lock()
+			defer p.lock.Unlock()
+			if len(p.rates) == 0 {
+				s.Suffix = fmt.Sprintf("  %s", p.formatRate(p.last.Rate))
+				return
+			}
+			s.Suffix = fmt.Sprintf(i18n.T("  %s  current %s, average %s  %ds elapsed, %ds left"), sparkline(p.rates),
+				p.formatRate(p.rates[len(p.rates)-1]), p.formatRate(p.last.Rate),
+				int(p.last.Elapsed.Seconds()), int(math.Ceil(p.last.Remaining.Seconds())))
+		}
+	}
+	p.pb.Start()
+}
+
+func (p *cliProgress) onSample(sample ThroughputSample) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	// the current rate is the throughput since the last sample
+	if dt := sample.Elapsed - p.last.Elapsed; sample.Phase == p.last.Phase && dt > 0 && sample.Bytes >= p.last.Bytes {
+		p.rates = append(p.rates, float64(sample.Bytes-p.last.Bytes)*8/1000000/dt.Seconds())
+		if len(p.rates) > sparklineSamples {
+			p.rates = p.rates[len(p.rates)-sparklineSamples:]
+		}
+	}
+	p.last = sample
+}
+
+// sparkline returns the rates as bars scaled to the highest rate
+func sparkline(rates []float64) string {
+	var peak float64
+	for _, r := range rates {
+		peak = max(peak, r)
+	}
+	bars := make([]rune, len(rates))
+	for i, r := range rates {
+		idx := 0
+		if peak > 0 {
+			idx = int(r / peak * float64(len(sparkBars)-1))
+		}
+		bars[i] = sparkBars[idx]
+	}
+	return string(bars)
+}
+
+func (p *cliProgress) onPhaseDone(res PhaseComplete) {
+	var msg string
+	switch res.Phase {
+	case PhasePing:
+		ping := gradeLow(i18n.Number(res.Ping, 2)+" ms", res.Ping, goodLatency, fairLatency)
+		msg = fmt.Sprintf(i18n.T("Latency:\t%s (%s jitter)\n"), ping, colorDim(i18n.Number(res.Jitter, 2)+" ms"))
+	case PhaseDownload:
+		rate := gradeHigh(p.formatRate(res.Rate), res.Rate, goodDownload, fairDownload)
+		msg = fmt.Sprintf(i18n.T("Download:\t%s (data used: %s)\n"), rate, colorDim(p.formatBytes(res.Bytes)))
+	case PhaseUpload:
+		rate := gradeHigh(p.formatRate(res.Rate), res.Rate, goodUpload, fairUpload)
+		msg = fmt.Sprintf(i18n.T("Upload:\t\t%s (data used: %s)\n"), rate, colorDim(p.formatBytes(res.Bytes)))
+	case PhaseLoss:
+		if res.Loss < 0 {
+			msg = i18n.T("Packet loss:\tunavailable\n")
+		} else {
+			loss := gradeLow(i18n.Number(res.Loss, 2)+"%", res.Loss, goodLoss, fairLoss)
+			msg = fmt.Sprintf(i18n.T("Packet loss:\t%s\n"), loss)
+		}
+	}
+
+	p.lock.Lock()
+	pb := p.pb
+	p.pb = nil
+	p.lock.Unlock()
+
+	if pb != nil {
+		pb.Stop()
+	}
+	if !p.ui.silent || p.ui.simple {
+		fmt.Print(msg)
+	}
+}
+
+// stop stops the running spinner if a phase failed
+func (p *cliProgress) stop() {
+	p.lock.Lock()
+	pb := p.pb
+	p.pb = nil
+	p.lock.Unlock()
+
+	if pb != nil {
+		pb.Stop()
+	}
+}
+
+// formatRate returns the rate in the unit given by --unit, or scaled to its magnitude in bits per second, or in bytes
+// per second with --bytes, in IEC units with --mebibytes
+func (p *cliProgress) formatRate(mbps float64) string {
+	if p.ui.unit != nil {
+		return p.ui.unit.format(mbps)
+	}
+	return scaleUnit(mbps, rateUnits(p.ui.useBytes, p.ui.useMebi)).format(mbps)
+}
+
+// brief returns the result in one line of the form `srv=NAME ping=8ms jitter=1ms down=942.1Mbps up=87.3Mbps`, the
+// results of skipped tests are left out
+func (p *cliProgress) brief(rep report.Result) string {
+	fields := []string{
+		fmt.Sprintf("srv=%s", strings.ReplaceAll(i18n.Name(rep.Name), " ", "_")),
+		fmt.Sprintf("ping=%.0fms", rep.Ping),
+		fmt.Sprintf("jitter=%.0fms", rep.Jitter),
+	}
+	if rep.Loss != nil {
+		fields = append(fields, fmt.Sprintf("loss=%.1f%%", *rep.Loss))
+	}
+	if rep.BytesReceived > 0 {
+		fields = append(fields, fmt.Sprintf("down=%s", p.compactRate(rep.Download)))
+	}
+	if rep.BytesSent > 0 {
+		fields = append(fields, fmt.Sprintf("up=%s", p.compactRate(rep.Upload)))
+	}
+	return strings.Join(fields, " ")
+}
+
+// compactRate returns the rate like formatRate with one decimal and without a space before the unit
+func (p *cliProgress) compactRate(mbps float64) string {
+	unit := p.ui.unit
+	if unit == nil {
+		u := scaleUnit(mbps, rateUnits(p.ui.useBytes, p.ui.useMebi))
+		unit = &u
+	}
+	return fmt.Sprintf("%.1f%s", mbps/unit.mbps, unit.name)
+}
+
+// formatBytes returns the amount of data used scaled to its magnitude, in IEC units with --mebibytes or an IEC --unit
+func (p *cliProgress) formatBytes(bytes uint64) string {
+	units := sizeUnits
+	if p.ui.useMebi {
+		units = sizeIECUnits
+	}
+	return scaleUnit(float64(bytes), units).format(float64(bytes))
+}
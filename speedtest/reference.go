@@ -0,0 +1,144 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+)
+
+// the file caching the full server list in defs.DataDir, written by --list and the reference commands
+const serversFile = "servers.json"
+
+// serverCache is the cached full server list
+type serverCache struct {
+	Fetched time.Time     `json:"fetched"`
+	Servers []defs.Server `json:"servers"`
+}
+
+// serversPath returns the path of the cached server list
+func serversPath() (string, error) {
+	dir, err := defs.DataDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, serversFile), nil
+}
+
+// saveServers caches the full server list
+func saveServers(servers []defs.Server) error {
+	path, err := serversPath()
+	if err != nil {
+		return err
+	}
+	b, err := json.Marshal(serverCache{Fetched: time.Now(), Servers: servers})
+	if err != nil {
+		return err
+	}
+	return writeFile(path, b)
+}
+
+// cachedServers returns the cached full server list, which is fetched and cached first if not cached yet or with
+// --refresh
+func cachedServers(c *cli.Context) (*serverCache, error) {
+	path, err := serversPath()
+	if err != nil {
+		return nil, err
+	}
+	if !c.Bool(defs.OptionRefresh) {
+		b, err := os.ReadFile(path)
+		if err == nil {
+			var cache serverCache
+			perr := json.Unmarshal(b, &cache)
+			if perr == nil {
+				return &cache, nil
+			}
+			log.Debugf("Failed to parse the cached server list: %s", perr)
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return nil, err
+		}
+	}
+
+	opts := DefaultOptions()
+	opts.Transport = http.DefaultTransport
+	opts.APIBase, opts.APIVersion = c.String(defs.OptionAPIBase), c.String(defs.OptionAPIVersion)
+	log.Info(i18n.T("Retrieving server list"))
+	servers, err := Discover(c.Context, Filters{Options: &opts})
+	if err != nil {
+		log.Errorf(i18n.T("Error when fetching server list: %s"), err)
+		return nil, err
+	}
+	if err := saveServers(servers); err != nil {
+		log.Debugf("Failed to cache the server list: %s", err)
+	}
+	return &serverCache{Fetched: time.Now(), Servers: servers}, nil
+}
+
+// Provinces prints the codes and names of the provinces accepted by --group, with the number of servers in each
+func Provinces(c *cli.Context) error {
+	if err := setLang(c); err != nil {
+		return err
+	}
+	// the cached metadata bundle may have updated the provinces
+	loadMetadata(c.Context, http.DefaultClient, metadataSource{})
+	cache, err := cachedServers(c)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[uint8]int)
+	for _, s := range cache.Servers {
+		counts[s.Prov]++
+	}
+	fmt.Printf(i18n.T("Servers as of %s\n"), cache.Fetched.Local().Format(time.DateTime))
+	fmt.Println(i18n.T("Code\tName\t\tServers"))
+	for _, p := range loadProvinces() {
+		if p.Code == "" {
+			continue
+		}
+		fmt.Printf("%s\t%s\t\t%d\n", p.Code, i18n.Name(p.Short), counts[p.ID])
+	}
+	return nil
+}
+
+// ISPs prints the short names and ASNs of the ISPs accepted by --group, with the number of servers of each
+func ISPs(c *cli.Context) error {
+	if err := setLang(c); err != nil {
+		return err
+	}
+	// the cached metadata bundle may have updated the ISPs
+	loadMetadata(c.Context, http.DefaultClient, metadataSource{})
+	cache, err := cachedServers(c)
+	if err != nil {
+		return err
+	}
+
+	counts := make(map[uint8]int)
+	for _, s := range cache.Servers {
+		counts[s.ISP]++
+	}
+	var isps []*defs.ISPInfo
+	for _, isp := range defs.ISPMap {
+		if isp.Short != "" {
+			isps = append(isps, isp)
+		}
+	}
+	sort.Slice(isps, func(i, j int) bool { return isps[i].ID < isps[j].ID })
+
+	fmt.Printf(i18n.T("Servers as of %s\n"), cache.Fetched.Local().Format(time.DateTime))
+	fmt.Println(i18n.T("Code\tASN\tName\t\tServers"))
+	for _, isp := range isps {
+		fmt.Printf("%s\t%d\t%s\t\t%d\n", isp.Short, isp.ASN, i18n.Name(isp.Name), counts[isp.ID])
+	}
+	return nil
+}
@@ -0,0 +1,336 @@
+package speedtest
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+// the error codes of JSON-RPC 2.0
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcServerError    = -32000
+)
+
+// the longest request line accepted by --stdio-rpc
+const rpcMaxLine = 1 << 20
+
+// errTestRunning is returned by startTest while another test is running, as concurrent tests distort each other
+var errTestRunning = errors.New("a test is already running")
+
+// rpcRequest is a JSON-RPC request, or a notification if ID is empty
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+}
+
+// rpcMessage is a JSON-RPC response to a request, or a notification sent by the engine if Method is set
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  interface{}     `json:"params,omitempty"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+// rpcError is the error of a JSON-RPC response
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+// rpcFilters are the params of listServers, like Filters
+type rpcFilters struct {
+	IDs       []string          `json:"ids"`
+	Provinces []string          `json:"provinces"`
+	ISPs      []string          `json:"isps"`
+	Groups    []string          `json:"groups"`
+	Types     []defs.ServerType `json:"types"`
+}
+
+// rpcTestParams are the params of startTest overriding the options given on the command line, durations are in
+// seconds. The fastest server nearby is tested against if Server is empty
+type rpcTestParams struct {
+	Server     string  `json:"server"`
+	Duration   float64 `json:"duration"`
+	Concurrent int     `json:"concurrent"`
+	PingCount  int     `json:"pingCount"`
+	NoDownload bool    `json:"noDownload"`
+	NoUpload   bool    `json:"noUpload"`
+}
+
+// rpcTest identifies a test in the result of startTest, the params of cancel and all notifications of the test
+type rpcTest struct {
+	Test int `json:"test"`
+}
+
+// rpcProgress is the params of the progress notification, one for every event of a test
+type rpcProgress struct {
+	Test int `json:"test"`
+	progressLine
+}
+
+// rpcResult is the params of the result notification sent when a test is finished, Error is set if it failed
+type rpcResult struct {
+	Test   int            `json:"test"`
+	Result *report.Result `json:"result,omitempty"`
+	Error  string         `json:"error,omitempty"`
+}
+
+// rpcSession serves JSON-RPC requests read line by line from stdin, with responses and notifications written to
+// stdout line by line
+type rpcSession struct {
+	c    *cli.Context
+	opts *Options
+
+	writeLock sync.Mutex
+	enc       *json.Encoder
+
+	lock sync.Mutex
+	// tests is the number of the last test started, cancel and done belong to the running test if not nil
+	tests  int
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// stdioRPC drives the engine by JSON-RPC 2.0 over stdio for GUIs running it as a child process, a request is read from
+// every line of stdin and responses are written to stdout line by line. The methods are
+//
+//	listServers {ids, provinces, isps, groups, types} -> the servers found by Discover
+//	startTest {server, duration, concurrent, pingCount, noDownload, noUpload} -> {test}
+//	cancel {test} -> {canceled}
+//
+// startTest returns at once, then a `progress` notification is sent for every event of the test like with
+// --json-progress, and a `result` notification when it's finished. The options of startTest override the ones on the
+// command line. A `ready` notification is sent once started, and the running test is canceled when stdin is closed
+func stdioRPC(c *cli.Context, opts *Options) error {
+	s := &rpcSession{c: c, opts: opts, enc: json.NewEncoder(os.Stdout)}
+	s.notify("ready", map[string]string{"version": defs.ProgVersion})
+
+	lines := make(chan []byte)
+	errs := make(chan error, 1)
+	go func() {
+		scanner := bufio.NewScanner(os.Stdin)
+		scanner.Buffer(make([]byte, 64*1024), rpcMaxLine)
+		for scanner.Scan() {
+			lines <- append([]byte(nil), scanner.Bytes()...)
+		}
+		errs <- scanner.Err()
+	}()
+
+	var err error
+loop:
+	for {
+		select {
+		case line := <-lines:
+			s.handle(line)
+		case err = <-errs:
+			break loop
+		case <-c.Context.Done():
+			break loop
+		}
+	}
+
+	s.lock.Lock()
+	cancel, done := s.cancel, s.done
+	s.lock.Unlock()
+	if cancel != nil {
+		cancel()
+		<-done
+	}
+	if err != nil && !errors.Is(err, io.EOF) {
+		log.Debugf("Failed to read requests: %s", err)
+		return err
+	}
+	return nil
+}
+
+// handle serves a request line, notifications get no response
+func (s *rpcSession) handle(line []byte) {
+	var req rpcRequest
+	if err := json.Unmarshal(line, &req); err != nil {
+		s.reply(json.RawMessage("null"), nil, &rpcError{Code: rpcParseError, Message: err.Error()})
+		return
+	}
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		id := req.ID
+		if len(id) == 0 {
+			id = json.RawMessage("null")
+		}
+		s.reply(id, nil, &rpcError{Code: rpcInvalidRequest, Message: "invalid request"})
+		return
+	}
+
+	result, rerr := s.call(req.Method, req.Params)
+	if len(req.ID) > 0 {
+		s.reply(req.ID, result, rerr)
+	}
+}
+
+// call runs a method and returns its result
+func (s *rpcSession) call(method string, params json.RawMessage) (interface{}, *rpcError) {
+	switch method {
+	case "listServers":
+		var f rpcFilters
+		if err := decodeParams(params, &f); err != nil {
+			return nil, err
+		}
+		servers, err := Discover(s.c.Context, Filters{
+			IDs:       serverAliases(f.IDs),
+			Provinces: f.Provinces,
+			ISPs:      f.ISPs,
+			Groups:    f.Groups,
+			Types:     f.Types,
+			Options:   s.opts,
+		})
+		if err != nil {
+			return nil, &rpcError{Code: rpcServerError, Message: err.Error()}
+		}
+		if servers == nil {
+			servers = []defs.Server{}
+		}
+		return servers, nil
+	case "startTest":
+		var p rpcTestParams
+		if err := decodeParams(params, &p); err != nil {
+			return nil, err
+		}
+		test, err := s.start(p)
+		if err != nil {
+			return nil, &rpcError{Code: rpcServerError, Message: err.Error()}
+		}
+		return rpcTest{Test: test}, nil
+	case "cancel":
+		var t rpcTest
+		if err := decodeParams(params, &t); err != nil {
+			return nil, err
+		}
+		return map[string]bool{"canceled": s.stop(t.Test)}, nil
+	default:
+		return nil, &rpcError{Code: rpcMethodNotFound, Message: "method not found: " + method}
+	}
+}
+
+// start starts a test in the background and returns its number
+func (s *rpcSession) start(p rpcTestParams) (int, error) {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.cancel != nil {
+		return 0, errTestRunning
+	}
+	s.tests++
+	test := s.tests
+
+	opts := *s.opts
+	if p.Duration > 0 {
+		opts.Duration = time.Duration(p.Duration * float64(time.Second))
+	}
+	if p.Concurrent > 0 {
+		opts.Concurrent = p.Concurrent
+	}
+	if p.PingCount > 0 {
+		opts.PingCount = p.PingCount
+	}
+	opts.NoDownload = opts.NoDownload || p.NoDownload
+	opts.NoUpload = opts.NoUpload || p.NoUpload
+	opts.Events = NewBus()
+	opts.Events.Handle(func(e Event) {
+		s.notify("progress", rpcProgress{Test: test, progressLine: newProgressLine(e)})
+	})
+
+	ctx, cancel := context.WithCancel(s.c.Context)
+	done := make(chan struct{})
+	s.cancel, s.done = cancel, done
+	go func() {
+		defer close(done)
+		defer cancel()
+		rep, err := s.run(ctx, p.Server, opts)
+
+		s.lock.Lock()
+		s.cancel, s.done = nil, nil
+		s.lock.Unlock()
+		if err != nil {
+			s.notify("result", rpcResult{Test: test, Error: err.Error()})
+			return
+		}
+		s.notify("result", rpcResult{Test: test, Result: &rep})
+	}()
+	return test, nil
+}
+
+// run runs a test against `server` given by ID, HOST:PORT or alias, or the fastest server nearby if empty, and
+// records the result in the history
+func (s *rpcSession) run(ctx context.Context, server string, opts Options) (report.Result, error) {
+	if server != "" {
+		target, err := lookupServer(ctx, directServerType(s.c), &opts, server)
+		if err != nil {
+			return report.Result{}, err
+		}
+		opts.Server = &target
+	}
+	rep, err := RunTest(ctx, opts)
+	if err == nil && !s.c.Bool(defs.OptionNoHistory) {
+		if err := recordHistory([]report.Result{rep}, nil); err != nil {
+			log.Debugf("Failed to record the history: %s", err)
+		}
+	}
+	return rep, err
+}
+
+// stop cancels the running test if it's `test`, or any running test if `test` is 0
+func (s *rpcSession) stop(test int) bool {
+	s.lock.Lock()
+	defer s.lock.Unlock()
+	if s.cancel == nil || (test != 0 && test != s.tests) {
+		return false
+	}
+	s.cancel()
+	return true
+}
+
+// reply writes the response to the request `id`
+func (s *rpcSession) reply(id json.RawMessage, result interface{}, err *rpcError) {
+	s.write(rpcMessage{JSONRPC: "2.0", ID: id, Result: result, Error: err})
+}
+
+// notify writes a notification
+func (s *rpcSession) notify(method string, params interface{}) {
+	s.write(rpcMessage{JSONRPC: "2.0", Method: method, Params: params})
+}
+
+// write writes a message as a line of JSON
+func (s *rpcSession) write(m rpcMessage) {
+	s.writeLock.Lock()
+	defer s.writeLock.Unlock()
+	if err := s.enc.Encode(m); err != nil {
+		log.Debugf("Failed to write a response: %s", err)
+	}
+}
+
+// decodeParams decodes the params of a request into `v`, params may be left out
+func decodeParams(params json.RawMessage, v interface{}) *rpcError {
+	if len(params) == 0 || string(params) == "null" {
+		return nil
+	}
+	if err := json.Unmarshal(params, v); err != nil {
+		return &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+	}
+	return nil
+}
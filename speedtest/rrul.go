@@ -0,0 +1,164 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+// the number of flows in each direction of RRUL tests unless --concurrent is given, as in the reference RRUL test
+const rrulFlows = 4
+
+// bloatGrades are the upper bounds of the induced latency in ms of each bufferbloat grade, higher latencies are
+// graded F
+var bloatGrades = []struct {
+	grade string
+	limit float64
+}{
+	{"A+", 5},
+	{"A", 30},
+	{"B", 60},
+	{"C", 200},
+	{"D", 400},
+}
+
+// rrulMode runs a Realtime Response Under Load test against the server given by --server or the fastest server
+// nearby: after measuring the idle latency, download and upload flows run simultaneously for --duration while the
+// latency is probed, and the latency induced by the load is graded
+func rrulMode(c *cli.Context, opts *Options, ui *uiOptions) error {
+	if opts.NoDownload || opts.NoUpload {
+		log.Errorf(i18n.T("--%s needs both download and upload tests"), defs.OptionRRUL)
+		return errors.New("invalid RRUL setting")
+	}
+	if !c.IsSet(defs.OptionConcurrent) {
+		opts.Concurrent = rrulFlows
+	}
+
+	servers, err := givenOrFastestServers(c, opts)
+	if err != nil {
+		return err
+	}
+	server := servers[0]
+	if up, _ := checkServers(c.Context, servers[:1], opts, 1); !up[0] {
+		log.Errorf(i18n.T("Selected server %s (%s) is not responding at the moment, try again later"), server.Name, server.ID)
+		return ErrServerDown
+	}
+	opts.target(&server)
+
+	human := !c.Bool(defs.OptionCSV) && !c.Bool(defs.OptionJSON)
+	if human {
+		printServer(server)
+	}
+
+	pinger := server
+	idle := idleLatency(c.Context, &pinger, opts)
+
+	token := ""
+	if server.Type == defs.GlobalSpeed {
+		token = enQueue(c.Context, server)
+		if len(token) <= 0 || token == "-" {
+			if err := c.Context.Err(); err != nil {
+				return err
+			}
+			return ErrToken
+		}
+		defer deQueue(context.WithoutCancel(c.Context), server, token)
+	}
+
+	log.Infof(i18n.T("Running %d download and %d upload flows for %s"), opts.Concurrent, opts.Concurrent, opts.Duration)
+	var download, upload float64
+	var downloadErr, uploadErr error
+	loaded, _ := loadedLatency(c.Context, &pinger, opts, func(ctx context.Context) error {
+		var wg sync.WaitGroup
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			download, _, downloadErr = server.Download(ctx, opts.Concurrent, opts.Duration, token, nil)
+		}()
+		go func() {
+			defer wg.Done()
+			upload, _, uploadErr = server.Upload(ctx, opts.NoPreAllocate, opts.Concurrent, opts.UploadSize, opts.Duration, token, nil)
+		}()
+		wg.Wait()
+		return nil
+	})
+	if c.Context.Err() != nil {
+		return nil
+	}
+	if downloadErr != nil {
+		log.Errorf(i18n.T("Failed to get download speed: %s"), downloadErr)
+		return downloadErr
+	}
+	if uploadErr != nil {
+		log.Errorf(i18n.T("Failed to get upload speed: %s"), uploadErr)
+		return uploadErr
+	}
+
+	res := report.RRUL{
+		ID:        server.ID,
+		Name:      server.Name,
+		IP:        server.Host,
+		Timestamp: time.Now(),
+		Flows:     opts.Concurrent,
+		Download:  download,
+		Upload:    upload,
+		Total:     download + upload,
+		IdlePing:  idle.Median,
+		Ping:      loaded.Median,
+		P95:       loaded.P95,
+		Max:       loaded.Max,
+		Jitter:    loaded.StdDev,
+		Loss:      loaded.Loss,
+		Increase:  max(loaded.Median-idle.Median, 0),
+	}
+	res.Round()
+	res.Grade = bloatGrade(res.Increase)
+
+	switch {
+	case c.Bool(defs.OptionCSV):
+		if b, err := report.MarshalRRULCSV([]report.RRUL{res}, []rune(c.String(defs.OptionCSVDelimiter))[0], true); err != nil {
+			log.Errorf(i18n.T("Error generating CSV report: %s"), err)
+		} else {
+			os.Stdout.Write(b)
+		}
+	case c.Bool(defs.OptionJSON):
+		if b, err := json.Marshal(res); err != nil {
+			log.Errorf(i18n.T("Error generating JSON report: %s"), err)
+		} else {
+			os.Stdout.Write(append(b, '\n'))
+		}
+	default:
+		progress := newProgress(ui)
+		fmt.Printf(i18n.T("Idle latency:\t%s ms\n"), i18n.Number(res.IdlePing, 2))
+		fmt.Printf(i18n.T("Download:\t%s (%d flows)\n"), gradeHigh(progress.formatRate(res.Download), res.Download, goodDownload, fairDownload), res.Flows)
+		fmt.Printf(i18n.T("Upload:\t\t%s (%d flows)\n"), gradeHigh(progress.formatRate(res.Upload), res.Upload, goodUpload, fairUpload), res.Flows)
+		fmt.Printf(i18n.T("Total:\t\t%s\n"), progress.formatRate(res.Total))
+		fmt.Printf(i18n.T("Loaded latency:\t%s ms (95th percentile %s ms, max %s ms, jitter %s ms)\n"), i18n.Number(res.Ping, 2),
+			i18n.Number(res.P95, 2), i18n.Number(res.Max, 2), i18n.Number(res.Jitter, 2))
+		fmt.Printf(i18n.T("Packet loss:\t%s\n"), gradeLow(i18n.Number(res.Loss, 2)+"%", res.Loss, goodLoss, fairLoss))
+		fmt.Printf(i18n.T("Bufferbloat:\t%s (+%s ms under load)\n"), gradeLow(res.Grade, res.Increase, bloatGrades[1].limit, bloatGrades[3].limit),
+			i18n.Number(res.Increase, 2))
+	}
+	return nil
+}
+
+// bloatGrade returns the bufferbloat grade of the latency induced by load
+func bloatGrade(increase float64) string {
+	for _, g := range bloatGrades {
+		if increase < g.limit {
+			return g.grade
+		}
+	}
+	return "F"
+}
@@ -0,0 +1,79 @@
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+)
+
+// schedule starts a round every interval for the periodic modes, each round is delayed by a random offset within
+// jitter so that many clients started at the same time don't test at the same time
+type schedule struct {
+	interval time.Duration
+	jitter   time.Duration
+
+	// slot is the start of the next round before the jitter, due the start with the jitter once picked. timer fires
+	// at due, it's nil until the start of the next round is picked
+	slot  time.Time
+	due   time.Time
+	timer *time.Timer
+}
+
+// newSchedule returns a schedule with the first round due now, or within `jitter` from now
+func newSchedule(interval, jitter time.Duration) *schedule {
+	return &schedule{interval: interval, jitter: jitter, slot: time.Now()}
+}
+
+// periodicSchedule returns the schedule given by --interval and --jitter
+func periodicSchedule(c *cli.Context) (*schedule, error) {
+	interval := time.Duration(c.Int(defs.OptionInterval)) * time.Second
+	if interval <= 0 {
+		log.Errorf(i18n.T("Interval must be at least 1 second: %d is given"), c.Int(defs.OptionInterval))
+		return nil, errors.New("invalid interval setting")
+	}
+	jitter := time.Duration(c.Int(defs.OptionJitter)) * time.Second
+	if jitter < 0 || jitter > interval {
+		log.Errorf(i18n.T("Jitter must be between 0 and the interval of %d seconds: %d is given"), c.Int(defs.OptionInterval), c.Int(defs.OptionJitter))
+		return nil, errors.New("invalid jitter setting")
+	}
+	return newSchedule(interval, jitter), nil
+}
+
+// next returns the start of the next round. Rounds missed by a long running round are skipped, the next one starts
+// right away
+func (s *schedule) next() time.Time {
+	if s.timer == nil {
+		if now := time.Now(); s.slot.Before(now) {
+			s.slot = now
+		}
+		s.due = s.slot
+		if s.jitter > 0 {
+			s.due = s.due.Add(time.Duration(rand.Int63n(int64(s.jitter))))
+		}
+		s.slot = s.slot.Add(s.interval)
+		s.timer = time.NewTimer(time.Until(s.due))
+	}
+	return s.due
+}
+
+// wait blocks until the next round starts, it returns false if `ctx` is done meanwhile
+func (s *schedule) wait(ctx context.Context) bool {
+	s.next()
+	timer := s.timer
+	defer timer.Stop()
+	s.timer = nil
+
+	select {
+	case <-ctx.Done():
+		return false
+	case <-timer.C:
+		return true
+	}
+}
@@ -0,0 +1,159 @@
+package speedtest
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/mockserver"
+)
+
+// Serve hosts a test server with the endpoints of GlobalSpeed servers, or of the server type given by --type, which can
+// be tested against with `--server HOST:PORT`, or with `--peer HOST:PORT` which also measures packet loss
+func Serve(c *cli.Context) error {
+	if err := setLang(c); err != nil {
+		return err
+	}
+	if c.Bool(defs.OptionDebug) {
+		log.SetLevel(log.DebugLevel)
+	}
+
+	port := c.Int(defs.OptionPort)
+	if port <= 0 || port > 65535 {
+		log.Errorf(i18n.T("Port must be between 1 and 65535: %d is given"), port)
+		return errors.New("invalid port setting")
+	}
+	name := c.String(defs.OptionType)
+	serverType, ok := defs.ServerTypeNames[name]
+	if !ok {
+		log.Errorf(i18n.T("Unknown server type: %s is given"), name)
+		return errors.New("invalid server type setting")
+	}
+	return listenAndServe(c.Context, net.JoinHostPort(c.String(defs.OptionBind), strconv.Itoa(port)), serverType)
+}
+
+// listenAndServe hosts a test server on `addr` until `ctx` is done, with the endpoints of `serverType` servers over TCP
+// and an echo for the UDP probes of --peer
+func listenAndServe(ctx context.Context, addr string, serverType defs.ServerType) error {
+	handler := mockserver.NewHandler(mockserver.Config{Type: serverType})
+	srv := &http.Server{
+		Addr: addr,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			log.Debugf("%s %s %s", r.RemoteAddr, r.Method, r.URL.Path)
+			handler.ServeHTTP(w, r)
+		}),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		log.Errorf(i18n.T("Failed to listen on %s: %s"), addr, err)
+		return err
+	}
+	pc, err := net.ListenPacket("udp", ln.Addr().String())
+	if err != nil {
+		ln.Close()
+		log.Errorf(i18n.T("Failed to listen on %s: %s"), addr, err)
+		return err
+	}
+	defer pc.Close()
+	go serveEcho(pc)
+
+	port := ln.Addr().(*net.TCPAddr).Port
+	if serverType == defs.GlobalSpeed {
+		log.Infof(i18n.T("Serving speed test on %s, test against it with --%s HOST:%d"), ln.Addr(), defs.OptionPeer, port)
+	} else {
+		log.Infof(i18n.T("Serving speed test on %s, test against it with --%s HOST:%d --%s %s"), ln.Addr(), defs.OptionServer, port, defs.OptionServerType, typeName(serverType))
+	}
+
+	// shut down on interrupt, open transfers are cut off after a short grace period
+	go func() {
+		<-ctx.Done()
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		srv.Shutdown(ctx)
+	}()
+
+	if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Errorf(i18n.T("Failed to serve: %s"), err)
+		return err
+	}
+
+	stats := handler.Stats()
+	log.Infof(i18n.T("Served %d downloads (%.2f MB) and %d uploads (%.2f MB)"), stats.Downloads, float64(stats.BytesSent)/1000000, stats.Uploads, float64(stats.BytesReceived)/1000000)
+	return nil
+}
+
+// selfTest runs the test against a built-in server on the loopback interface, to tell whether results are limited by
+// the network or by this device
+func selfTest(c *cli.Context, opts *Options, ui *uiOptions) error {
+	m, err := mockserver.Start(mockserver.Config{Type: defs.GlobalSpeed})
+	if err != nil {
+		log.Errorf(i18n.T("Failed to start the built-in server: %s"), err)
+		return err
+	}
+	defer m.Close()
+
+	// the test traffic stays on the loopback interface, regardless of the proxy or interface given
+	if t, ok := opts.Transport.(*http.Transport); ok {
+		t = t.Clone()
+		t.Proxy = nil
+		t.DialContext = nil
+		opts.Transport = t
+	}
+	opts.NoICMP = true
+	opts.Network = "ip4"
+
+	server := m.Server()
+	server.ID = "selftest"
+	server.Name = "Self test"
+
+	if err := doSpeedTest(c, []defs.Server{server}, opts, ui, nil); err != nil {
+		return err
+	}
+	log.Info(i18n.T("These are the maximum rates this device can sustain, results close to them are limited by this device rather than the network"))
+	return nil
+}
+
+// typeName returns the name of a server type emulated by `serve`
+func typeName(serverType defs.ServerType) string {
+	for name, t := range defs.ServerTypeNames {
+		if t == serverType {
+			return name
+		}
+	}
+	return ""
+}
+
+// directServerType returns the server type given by --server-type for servers given by HOST:PORT
+func directServerType(c *cli.Context) defs.ServerType {
+	return defs.ServerTypeNames[c.String(defs.OptionServerType)]
+}
+
+// directServer returns the server of `serverType` hosted by `serve` at `addr` in the form of HOST:PORT
+func directServer(addr string, serverType defs.ServerType) (defs.Server, bool) {
+	host, p, err := net.SplitHostPort(addr)
+	if err != nil || host == "" {
+		return defs.Server{}, false
+	}
+	port, err := strconv.ParseUint(p, 10, 16)
+	if err != nil {
+		return defs.Server{}, false
+	}
+
+	server := defs.Server{ID: addr, Name: addr, Host: host, Port: uint16(port), Type: serverType}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		server.IPv6 = host
+	} else {
+		server.IP = host
+	}
+	return server, true
+}
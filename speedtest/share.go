@@ -0,0 +1,92 @@
+package speedtest
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+// the limit of the response of share endpoints, which is only a URL
+const maxShareResponse = 64 * 1024
+
+// shareEndpoint returns the endpoint given by --share-url, or the share service of the core API
+func shareEndpoint(endpoint string, opts *Options) (string, error) {
+	if endpoint != "" {
+		return endpoint, nil
+	}
+	u, err := url.Parse(opts.APIBase)
+	if err != nil {
+		return "", err
+	}
+	return u.JoinPath(opts.APIVersion, "share").String(), nil
+}
+
+// shareReport posts the JSON report to `endpoint` and returns the URL it's shared at. The endpoint responds with the
+// URL either in plain text, as paste services do, or in the `url` field of a JSON object
+func shareReport(ctx context.Context, client *http.Client, endpoint string, rep *report.JSONReport) (string, error) {
+	b, err := rep.Marshal()
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(b))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", defs.ApiUA)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= http.StatusBadRequest {
+		return "", errors.New(resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxShareResponse))
+	if err != nil {
+		return "", err
+	}
+
+	var link string
+	if body = bytes.TrimSpace(body); bytes.HasPrefix(body, []byte("{")) {
+		var res struct {
+			URL string `json:"url"`
+		}
+		if err := json.Unmarshal(body, &res); err != nil {
+			return "", err
+		}
+		link = res.URL
+	} else {
+		link, _, _ = strings.Cut(string(body), "\n")
+		link = strings.TrimSpace(link)
+	}
+	if link == "" {
+		// some services respond with the location of the paste only
+		link = resp.Header.Get("Location")
+	}
+
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("unexpected response %q", truncate(link, 80))
+	}
+	return link, nil
+}
+
+// truncate shortens `s` to at most `n` bytes of its first line
+func truncate(s string, n int) string {
+	s, _, _ = strings.Cut(s, "\n")
+	if len(s) > n {
+		return s[:n] + "..."
+	}
+	return s
+}
@@ -2,55 +2,81 @@ package speedtest
 
 import (
 	"context"
+	"crypto/sha256"
 	"crypto/tls"
 	_ "embed"
+	"encoding/hex"
 	"errors"
 	"fmt"
 	"math"
-	"math/rand"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
+	"path/filepath"
+	"regexp"
+	"runtime/debug"
 	"strconv"
 	"strings"
-	"sync"
 	"time"
 
-	"github.com/gocarina/gocsv"
+	"github.com/fatih/color"
 	log "github.com/sirupsen/logrus"
 	"github.com/urfave/cli/v2"
 
 	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
 	"github.com/ztelliot/taierspeed-cli/report"
 )
 
+const (
+	// limits applied by --low-memory
+	lowMemUploadSize = 128
+	lowMemConcurrent = 2
+	lowMemBufferSize = 4 * 1024
+
+	// GOGC applied by --memlimit
+	memLimitGCPercent = 50
+)
+
 //go:embed province.csv
 var ProvinceListByte []byte
 
-type PingJob struct {
-	Index  int
-	Server defs.Server
-}
-
-type PingResult struct {
-	Index int
-	Ping  float64
-}
+// metadataVersionPattern matches the versions of metadata bundles, which are part of the tag and the cache file name
+var metadataVersionPattern = regexp.MustCompile(`^[0-9A-Za-z._-]+$`)
 
 // SpeedTest is the actual main function that handles the speed test(s)
 func SpeedTest(c *cli.Context) error {
+	if err := setLang(c); err != nil {
+		return err
+	}
+
 	// check for suppressed output flags
 	var silent bool
-	if c.Bool(defs.OptionSimple) || c.Bool(defs.OptionJSON) || c.Bool(defs.OptionCSV) {
+	if c.Bool(defs.OptionSimple) || c.Bool(defs.OptionJSON) || c.Bool(defs.OptionCSV) || c.IsSet(defs.OptionFormat) || c.Bool(defs.OptionStdioRPC) {
 		log.SetLevel(log.WarnLevel)
 		silent = true
 	}
+	// only errors are logged with --brief, so that the summary is the only line in the output
+	if c.Bool(defs.OptionBrief) {
+		log.SetLevel(log.ErrorLevel)
+		silent = true
+	}
 
 	// check for debug flag
 	if c.Bool(defs.OptionDebug) {
 		log.SetLevel(log.DebugLevel)
 	}
 
+	if c.IsSet(defs.OptionAndroid) {
+		defs.Android = c.Bool(defs.OptionAndroid)
+	}
+
+	// colors are also disabled by NO_COLOR or if stdout is not a terminal
+	if c.Bool(defs.OptionNoColor) {
+		color.NoColor = true
+	}
+
 	// print help
 	if c.Bool(defs.OptionHelp) {
 		return cli.ShowAppHelp(c)
@@ -59,50 +85,175 @@ func SpeedTest(c *cli.Context) error {
 	// print version
 	if c.Bool(defs.OptionVersion) {
 		log.SetOutput(os.Stdout)
-		log.Warnf("%s %s (built on %s %s)", defs.ProgName, defs.ProgVersion, defs.ProgCommit, defs.BuildDate)
-		log.Warn("Powered by TaierSpeed")
-		log.Warn("Project: https://github.com/ztelliot/taierspeed-cli")
-		log.Warn("Forked: https://github.com/librespeed/speedtest-cli")
+		log.Warnf(i18n.T("%s %s (built on %s %s)"), defs.ProgName, defs.ProgVersion, defs.ProgCommit, defs.BuildDate)
+		log.Warn(i18n.T("Powered by TaierSpeed"))
+		log.Warn(i18n.T("Project: https://github.com/ztelliot/taierspeed-cli"))
+		log.Warn(i18n.T("Forked: https://github.com/librespeed/speedtest-cli"))
 		return nil
 	}
 
 	if c.Bool(defs.OptionCheckUpdate) {
-		if latest, err := getVersion(c); err != nil {
-			log.Errorf("Error when fetching latest version: %s", err)
+		if latest, err := getVersion(c.Context, http.DefaultClient, c.String(defs.OptionAPIBase), c.String(defs.OptionAPIVersion)); err != nil {
+			log.Errorf(i18n.T("Error when fetching latest version: %s"), err)
 		} else {
 			if latest.Version != defs.ProgVersion {
-				log.Warnf("Current version: %s", defs.ProgVersion)
-				log.Warnf("New version available: %s", latest.Version)
-				log.Warnf("Download Url: %s", latest.Url)
+				log.Warnf(i18n.T("Current version: %s"), defs.ProgVersion)
+				log.Warnf(i18n.T("New version available: %s"), latest.Version)
+				log.Warnf(i18n.T("Download Url: %s"), latest.Url)
 			} else {
-				log.Warn("You are using the latest version")
+				log.Warn(i18n.T("You are using the latest version"))
 			}
 		}
 		return nil
 	}
 
+	if addr := c.String(defs.OptionListen); addr != "" {
+		return listenAndServe(c.Context, addr, defs.GlobalSpeed)
+	}
+
 	if c.String(defs.OptionSource) != "" && c.String(defs.OptionInterface) != "" {
 		return fmt.Errorf("incompatible options '%s' and '%s'", defs.OptionSource, defs.OptionInterface)
 	}
+	for _, option := range []string{defs.OptionServer, defs.OptionServerGroup, defs.OptionList} {
+		if c.IsSet(option) && c.Bool(defs.OptionFavorite) {
+			return fmt.Errorf("incompatible options '%s' and '%s'", option, defs.OptionFavorite)
+		}
+	}
+	for _, option := range []string{defs.OptionSource, defs.OptionInterface} {
+		if c.String(option) != "" && c.IsSet(defs.OptionInterfaces) {
+			return fmt.Errorf("incompatible options '%s' and '%s'", option, defs.OptionInterfaces)
+		}
+	}
 
-	// set CSV delimiter
-	gocsv.TagSeparator = c.String(defs.OptionCSVDelimiter)
+	// check CSV delimiter
+	delimiter := []rune(c.String(defs.OptionCSVDelimiter))
+	if len(delimiter) != 1 {
+		log.Errorf(i18n.T("CSV delimiter must be a single character: %q is given"), c.String(defs.OptionCSVDelimiter))
+		return errors.New("invalid CSV delimiter setting")
+	}
+
+	if format := c.String(defs.OptionFormat); format != "" && format != defs.FormatLegacyCSV {
+		log.Errorf(i18n.T("Unknown output format: %s is given"), format)
+		return errors.New("invalid format setting")
+	}
 
 	// if --csv-header is given, print the header and exit (same behavior speedtest-cli)
 	if c.Bool(defs.OptionCSVHeader) {
-		var rep []report.Result
-		b, _ := gocsv.MarshalBytes(&rep)
-		os.Stdout.WriteString(string(b))
+		var b []byte
+		if c.String(defs.OptionFormat) == defs.FormatLegacyCSV {
+			b, _ = report.MarshalLegacyCSV(nil, delimiter[0], true)
+		} else {
+			b, _ = report.MarshalCSV(nil, delimiter[0], true)
+		}
+		os.Stdout.Write(b)
 		return nil
 	}
 
 	if req := c.Int(defs.OptionConcurrent); req <= 0 {
-		log.Errorf("Concurrent requests cannot be lower than 1: %d is given", req)
+		log.Errorf(i18n.T("Concurrent requests cannot be lower than 1: %d is given"), req)
 		return errors.New("invalid concurrent requests setting")
 	}
 
-	// HTTP requests timeout
-	http.DefaultClient.Timeout = time.Duration(c.Int(defs.OptionTimeout)) * time.Second
+	if req := c.Int(defs.OptionSelectionConcurrency); req <= 0 {
+		log.Errorf(i18n.T("Selection concurrency cannot be lower than 1: %d is given"), req)
+		return errors.New("invalid selection concurrency setting")
+	}
+
+	if timeout := c.Int(defs.OptionSelectionTimeout); timeout <= 0 {
+		log.Errorf(i18n.T("Selection timeout must be at least 1 second: %d is given"), timeout)
+		return errors.New("invalid selection timeout setting")
+	}
+
+	if timeout := c.Int(defs.OptionStallTimeout); timeout < 0 {
+		log.Errorf(i18n.T("Stall timeout cannot be negative: %d is given"), timeout)
+		return errors.New("invalid stall timeout setting")
+	}
+
+	if c.Float64(defs.OptionVerifyAnomalies) < 0 {
+		log.Errorf(i18n.T("Anomaly threshold cannot be negative: %s%% is given"), c.String(defs.OptionVerifyAnomalies))
+		return errors.New("invalid verify anomalies setting")
+	}
+	if c.IsSet(defs.OptionVerifyServer) && c.Float64(defs.OptionVerifyAnomalies) == 0 {
+		log.Errorf(i18n.T("--%s needs --%s"), defs.OptionVerifyServer, defs.OptionVerifyAnomalies)
+		return errors.New("invalid verify server setting")
+	}
+
+	// the textfile collector of node_exporter only reads *.prom files
+	if path := c.String(defs.OptionPromTextfile); path != "" && filepath.Ext(path) != ".prom" {
+		log.Errorf(i18n.T("Prometheus textfile must end with .prom: %s is given"), path)
+		return errors.New("invalid prom textfile setting")
+	}
+
+	if c.Float64(defs.OptionUpCheckTimeout) <= 0 {
+		log.Errorf(i18n.T("Up check timeout must be positive: %s seconds is given"), c.String(defs.OptionUpCheckTimeout))
+		return errors.New("invalid up check timeout setting")
+	}
+
+	if name := c.String(defs.OptionServerType); name != "" {
+		if _, ok := defs.ServerTypeNames[name]; !ok {
+			log.Errorf(i18n.T("Unknown server type: %s is given"), name)
+			return errors.New("invalid server type setting")
+		}
+	}
+
+	for _, option := range []string{defs.OptionDownloadPath, defs.OptionUploadPath, defs.OptionPingPath} {
+		if path := c.String(option); path != "" && !strings.HasPrefix(path, "/") {
+			log.Errorf(i18n.T("Path must start with /: %s is given for --%s"), path, option)
+			return errors.New("invalid path setting")
+		}
+	}
+
+	if version := c.String(defs.OptionMetadataVersion); version != "" && !metadataVersionPattern.MatchString(version) {
+		log.Errorf(i18n.T("Invalid metadata version: %s is given"), version)
+		return errors.New("invalid metadata version setting")
+	}
+
+	if checksum := c.String(defs.OptionMetadataSHA256); checksum != "" {
+		if b, err := hex.DecodeString(checksum); err != nil || len(b) != sha256.Size {
+			log.Errorf(i18n.T("Metadata checksum must be a SHA-256 in hex: %s is given"), checksum)
+			return errors.New("invalid metadata checksum setting")
+		}
+	}
+
+	if pingURL := c.String(defs.OptionPingURL); pingURL != "" {
+		if u, err := url.Parse(pingURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Errorf(i18n.T("Ping URL must be an absolute http or https URL: %s is given"), pingURL)
+			return errors.New("invalid ping URL setting")
+		}
+	}
+
+	if limit := c.String(defs.OptionMemLimit); limit != "" {
+		size, err := parseSize(limit)
+		if err != nil || size <= 0 {
+			log.Errorf(i18n.T("Invalid memory limit: %s"), limit)
+			return errors.New("invalid memory limit setting")
+		}
+		debug.SetMemoryLimit(size)
+		// a lower GOGC keeps the heap small on constrained devices, but don't override the user's own setting
+		if os.Getenv("GOGC") == "" {
+			debug.SetGCPercent(memLimitGCPercent)
+		}
+		log.Debugf("Memory limit set to %d bytes", size)
+	}
+
+	uploadSize, concurrent, bufferSize := c.Int(defs.OptionUploadSize), c.Int(defs.OptionConcurrent), 0
+	if c.Bool(defs.OptionLowMemory) {
+		uploadSize = min(uploadSize, lowMemUploadSize)
+		concurrent = min(concurrent, lowMemConcurrent)
+		bufferSize = lowMemBufferSize
+		log.Debugf("Low memory mode: %d KiB upload data, %d concurrent requests", uploadSize, concurrent)
+	}
+	if defs.Android {
+		bufferSize = lowMemBufferSize
+		log.Debug("Android preset: unprivileged ICMP ping, small buffers")
+	}
+
+	if blob := c.String(defs.OptionBlobFile); blob != "" && !c.Bool(defs.OptionNoPreAllocate) {
+		if err := defs.MapBlob(blob, uploadSize); err != nil {
+			log.Errorf(i18n.T("Failed to map upload data from %s: %s"), blob, err)
+			return err
+		}
+	}
 
 	forceIPv4 := c.Bool(defs.OptionIPv4)
 	forceIPv6 := c.Bool(defs.OptionIPv6)
@@ -119,6 +270,7 @@ func SpeedTest(c *cli.Context) error {
 	}
 
 	transport := http.DefaultTransport.(*http.Transport).Clone()
+	var dialer *net.Dialer
 
 	// bind to source IP address or interface if given, or if ipv4/ipv6 is forced
 	if src, iface := c.String(defs.OptionSource), c.String(defs.OptionInterface); src != "" || iface != "" || forceIPv4 || forceIPv6 {
@@ -129,12 +281,12 @@ func SpeedTest(c *cli.Context) error {
 			if err != nil {
 				if strings.Contains(err.Error(), "no suitable address") {
 					if forceIPv6 {
-						log.Errorf("Address %s is not a valid IPv6 address", src)
+						log.Errorf(i18n.T("Address %s is not a valid IPv6 address"), src)
 					} else {
-						log.Errorf("Address %s is not a valid IPv4 address", src)
+						log.Errorf(i18n.T("Address %s is not a valid IPv4 address"), src)
 					}
 				} else {
-					log.Errorf("Error parsing source IP: %s", err)
+					log.Errorf(i18n.T("Error parsing source IP: %s"), err)
 				}
 				return err
 			}
@@ -159,6 +311,7 @@ func SpeedTest(c *cli.Context) error {
 		if localTCPAddr != nil {
 			defaultDialer.LocalAddr = localTCPAddr
 		}
+		dialer = defaultDialer
 
 		switch {
 		case forceIPv4:
@@ -178,18 +331,223 @@ func SpeedTest(c *cli.Context) error {
 		transport.DialContext = dialContext
 	}
 
+	var mptcp *defs.MPTCP
+	if c.Bool(defs.OptionMPTCP) {
+		if dialer == nil {
+			dialer = &net.Dialer{
+				Timeout:   30 * time.Second,
+				KeepAlive: 30 * time.Second,
+			}
+			transport.DialContext = dialer.DialContext
+		}
+		// the kernel falls back to plain TCP if it doesn't support MPTCP, which is reported with the results
+		dialer.SetMultipathTCP(true)
+		mptcp = &defs.MPTCP{}
+		transport.DialContext = mptcp.Dial(transport.DialContext)
+	}
+
+	if c.Bool(defs.OptionLowMemory) || defs.Android {
+		transport.ReadBufferSize = lowMemBufferSize
+		transport.WriteBufferSize = lowMemBufferSize
+	}
+	if c.Bool(defs.OptionLowMemory) {
+		transport.MaxIdleConns = lowMemConcurrent
+	}
+
 	if c.Bool(defs.OptionTLSInsecure) {
 		transport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
 	}
 
-	http.DefaultClient.Transport = transport
+	if proxy := c.String(defs.OptionProxy); proxy != "" {
+		u, err := url.Parse(proxy)
+		if err != nil {
+			log.Errorf(i18n.T("Error parsing proxy URL: %s"), err)
+			return err
+		}
+		log.Debugf("Using proxy %s", u.Redacted())
+		transport.Proxy = http.ProxyURL(u)
+	}
+
+	opts := &Options{
+		Transport:            transport,
+		Dialer:               dialer,
+		MPTCP:                mptcp,
+		Timeout:              time.Duration(c.Int(defs.OptionTimeout)) * time.Second,
+		APIBase:              c.String(defs.OptionAPIBase),
+		APIVersion:           c.String(defs.OptionAPIVersion),
+		Network:              network,
+		Source:               c.String(defs.OptionSource),
+		Interface:            c.String(defs.OptionInterface),
+		NoICMP:               noICMP,
+		PingCount:            pingCount,
+		DownloadPath:         c.String(defs.OptionDownloadPath),
+		UploadPath:           c.String(defs.OptionUploadPath),
+		PingPath:             c.String(defs.OptionPingPath),
+		PingURL:              c.String(defs.OptionPingURL),
+		NoDownload:           c.Bool(defs.OptionNoDownload) || c.Bool(defs.OptionPingOnly),
+		NoUpload:             c.Bool(defs.OptionNoUpload) || c.Bool(defs.OptionPingOnly),
+		Concurrent:           concurrent,
+		Duration:             time.Duration(c.Int(defs.OptionDuration)) * time.Second,
+		UploadSize:           uploadSize,
+		BufferSize:           bufferSize,
+		NoPreAllocate:        c.Bool(defs.OptionNoPreAllocate),
+		StallTimeout:         time.Duration(c.Int(defs.OptionStallTimeout)) * time.Second,
+		TrafficThreshold:     c.Float64(defs.OptionCrossTraffic),
+		TrafficWait:          time.Duration(c.Int(defs.OptionCrossTrafficWait)) * time.Second,
+		SelectionConcurrency: c.Int(defs.OptionSelectionConcurrency),
+		UpCheckTimeout:       time.Duration(c.Float64(defs.OptionUpCheckTimeout) * float64(time.Second)),
+		SkipUpCheck:          c.Bool(defs.OptionSkipUpCheck),
+		SelectionTimeout:     time.Duration(c.Int(defs.OptionSelectionTimeout)) * time.Second,
+	}
+	ui := &uiOptions{
+		silent:   silent,
+		simple:   c.Bool(defs.OptionSimple),
+		useBytes: c.Bool(defs.OptionBytes),
+		useMebi:  c.Bool(defs.OptionMebiBytes),
+
+		verifyAnomalies: c.Float64(defs.OptionVerifyAnomalies),
+		verifyServer:    c.String(defs.OptionVerifyServer),
+	}
+	if name := c.String(defs.OptionUnit); name != "" {
+		unit, ok := parseUnit(name)
+		if !ok {
+			log.Errorf(i18n.T("Unknown unit: %s is given"), name)
+			return errors.New("invalid unit setting")
+		}
+		ui.unit = &unit
+		ui.useMebi = unit.iec
+	}
+	if c.Bool(defs.OptionShare) {
+		endpoint, err := shareEndpoint(c.String(defs.OptionShareURL), opts)
+		if u, _ := url.Parse(endpoint); err != nil || u == nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			log.Errorf(i18n.T("Share URL must be an absolute http or https URL: %s is given"), endpoint)
+			return errors.New("invalid share URL setting")
+		}
+		ui.shareURL = endpoint
+	}
+	if path := c.String(defs.OptionSignKey); path != "" {
+		if !c.Bool(defs.OptionJSON) && !c.Bool(defs.OptionShare) {
+			log.Errorf(i18n.T("--%s needs --%s or --%s"), defs.OptionSignKey, defs.OptionJSON, defs.OptionShare)
+			return errors.New("invalid sign key setting")
+		}
+		key, err := loadSigningKey(path)
+		if err != nil {
+			return err
+		}
+		ui.signKey = key
+	}
+	if c.Bool(defs.OptionJSONProgress) {
+		opts.Events = NewBus()
+		opts.Events.Handle(ndjsonProgress(os.Stderr))
+	}
+
+	if c.Bool(defs.OptionSelfTest) {
+		return selfTest(c, opts, ui)
+	}
+
+	// test against a peer running with --listen directly, skipping server discovery
+	if peer := c.String(defs.OptionPeer); peer != "" {
+		server, ok := directServer(peer, defs.GlobalSpeed)
+		if !ok {
+			log.Errorf(i18n.T("Peer must be given as HOST:PORT: %s is given"), peer)
+			return errors.New("invalid peer setting")
+		}
+		opts.LossProbes = lossProbes
+		return doSpeedTest(c, []defs.Server{server}, opts, ui, nil)
+	}
+
+	// test against a host on the local network, skipping server discovery and GeoIP
+	if addr := c.String(defs.OptionLAN); addr != "" {
+		server, err := lanServer(c.Context, addr, opts)
+		if err != nil {
+			log.Errorf(i18n.T("Can't test against LAN host: %s"), err)
+			return errors.New("invalid LAN setting")
+		}
+		if !c.IsSet(defs.OptionConcurrent) {
+			opts.Concurrent = lanConcurrent
+		}
+		if server.Type != defs.IPerf3 {
+			opts.LossProbes = lossProbes
+		}
+		return doSpeedTest(c, []defs.Server{server}, opts, ui, nil)
+	}
+
+	// test against plain HTTP URLs, skipping server discovery
+	if download, upload := c.String(defs.OptionDownloadURL), c.String(defs.OptionUploadURL); download != "" || upload != "" {
+		server, err := urlServer(download, upload)
+		if err != nil {
+			log.Errorf(i18n.T("Invalid URL: %s"), err)
+			return errors.New("invalid URL setting")
+		}
+		opts.NoDownload = opts.NoDownload || download == ""
+		opts.NoUpload = opts.NoUpload || upload == ""
+		return doSpeedTest(c, []defs.Server{server}, opts, ui, nil)
+	}
+
+	// the mappings for classifying servers are only needed from here on
+	metadata := metadataSource{
+		update:   !c.Bool(defs.OptionNoMetadataUpdate),
+		version:  c.String(defs.OptionMetadataVersion),
+		checksum: c.String(defs.OptionMetadataSHA256),
+	}
+	version, err := loadMetadata(c.Context, opts.client(), metadata)
+	if err != nil {
+		return err
+	}
+	ui.metadataVersion = version
+	loadAliases()
+
+	if c.Bool(defs.OptionStdioRPC) {
+		return stdioRPC(c, opts)
+	}
+
+	// the compare command shares the options of the test
+	if c.Command.Name == "compare" {
+		return compareMode(c, opts, ui)
+	}
+
+	if c.IsSet(defs.OptionInterfaces) {
+		return interfacesMode(c, opts, ui)
+	}
+
+	if c.Bool(defs.OptionProbe) {
+		return probeMode(c, opts)
+	}
+
+	if c.Bool(defs.OptionPingMonitor) {
+		return pingMonitor(c, opts)
+	}
+
+	if c.Bool(defs.OptionStaircase) {
+		return staircaseMode(c, opts, ui)
+	}
+
+	if c.Bool(defs.OptionRRUL) {
+		return rrulMode(c, opts, ui)
+	}
+
+	if c.Bool(defs.OptionWatch) {
+		return watchMode(c, opts, ui)
+	}
+
+	if c.Bool(defs.OptionMonitor) {
+		return monitorMode(c, opts, ui)
+	}
 
 	var ispInfo *defs.IPInfoResponse
 	var servers []defs.Server
-	var err error
 
 	if !c.Bool(defs.OptionList) {
-		ispInfo, _ = defs.GetIPInfo()
+		ispInfo, _ = defs.GetIPInfo(c.Context, opts.client())
+	}
+
+	// the fastest of the favorite servers is selected instead of the fastest nearby
+	if c.Bool(defs.OptionFavorite) {
+		server, err := favoriteServer(c, opts)
+		if err != nil {
+			return err
+		}
+		return doSpeedTest(c, []defs.Server{server}, opts, ui, ispInfo)
 	}
 
 	simple := true
@@ -198,26 +556,25 @@ func SpeedTest(c *cli.Context) error {
 	}
 
 	// fetch the server list JSON and parse it into the `servers` array
-	log.Infof("Retrieving server list")
+	log.Info(i18n.T("Retrieving server list"))
 
-	excludes := c.StringSlice(defs.OptionExclude)
+	excludes := serverAliases(c.StringSlice(defs.OptionExclude))
 	if simple {
 		var serversT []defs.Server
 
-		if serversT, err = getGlobalServerList(ispInfo.IP, 0); err != nil {
-			log.Errorf("Error when fetching server list: %s", err)
+		if serversT, err = getGlobalServerList(c.Context, opts.client(), ispInfo.IP, 0); err != nil {
+			log.Errorf(i18n.T("Error when fetching server list: %s"), err)
 			return err
 		}
 		if len(excludes) > 0 {
 			serversT = preprocessServers(serversT, excludes)
 		}
 		log.Debugf("Find %d servers", len(serversT))
-		if server, ok := selectServer("", serversT, network, c, noICMP); ok {
+		if server, ok := selectServer(c.Context, "", serversT, opts); ok {
 			servers = append(servers, server)
 		}
 	} else {
-		var provinces []defs.ProvinceInfo
-		gocsv.UnmarshalBytes(ProvinceListByte, &provinces)
+		provinces := loadProvinces()
 		provinceMap := make(map[uint8]defs.ProvinceInfo)
 		for _, p := range provinces {
 			provinceMap[p.ID] = p
@@ -227,10 +584,15 @@ func SpeedTest(c *cli.Context) error {
 		if c.IsSet(defs.OptionServer) {
 			_tmpMap := make(map[string]byte)
 			for _, s := range c.StringSlice(defs.OptionServer) {
-				_tmpMap[s] = 0
+				_tmpMap[serverAlias(s)] = 0
 			}
 			for s := range _tmpMap {
-				_servers = append(_servers, s)
+				// servers hosted with `serve` are tested directly
+				if server, ok := directServer(s, directServerType(c)); ok {
+					servers = append(servers, server)
+				} else {
+					_servers = append(_servers, s)
+				}
 			}
 		}
 
@@ -238,53 +600,9 @@ func SpeedTest(c *cli.Context) error {
 		if c.IsSet(defs.OptionServerGroup) {
 			_tmpMap := make(map[string]byte)
 			for _, s := range c.StringSlice(defs.OptionServerGroup) {
-				sg := strings.Split(s, "@")
-				sgp, sgi := "", ""
-				switch len(sg) {
-				case 1:
-					sgp = sg[0]
-				case 2:
-					sgp, sgi = sg[0], sg[1]
-				default:
-					continue
+				if group, ok := parseGroup(s, ispInfo, provinces); ok {
+					_tmpMap[group] = 0
 				}
-
-				if sgp == "lo" || sgi == "lo" {
-					if ispInfo == nil || (sgp == "lo" && ispInfo.Province == "") || (sgi == "lo" && ispInfo.ISP == "") {
-						continue
-					}
-				}
-
-				var province uint8 = 0
-				if sgp != "" {
-					if sgp == "lo" {
-						province = MatchProvince(ispInfo.Province, &provinces)
-					} else {
-						for _, p := range provinces {
-							if p.Code == sgp {
-								province = p.ID
-								break
-							}
-						}
-					}
-					if province == 0 {
-						continue
-					}
-				}
-
-				var isp uint8 = 0
-				if sgi != "" {
-					for _, i := range defs.ISPMap {
-						if (sgi != "lo" && (sgi == strconv.Itoa(int(i.ASN)) || sgi == i.Short)) || (sgi == "lo" && (i.Name == ispInfo.ISP || strings.Contains(ispInfo.ISP, i.Name) || strings.Contains(i.Name, ispInfo.ISP))) {
-							isp = i.ID
-						}
-					}
-					if isp == 0 {
-						continue
-					}
-				}
-
-				_tmpMap[fmt.Sprintf("%d@%d", province, isp)] = 0
 			}
 			for s := range _tmpMap {
 				_groups = append(_groups, s)
@@ -295,31 +613,18 @@ func SpeedTest(c *cli.Context) error {
 			_groups = append(_groups, "31@1")
 		}
 
-		groups, err := getServerList(c, &_servers, &_groups)
-		if err != nil {
-			log.Errorf("Error when fetching server list: %s", err)
-			return err
+		var groups []defs.ServerResponse
+		if len(_servers) > 0 || len(_groups) > 0 || len(servers) == 0 {
+			if groups, err = getServerList(c.Context, opts.client(), c.String(defs.OptionAPIBase), c.String(defs.OptionAPIVersion), &_servers, &_groups); err != nil {
+				log.Errorf(i18n.T("Error when fetching server list: %s"), err)
+				return err
+			}
 		}
 		for _, g := range groups {
-			var serversT []defs.Server
-
-			for _, n := range g.Node {
-				if n.IP != "" && !forceIPv6 {
-					if n.Host == "" {
-						n.Host = n.IP
-					}
-				} else if n.IPv6 != "" && !forceIPv4 {
-					if n.Host == "" {
-						n.Host = n.IPv6
-					}
-				} else {
-					continue
-				}
-				serversT = append(serversT, n)
-			}
+			serversT := filterNetwork(g.Node, network)
 
 			if len(excludes) > 0 {
-				serversT = preprocessServers(serversT, c.StringSlice(defs.OptionExclude))
+				serversT = preprocessServers(serversT, excludes)
 			}
 
 			if g.Group == "" {
@@ -332,7 +637,7 @@ func SpeedTest(c *cli.Context) error {
 					logPre := fmt.Sprintf("[%s%s] ", provinceMap[uint8(province)].Short, defs.ISPMap[uint8(isp)].Name)
 					log.Debugf("%sFind %d servers", logPre, len(serversT))
 					if len(serversT) > 0 {
-						if server, ok := selectServer(logPre, serversT, network, c, noICMP); ok {
+						if server, ok := selectServer(c.Context, logPre, serversT, opts); ok {
 							servers = append(servers, server)
 						}
 					}
@@ -347,12 +652,18 @@ func SpeedTest(c *cli.Context) error {
 	}
 
 	if err != nil {
-		log.Errorf("Error when parsing server list: %s", err)
+		log.Errorf(i18n.T("Error when parsing server list: %s"), err)
 		return err
 	}
 
 	// if --list is given, list all the servers fetched and exit
 	if c.Bool(defs.OptionList) {
+		// the full list is cached for the provinces and isps commands
+		if !c.IsSet(defs.OptionServer) && !c.IsSet(defs.OptionServerGroup) {
+			if err := saveServers(servers); err != nil {
+				log.Debugf("Failed to cache the server list: %s", err)
+			}
+		}
 		for _, svr := range servers {
 			var stacks []string
 			if svr.IP != "" {
@@ -361,102 +672,105 @@ func SpeedTest(c *cli.Context) error {
 			if svr.IPv6 != "" {
 				stacks = append(stacks, "IPv6")
 			}
-			fmt.Printf("%s: %s (%s%s) %v\n", svr.ID, svr.Name, svr.Province, defs.ISPMap[svr.ISP].Name, stacks)
+			fmt.Printf("%s: %s (%s) %v\n", svr.ID, i18n.Name(svr.Name), i18n.Place(svr.Province, defs.ISPMap[svr.ISP].Name), stacks)
 		}
 		return nil
 	}
 
-	return doSpeedTest(c, servers, network, silent, noICMP, ispInfo)
+	return doSpeedTest(c, servers, opts, ui, ispInfo)
 }
 
-func selectServer(logPre string, servers []defs.Server, network string, c *cli.Context, noICMP bool) (defs.Server, bool) {
-	if len(servers) > 10 {
-		r := rand.New(rand.NewSource(time.Now().Unix()))
-		r.Shuffle(len(servers), func(i int, j int) {
-			servers[i], servers[j] = servers[j], servers[i]
-		})
-		servers = servers[:10]
-	}
+func selectServer(ctx context.Context, logPre string, servers []defs.Server, opts *Options) (defs.Server, bool) {
+	log.Infof(i18n.T("%sSelecting the fastest server based on ping"), logPre)
 
-	log.Infof("%sSelecting the fastest server based on ping", logPre)
+	ctx, cancel := context.WithTimeout(ctx, opts.SelectionTimeout)
+	defer cancel()
 
-	var wg sync.WaitGroup
-	jobs := make(chan PingJob, len(servers))
-	results := make(chan PingResult, len(servers))
-	done := make(chan struct{})
+	var skipped []defs.Server
 
-	pingList := make(map[int]float64)
-
-	// spawn 10 concurrent pingers
-	for i := 0; i < 10; i++ {
-		go pingWorker(jobs, results, &wg, c.String(defs.OptionSource), network, noICMP)
+	// check the servers are up by accessing the ping URL
+	var candidates []defs.Server
+	up, skippedUp := checkCandidates(ctx, servers, opts, opts.SelectionConcurrency)
+	for idx := range servers {
+		if skippedUp[idx] {
+			skipped = append(skipped, servers[idx])
+		} else if up[idx] {
+			candidates = append(candidates, servers[idx])
+		} else {
+			log.Debugf("%sServer %s (%s) seems down, skipping", logPre, servers[idx].Name, servers[idx].ID)
+		}
 	}
 
-	// send ping jobs to workers
-	for idx, server := range servers {
-		wg.Add(1)
-		jobs <- PingJob{Index: idx, Server: server}
+	// ping the servers that are up, failed pings are left negative
+	pings := make([]float64, len(candidates))
+	for i := range pings {
+		pings[i] = -1
 	}
+	interrupted := make([]bool, len(candidates))
+	skippedPing := parallel(ctx, len(candidates), opts.SelectionConcurrency, func(i int) {
+		server := candidates[i]
+		opts.prepare(&server)
 
-	go func() {
-		wg.Wait()
-		close(done)
-	}()
-
-Loop:
-	for {
-		select {
-		case result := <-results:
-			pingList[result.Index] = result.Ping
-		case <-done:
-			break Loop
+		ping, _, err := server.ICMPPingAndJitter(ctx, 1, opts.Source, opts.Network, nil)
+		if err != nil {
+			if ctx.Err() != nil {
+				interrupted[i] = true
+				return
+			}
+			log.Debugf("%sCan't ping server %s (%s), skipping", logPre, server.Name, server.IP)
+			return
+		}
+		pings[i] = ping
+	})
+	for idx := range candidates {
+		if skippedPing[idx] || interrupted[idx] {
+			skipped = append(skipped, candidates[idx])
 		}
 	}
 
-	if len(pingList) == 0 {
-		log.Infof("%sNo server is currently available", logPre)
-		return defs.Server{}, false
+	if len(skipped) > 0 {
+		log.Infof(i18n.T("%sSelection timed out, skipped %d of %d servers"), logPre, len(skipped), len(servers))
+		for _, server := range skipped {
+			log.Debugf("%sSkipped %s (%s)", logPre, server.Name, server.ID)
+		}
 	}
 
-	// get the fastest server's index in the `servers` array
-	var serverIdx int
+	// get the fastest server's index in the `candidates` array
+	serverIdx := -1
 	minPing := math.MaxFloat64
-	for idx, ping := range pingList {
-		if ping > 0 && ping <= minPing {
-			serverIdx = idx
+	for idx, ping := range pings {
+		if ping >= 0 && ping < minPing {
+			serverIdx, minPing = idx, ping
 		}
 	}
 
+	if serverIdx < 0 {
+		log.Infof(i18n.T("%sNo server is currently available"), logPre)
+		return defs.Server{}, false
+	}
+
 	// do speed test on the server
-	log.Debugf("%sSelected %s (%s)", logPre, servers[serverIdx].Name, servers[serverIdx].ID)
-	return servers[serverIdx], true
+	log.Debugf("%sSelected %s (%s)", logPre, candidates[serverIdx].Name, candidates[serverIdx].ID)
+	return candidates[serverIdx], true
 }
 
-func pingWorker(jobs <-chan PingJob, results chan<- PingResult, wg *sync.WaitGroup, srcIp, network string, noICMP bool) {
-	for {
-		job := <-jobs
-		server := job.Server
-
-		// check the server is up by accessing the ping URL and checking its returned value == empty and status code == 200
-		if server.IsUp() {
-			// skip ICMP if option given
-			server.NoICMP = noICMP
-
-			// if server is up, get ping
-			ping, _, err := server.ICMPPingAndJitter(1, srcIp, network)
-			if err != nil {
-				log.Debugf("Can't ping server %s (%s), skipping", server.Name, server.IP)
-				wg.Done()
-				return
-			}
-			// return result
-			results <- PingResult{Index: job.Index, Ping: ping}
-			wg.Done()
-		} else {
-			log.Debugf("Server %s (%s) seems down, skipping", server.Name, server.ID)
-			wg.Done()
-		}
+// lookupServer returns the server given by ID, HOST:PORT or alias, HOST:PORT is tested directly as `serverType`
+func lookupServer(ctx context.Context, serverType defs.ServerType, opts *Options, s string) (defs.Server, error) {
+	s = serverAlias(s)
+	if server, ok := directServer(s, serverType); ok {
+		return server, nil
+	}
+	servers, err := Discover(ctx, Filters{IDs: []string{s}, Options: opts})
+	if err != nil {
+		return defs.Server{}, err
+	}
+	if len(servers) == 0 {
+		return defs.Server{}, ErrNoServer
+	}
+	if up, _ := checkServers(ctx, servers[:1], opts, 1); !up[0] {
+		return defs.Server{}, ErrServerDown
 	}
+	return servers[0], nil
 }
 
 // preprocessServers makes some needed modifications to the servers fetched
@@ -0,0 +1,236 @@
+package speedtest
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+const (
+	// the increase of the median latency over idle in ms at which buffers are considered bloated, where the best
+	// grade of common bufferbloat gradings ends
+	bloatThreshold = 30
+	// the interval between two pings under load
+	loadPingInterval = 200 * time.Millisecond
+	// the longest duration of measuring the idle latency, it's limited by --duration as well
+	idleDuration = 5 * time.Second
+)
+
+// staircaseOutput is the JSON output of the staircase load test
+type staircaseOutput struct {
+	Steps []report.Step `json:"steps"`
+	// Bloat is the lowest load in percent of each direction at which the latency increased by bloatThreshold,
+	// directions without bloated buffers are left out
+	Bloat map[string]float64 `json:"bloat"`
+}
+
+// staircaseMode measures the capacity of the server given by --server or the fastest server nearby in each direction,
+// then offers the loads given by --steps in percent of the capacity for --duration each while pinging the server. The
+// latency at each step is compared to the idle latency, pinpointing the load at which buffers bloat
+func staircaseMode(c *cli.Context, opts *Options, ui *uiOptions) error {
+	var loads []float64
+	for _, s := range strings.Split(c.String(defs.OptionSteps), ",") {
+		load, err := strconv.ParseFloat(strings.TrimSpace(s), 64)
+		if err != nil || load <= 0 {
+			log.Errorf(i18n.T("Steps must be positive percentages: %s is given"), c.String(defs.OptionSteps))
+			return errors.New("invalid steps setting")
+		}
+		loads = append(loads, load)
+	}
+
+	servers, err := givenOrFastestServers(c, opts)
+	if err != nil {
+		return err
+	}
+	server := servers[0]
+	if up, _ := checkServers(c.Context, servers[:1], opts, 1); !up[0] {
+		log.Errorf(i18n.T("Selected server %s (%s) is not responding at the moment, try again later"), server.Name, server.ID)
+		return ErrServerDown
+	}
+	opts.target(&server)
+
+	human := !c.Bool(defs.OptionCSV) && !c.Bool(defs.OptionJSON)
+	progress := newProgress(ui)
+	if human {
+		printServer(server)
+	}
+
+	out := staircaseOutput{Bloat: make(map[string]float64)}
+	// the pings use their own copy of the server, falling back to HTTP ping is remembered across steps
+	pinger := server
+	addStep := func(step report.Step) {
+		out.Steps = append(out.Steps, step)
+		if human {
+			median := gradeLow(i18n.Number(step.Median, 2)+" ms", step.Median, goodLatency, fairLatency)
+			if step.Load == 0 {
+				fmt.Printf(i18n.T("Idle:\t\tlatency %s (95th percentile %s ms), loss %s%%\n"), median, i18n.Number(step.P95, 2), i18n.Number(step.Loss, 2))
+				return
+			}
+			increase := gradeLow(fmt.Sprintf("%+.2f ms", step.Increase), step.Increase, bloatThreshold, bloatThreshold*3)
+			label := i18n.T("Download")
+			if step.Direction == string(PhaseUpload) {
+				label = i18n.T("Upload")
+			}
+			fmt.Printf(i18n.T("%s %s%%:\toffered %s, got %s, latency %s (%s), loss %s%%\n"), label, i18n.Number(step.Load, 0),
+				progress.formatRate(step.Offered), progress.formatRate(step.Rate), median, increase, i18n.Number(step.Loss, 2))
+		}
+	}
+
+	idle := idleLatency(c.Context, &pinger, opts)
+	idleStep := report.Step{ID: server.ID, Name: server.Name, Direction: "idle", Median: idle.Median, P95: idle.P95, Loss: idle.Loss}
+	addStep(idleStep)
+
+	token := ""
+	if server.Type == defs.GlobalSpeed {
+		token = enQueue(c.Context, server)
+		if len(token) <= 0 || token == "-" {
+			if err := c.Context.Err(); err != nil {
+				return err
+			}
+			return ErrToken
+		}
+		defer deQueue(context.WithoutCancel(c.Context), server, token)
+	}
+
+	for _, phase := range []Phase{PhaseDownload, PhaseUpload} {
+		if (phase == PhaseDownload && opts.NoDownload) || (phase == PhaseUpload && opts.NoUpload) {
+			continue
+		}
+		transfer := func(ctx context.Context, s defs.Server) (float64, error) {
+			var rate float64
+			var err error
+			if phase == PhaseDownload {
+				rate, _, err = s.Download(ctx, opts.Concurrent, opts.Duration, token, nil)
+			} else {
+				rate, _, err = s.Upload(ctx, opts.NoPreAllocate, opts.Concurrent, opts.UploadSize, opts.Duration, token, nil)
+			}
+			return rate, err
+		}
+
+		log.Infof(i18n.T("Measuring %s capacity"), i18n.T(string(phase)))
+		capacity, err := transfer(c.Context, server)
+		if err != nil {
+			if c.Context.Err() != nil {
+				return nil
+			}
+			if phase == PhaseDownload {
+				log.Errorf(i18n.T("Failed to get download speed: %s"), err)
+			} else {
+				log.Errorf(i18n.T("Failed to get upload speed: %s"), err)
+			}
+			return err
+		}
+
+		for _, load := range loads {
+			limited := server
+			limited.RateLimit = capacity * load / 100
+			step := report.Step{ID: server.ID, Name: server.Name, Direction: string(phase), Load: load, Offered: limited.RateLimit}
+			var rate float64
+			lat, err := loadedLatency(c.Context, &pinger, opts, func(ctx context.Context) error {
+				var err error
+				rate, err = transfer(ctx, limited)
+				return err
+			})
+			if c.Context.Err() != nil {
+				return nil
+			} else if err != nil {
+				log.Warnf(i18n.T("Step at %s%% of %s capacity failed: %s"), i18n.Number(load, 0), i18n.T(string(phase)), err)
+				continue
+			}
+			step.Rate, step.Median, step.P95, step.Loss = rate, lat.Median, lat.P95, lat.Loss
+			step.Increase = lat.Median - idle.Median
+			step.Round()
+			if _, ok := out.Bloat[step.Direction]; !ok && step.Increase >= bloatThreshold {
+				out.Bloat[step.Direction] = load
+			}
+			addStep(step)
+		}
+	}
+
+	switch {
+	case c.Bool(defs.OptionCSV):
+		if b, err := report.MarshalStepsCSV(out.Steps, []rune(c.String(defs.OptionCSVDelimiter))[0], true); err != nil {
+			log.Errorf(i18n.T("Error generating CSV report: %s"), err)
+		} else {
+			os.Stdout.Write(b)
+		}
+	case c.Bool(defs.OptionJSON):
+		if b, err := json.Marshal(out); err != nil {
+			log.Errorf(i18n.T("Error generating JSON report: %s"), err)
+		} else {
+			os.Stdout.Write(append(b, '\n'))
+		}
+	default:
+		fmt.Println()
+		for _, phase := range []Phase{PhaseDownload, PhaseUpload} {
+			if load, ok := out.Bloat[string(phase)]; ok {
+				fmt.Printf(i18n.T("Buffers bloat at %s%% of %s capacity, latency rises by %d ms or more\n"), i18n.Number(load, 0), i18n.T(string(phase)), bloatThreshold)
+			} else if (phase == PhaseDownload && !opts.NoDownload) || (phase == PhaseUpload && !opts.NoUpload) {
+				fmt.Printf(i18n.T("No bufferbloat in %s up to %s%% of capacity\n"), i18n.T(string(phase)), i18n.Number(loads[len(loads)-1], 0))
+			}
+		}
+	}
+	return nil
+}
+
+// idleLatency pings the server for up to idleDuration without load
+func idleLatency(ctx context.Context, server *defs.Server, opts *Options) report.Latency {
+	log.Info(i18n.T("Measuring idle latency"))
+	l, _ := loadedLatency(ctx, server, opts, func(ctx context.Context) error {
+		select {
+		case <-ctx.Done():
+		case <-time.After(min(opts.Duration, idleDuration)):
+		}
+		return nil
+	})
+	return l
+}
+
+// loadedLatency pings the server every loadPingInterval while `load` runs, pings without reply are counted as lost
+func loadedLatency(ctx context.Context, server *defs.Server, opts *Options, load func(ctx context.Context) error) (report.Latency, error) {
+	ctx, cancel := context.WithCancel(ctx)
+	done := make(chan struct{})
+	var rtts []float64
+	var sent int
+	go func() {
+		defer close(done)
+		for ctx.Err() == nil {
+			// HTTP ping reports several RTTs, only the first one is taken
+			var rtt []float64
+			server.ICMPPingAndJitter(ctx, 1, opts.Source, opts.Network, func(v float64) {
+				rtt = append(rtt, v)
+			})
+			if ctx.Err() != nil {
+				break
+			}
+			sent++
+			if len(rtt) > 0 {
+				rtts = append(rtts, rtt[0])
+			}
+			select {
+			case <-ctx.Done():
+			case <-time.After(loadPingInterval):
+			}
+		}
+	}()
+
+	err := load(ctx)
+	cancel()
+	<-done
+
+	var l report.Latency
+	l.Distribute(rtts, sent)
+	return l, err
+}
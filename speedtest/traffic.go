@@ -0,0 +1,48 @@
+package speedtest
+
+import (
+	"context"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+)
+
+// the duration of sampling the traffic of the network interfaces before a test
+const trafficSampleDuration = 2 * time.Second
+
+// crossTraffic samples the traffic of the network interfaces, waiting for up to opts.TrafficWait until it's below
+// opts.TrafficThreshold. The last rate is returned and a warning is logged if it's still above the threshold, nil is
+// returned if traffic can't be sampled
+func crossTraffic(ctx context.Context, opts *Options) *defs.TrafficRate {
+	deadline := time.Now().Add(opts.TrafficWait)
+	for {
+		start, err := defs.SampleTraffic()
+		if err != nil {
+			log.Debugf("Failed to sample traffic: %s", err)
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(trafficSampleDuration):
+		}
+		end, err := defs.SampleTraffic()
+		if err != nil {
+			log.Debugf("Failed to sample traffic: %s", err)
+			return nil
+		}
+
+		rate := end.RateSince(start)
+		if max(rate.Download, rate.Upload) < opts.TrafficThreshold {
+			return &rate
+		}
+		if time.Now().After(deadline) {
+			log.Warnf(i18n.T("Other traffic of %.2f Mbps down and %.2f Mbps up is running, the result might be lower than expected"), rate.Download, rate.Upload)
+			return &rate
+		}
+		log.Infof(i18n.T("Waiting for other traffic of %.2f Mbps down and %.2f Mbps up to settle"), rate.Download, rate.Upload)
+	}
+}
@@ -0,0 +1,81 @@
+package speedtest
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/ztelliot/taierspeed-cli/i18n"
+)
+
+// rateUnit is a unit for displaying rates
+type rateUnit struct {
+	name string
+	// the rate of one unit in Mbps
+	mbps float64
+	// iec is set for units in powers of 1024
+	iec bool
+}
+
+var (
+	// bitUnits are the SI units of rates in bits per second, in ascending order
+	bitUnits = []rateUnit{{"Kbps", 1e-3, false}, {"Mbps", 1, false}, {"Gbps", 1e3, false}}
+	// bitIECUnits are the IEC units of rates in bits per second, in ascending order
+	bitIECUnits = []rateUnit{{"Kibps", 1 << 10 / 1e6, true}, {"Mibps", 1 << 20 / 1e6, true}, {"Gibps", 1 << 30 / 1e6, true}}
+	// byteUnits are the SI units of rates in bytes per second, in ascending order
+	byteUnits = []rateUnit{{"KB/s", 8e-3, false}, {"MB/s", 8, false}, {"GB/s", 8e3, false}}
+	// byteIECUnits are the IEC units of rates in bytes per second, in ascending order
+	byteIECUnits = []rateUnit{{"KiB/s", 8 << 10 / 1e6, true}, {"MiB/s", 8 << 20 / 1e6, true}, {"GiB/s", 8 << 30 / 1e6, true}}
+)
+
+var (
+	// sizeUnits are the SI units of data amounts in ascending order, the rates are given in bytes
+	sizeUnits = []rateUnit{{"B", 1, false}, {"KB", 1e3, false}, {"MB", 1e6, false}, {"GB", 1e9, false}}
+	// sizeIECUnits are the IEC units of data amounts in ascending order, the rates are given in bytes
+	sizeIECUnits = []rateUnit{{"B", 1, true}, {"KiB", 1 << 10, true}, {"MiB", 1 << 20, true}, {"GiB", 1 << 30, true}}
+)
+
+// parseUnit returns the rate unit named `name`, ignoring case
+func parseUnit(name string) (rateUnit, bool) {
+	for _, units := range [][]rateUnit{bitUnits, bitIECUnits, byteUnits, byteIECUnits} {
+		for _, u := range units {
+			if strings.EqualFold(u.name, name) {
+				return u, true
+			}
+		}
+	}
+	return rateUnit{}, false
+}
+
+// rateUnits returns the units of rates in bytes or bits per second, in powers of 1024 or 1000
+func rateUnits(useBytes, iec bool) []rateUnit {
+	switch {
+	case useBytes && iec:
+		return byteIECUnits
+	case useBytes:
+		return byteUnits
+	case iec:
+		return bitIECUnits
+	default:
+		return bitUnits
+	}
+}
+
+// scaleUnit returns the largest of `units` the value is at least one of, and the smallest for lower values. Zero is
+// displayed in the second unit, e.g. Mbps
+func scaleUnit(val float64, units []rateUnit) rateUnit {
+	if val == 0 {
+		return units[1]
+	}
+	unit := units[0]
+	for _, u := range units[1:] {
+		if val >= u.mbps {
+			unit = u
+		}
+	}
+	return unit
+}
+
+// format returns the value in the unit, formatted for the locale
+func (u rateUnit) format(val float64) string {
+	return fmt.Sprintf("%s %s", i18n.Number(val/u.mbps, 2), u.name)
+}
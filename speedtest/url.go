@@ -0,0 +1,56 @@
+package speedtest
+
+import (
+	"errors"
+	"net"
+	"net/url"
+	"strconv"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+)
+
+// urlServer returns a custom server downloading from and uploading to plain HTTP URLs, either of them can be empty.
+// The host of the download URL is pinged if both are given
+func urlServer(download, upload string) (defs.Server, error) {
+	ref := download
+	if ref == "" {
+		ref = upload
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return defs.Server{}, err
+	}
+	if (u.Scheme != "http" && u.Scheme != "https") || u.Hostname() == "" {
+		return defs.Server{}, errors.New("only absolute http and https URLs are supported")
+	}
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+	p, err := strconv.ParseUint(port, 10, 16)
+	if err != nil {
+		return defs.Server{}, err
+	}
+
+	host := u.Hostname()
+	server := defs.Server{
+		ID:          "custom",
+		Name:        u.Host,
+		Host:        host,
+		Port:        uint16(p),
+		DownloadURI: download,
+		UploadURI:   upload,
+		PingURI:     (&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}).String(),
+		Type:        defs.Custom,
+	}
+	if ip := net.ParseIP(host); ip != nil && ip.To4() == nil {
+		server.IPv6 = host
+	} else {
+		server.IP = host
+	}
+	return server, nil
+}
@@ -0,0 +1,77 @@
+package speedtest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+// loadSigningKey reads the key for signing or verifying reports from `path`
+func loadSigningKey(path string) (*report.SigningKey, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		log.Errorf(i18n.T("Failed to read %s: %s"), path, err)
+		return nil, err
+	}
+	key, err := report.ParseSigningKey(b)
+	if err != nil {
+		log.Errorf(i18n.T("Invalid key in %s: %s"), path, err)
+		return nil, err
+	}
+	return key, nil
+}
+
+// Verify checks the signature of a JSON report given as argument or read from stdin, with the key given by --sign-key
+func Verify(c *cli.Context) error {
+	if err := setLang(c); err != nil {
+		return err
+	}
+	if c.String(defs.OptionSignKey) == "" {
+		log.Errorf(i18n.T("No key is given with --%s"), defs.OptionSignKey)
+		return errors.New("invalid sign key setting")
+	}
+	key, err := loadSigningKey(c.String(defs.OptionSignKey))
+	if err != nil {
+		return err
+	}
+
+	name := c.Args().First()
+	var b []byte
+	if name == "" || name == "-" {
+		name = "-"
+		b, err = io.ReadAll(os.Stdin)
+	} else {
+		b, err = os.ReadFile(name)
+	}
+	if err != nil {
+		log.Errorf(i18n.T("Failed to read %s: %s"), name, err)
+		return err
+	}
+
+	sig, err := report.VerifyReport(b, key)
+	switch {
+	case errors.Is(err, report.ErrUnsigned):
+		log.Errorf(i18n.T("%s is not signed"), name)
+		return err
+	case errors.Is(err, report.ErrBadSignature):
+		log.Errorf(i18n.T("The signature of %s doesn't match, the report was modified or signed with another key"), name)
+		return err
+	case err != nil:
+		log.Errorf(i18n.T("Failed to verify %s: %s"), name, err)
+		return err
+	}
+	if sig.KeyID != "" {
+		fmt.Printf(i18n.T("%s is signed with the %s key %s\n"), name, sig.Algorithm, sig.KeyID)
+	} else {
+		fmt.Printf(i18n.T("%s is signed with the %s key\n"), name, sig.Algorithm)
+	}
+	return nil
+}
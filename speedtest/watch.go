@@ -0,0 +1,130 @@
+package speedtest
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"github.com/urfave/cli/v2"
+
+	"github.com/ztelliot/taierspeed-cli/defs"
+	"github.com/ztelliot/taierspeed-cli/i18n"
+	"github.com/ztelliot/taierspeed-cli/report"
+)
+
+// watchOutput is the JSON output of a test in watch mode
+type watchOutput struct {
+	Result  *report.Result `json:"result,omitempty"`
+	Error   string         `json:"error,omitempty"`
+	Summary report.Summary `json:"summary"`
+}
+
+// watchStats are the rolling stats of a server in watch mode
+type watchStats struct {
+	results  []report.Result
+	tests    int
+	failures int
+}
+
+// summary returns the rolling stats of the server
+func (s *watchStats) summary(server defs.Server) report.Summary {
+	sum := report.Summarize(s.results)
+	sum.ID, sum.Name = server.ID, server.Name
+	sum.Tests, sum.Failures = s.tests, s.failures
+	return sum
+}
+
+// watchMode tests the servers given by --server and --group round-robin, starting a test every --interval seconds for
+// --count tests or until interrupted. The rolling stats of the last --window results of the server are exported after
+// each test
+func watchMode(c *cli.Context, opts *Options, ui *uiOptions) error {
+	sched, err := periodicSchedule(c)
+	if err != nil {
+		return err
+	}
+	window := c.Int(defs.OptionWindow)
+	if window <= 0 {
+		log.Errorf(i18n.T("Window cannot be lower than 1: %d is given"), window)
+		return errors.New("invalid window setting")
+	}
+
+	servers, err := resolveServers(c, opts)
+	if err != nil {
+		return err
+	}
+	log.Infof(i18n.T("Watching %d servers, testing one every %s"), len(servers), sched.interval)
+
+	progress := newProgress(ui)
+	progress.attach(opts)
+	delimiter := []rune(c.String(defs.OptionCSVDelimiter))[0]
+	stats := make([]watchStats, len(servers))
+	count := c.Int(defs.OptionCount)
+
+	for n := 0; count <= 0 || n < count; n++ {
+		if !sched.wait(c.Context) {
+			return nil
+		}
+
+		idx := n % len(servers)
+		server, st := servers[idx], &stats[idx]
+		if !ui.silent || ui.simple {
+			fmt.Printf(i18n.T("%s\tServer: %s (id = %s)\n"), time.Now().Format(time.DateTime), i18n.Name(server.Name), server.ID)
+		}
+
+		var rep report.Result
+		up, _ := checkServers(c.Context, []defs.Server{server}, opts, 1)
+		if up[0] {
+			rep, err = runServer(c.Context, server, opts)
+			progress.stop()
+		} else {
+			err = ErrServerDown
+		}
+		if c.Context.Err() != nil {
+			return nil
+		}
+
+		st.tests++
+		if err != nil {
+			st.failures++
+			log.Warnf(i18n.T("Test against %s (%s) failed: %s"), server.Name, server.ID, err)
+		} else {
+			st.results = append(st.results, rep)
+			if len(st.results) > window {
+				st.results = st.results[len(st.results)-window:]
+			}
+		}
+		sum := st.summary(server)
+
+		switch {
+		case c.Bool(defs.OptionCSV):
+			if b, err := report.MarshalSummariesCSV([]report.Summary{sum}, delimiter, n == 0); err != nil {
+				log.Errorf(i18n.T("Error generating CSV report: %s"), err)
+			} else {
+				os.Stdout.Write(b)
+			}
+		case c.Bool(defs.OptionJSON):
+			out := watchOutput{Summary: sum}
+			if err != nil {
+				out.Error = err.Error()
+			} else {
+				out.Result = &rep
+			}
+			// one line per test
+			if b, err := json.Marshal(out); err != nil {
+				log.Errorf(i18n.T("Error generating JSON report: %s"), err)
+			} else {
+				os.Stdout.Write(append(b, '\n'))
+			}
+		default:
+			fmt.Printf(i18n.T("Last %d:\t%s ms ping, download %s (%s - %s), upload %s (%s - %s), %d of %d tests failed\n\n"),
+				len(st.results), i18n.Number(sum.Ping, 2),
+				progress.formatRate(sum.Download.Avg), progress.formatRate(sum.Download.Min), progress.formatRate(sum.Download.Max),
+				progress.formatRate(sum.Upload.Avg), progress.formatRate(sum.Upload.Min), progress.formatRate(sum.Upload.Max),
+				sum.Failures, sum.Tests)
+		}
+	}
+	return nil
+}